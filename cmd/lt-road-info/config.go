@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/config"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/icons"
+	"github.com/dimchansky/lt-road-info/internal/roads"
+	"github.com/dimchansky/lt-road-info/internal/vehicle"
+)
+
+// configFlags registers -config and -profile on fs
+func configFlags(fs *flag.FlagSet) (path, profile *string) {
+	path = fs.String("config", "", "YAML configuration file (default $LT_ROAD_INFO_CONFIG)")
+	profile = fs.String("profile", "", "Profile of the configuration file to apply (default $LT_ROAD_INFO_PROFILE)")
+	return path, profile
+}
+
+// loadConfig loads the configuration file and its environment overrides.
+// Settings given explicitly on the command line are applied afterwards by
+// the caller, see setFlags.
+func loadConfig(path, profile string) (*config.Config, error) {
+	if path == "" {
+		path = os.Getenv("LT_ROAD_INFO_CONFIG")
+	}
+	if profile == "" {
+		profile = os.Getenv("LT_ROAD_INFO_PROFILE")
+	}
+	cfg, err := config.Load(path, profile, os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IconCatalog != "" {
+		if err := icons.Load(cfg.IconCatalog); err != nil {
+			return nil, fmt.Errorf("icon_catalog: %w", err)
+		}
+	}
+	return cfg, nil
+}
+
+// setFlags calls apply with the name of every flag set on the command line,
+// so that flags take precedence over the configuration file
+func setFlags(fs *flag.FlagSet, apply func(name string)) {
+	fs.Visit(func(f *flag.Flag) { apply(f.Name) })
+}
+
+// roadFlags registers -road and -split on fs
+func roadFlags(fs *flag.FlagSet) (road, split *string) {
+	road = fs.String("road", "", "Keep only tracks on these roads, e.g. A1,A6; national roads are KK101 or 101")
+	split = fs.String("split", "", "Also write the outputs split into one file per group: "+strings.Join(config.SplitKinds, ", ")+
+		" (roads come from the track names; categories from the icon catalogue, or else from the feature's icon or name)")
+	return road, split
+}
+
+// setRoads applies a -road list to every source
+func setRoads(cfg *config.Config, list string) error {
+	numbers, err := roads.Parse(list)
+	if err != nil {
+		return fmt.Errorf("invalid -road: %w", err)
+	}
+	cfg.Sources.Restrictions.Filter.Roads = numbers
+	cfg.Sources.SpeedControl.Filter.Roads = numbers
+	return nil
+}
+
+// vehicleFlag registers -vehicle on fs
+func vehicleFlag(fs *flag.FlagSet) *string {
+	return fs.String("vehicle", "", "Keep only restrictions applying to this vehicle, e.g. class=truck,weight=40,axle_load=11.5,height=4,width=2.55,length=16.5 (tonnes, metres); "+
+		"drops nothing unless icon_catalog lists the limit icon codes")
+}
+
+// setVehicle applies a -vehicle spec to the restrictions
+func setVehicle(cfg *config.Config, spec string) error {
+	v, err := vehicle.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid -vehicle: %w", err)
+	}
+	cfg.Sources.Restrictions.Filter.Vehicle = config.Vehicle{
+		Class: v.Class, Weight: v.Weight, AxleLoad: v.AxleLoad, Height: v.Height, Width: v.Width, Length: v.Length,
+	}
+	return nil
+}
+
+// warnVehicle warns that a vehicle profile drops nothing as long as the
+// icon catalogue lists no limit icons
+func warnVehicle(cfg *config.Config) {
+	if cfg.Sources.Restrictions.Filter.Vehicle == (config.Vehicle{}) {
+		return
+	}
+	c, _ := icons.Catalog()
+	for _, icon := range c {
+		if _, ok := icons.Units[icon.Kind]; ok || icon.Kind == icons.KindHeavyVehicles {
+			return
+		}
+	}
+	slog.Warn("The vehicle profile keeps every restriction, as the icon catalogue lists no limit icons; set icon_catalog")
+}
+
+// setSplit applies a -split list to every source
+func setSplit(cfg *config.Config, list string) error {
+	var kinds []string
+	for _, kind := range strings.Split(list, ",") {
+		kind = strings.TrimSpace(kind)
+		if kind == "" {
+			continue
+		}
+		if !slices.Contains(config.SplitKinds, kind) {
+			return fmt.Errorf("invalid -split: unknown split %q, expected one of %s", kind, strings.Join(config.SplitKinds, ", "))
+		}
+		kinds = append(kinds, kind)
+	}
+	cfg.Sources.Restrictions.Split = kinds
+	cfg.Sources.SpeedControl.Split = kinds
+	return nil
+}
+
+// selectSources returns the enabled sources. A -type other than "all"
+// enables the given source only.
+func selectSources(cfg *config.Config, dataType string) ([]source, error) {
+	switch dataType {
+	case "", "all":
+	case restrictionsSource.name, speedControlSource.name:
+		cfg.Sources.Restrictions.Enabled = dataType == restrictionsSource.name
+		cfg.Sources.SpeedControl.Enabled = dataType == speedControlSource.name
+	default:
+		return nil, fmt.Errorf("unknown data type: %s. Use 'all', 'restrictions', or 'speed-control'", dataType)
+	}
+
+	var sources []source
+	for _, src := range []source{restrictionsSource, speedControlSource} {
+		if sc, _ := cfg.Source(src.name); sc.Enabled {
+			sources = append(sources, src)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no source is enabled")
+	}
+	return sources, nil
+}
+
+// newHTTPClient applies the configured timeout and retry policy on top of
+// base, which may be nil. It returns nil, meaning the default client, when
+// there is nothing to apply.
+func newHTTPClient(cfg config.HTTP, base http.RoundTripper) *http.Client {
+	if cfg.Timeout == 0 && cfg.Retries == 0 && base == nil {
+		return nil
+	}
+	return &http.Client{
+		Timeout: time.Duration(cfg.Timeout),
+		Transport: &data.RetryTransport{
+			Base:    base,
+			Retries: cfg.Retries,
+			Delay:   time.Duration(cfg.RetryDelay),
+		},
+	}
+}
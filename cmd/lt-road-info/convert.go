@@ -0,0 +1,234 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/manifest"
+	"github.com/dimchansky/lt-road-info/internal/split"
+)
+
+// fileFlag is a repeatable flag collecting input files. Glob patterns are
+// expanded, so quoted patterns work on every shell.
+type fileFlag struct {
+	name  string
+	files []string
+	last  *string
+}
+
+func (f *fileFlag) String() string {
+	return strings.Join(f.files, " ")
+}
+
+func (f *fileFlag) Set(v string) error {
+	*f.last = f.name
+	return f.add(v)
+}
+
+func (f *fileFlag) add(pattern string) error {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	if len(matches) == 0 {
+		// Not a pattern, or nothing matched: opening it reports the error
+		matches = []string{pattern}
+	}
+	f.files = append(f.files, matches...)
+	return nil
+}
+
+// runConvert implements the convert subcommand and returns the exit code
+func runConvert(args []string) int {
+	fs := flag.NewFlagSet("convert", flag.ExitOnError)
+	configPath, profile := configFlags(fs)
+	logOpts := logFlags(fs)
+	var (
+		last         string
+		eal          = &fileFlag{name: "eal", last: &last}
+		arcgis       = &fileFlag{name: "arcgis", last: &last}
+		formats      = fs.String("format", "", "Comma-separated output formats: gpx, geojson (default from the configuration, gpx)")
+		outputDir    = fs.String("output", ".", "Output directory, or - to write a single source and format to stdout")
+		backup       = fs.Bool("backup", false, "Keep the replaced files as .bak")
+		force        = fs.Bool("force", false, "Replace existing files even with an empty or much smaller dataset")
+		withManifest = fs.Bool("manifest", true, "Write "+manifest.Filename+" listing the generated files with checksums and counts")
+	)
+	road, splitKinds := roadFlags(fs)
+	vehicleSpec := vehicleFlag(fs)
+	fs.Var(eal, "eal", "Saved EAL response (eismoinfo.lt, ?lks=true) with road restrictions (repeatable)")
+	fs.Var(arcgis, "arcgis", "Saved ArcGIS query response with speed control sections in LKS94, outSR=3346 (repeatable)")
+	fs.Usage = usage(fs,
+		`Convert previously saved upstream JSON without any network access, applying
+the filters, styles and simplification of the configuration file. Files
+following -eal or -arcgis belong to that flag, so shell globs can be used
+directly. Outputs are named as configured, e.g. lt-road-restrictions.gpx.`,
+		"convert [flags] -eal <file>... -arcgis <file>...",
+		"Convert a saved restrictions response to GPX and GeoJSON", "lt-road-info convert -eal raw.json -format gpx,geojson",
+		"Convert ArcGIS pages saved one per file", "lt-road-info convert -arcgis pages/*.json -format gpx,geojson -output out",
+		"Reprocess with the filters of a profile and print to stdout", "lt-road-info convert -eal raw.json -profile garmin -config lt-road-info.yaml -output -",
+		"Write one GPX per magistral road of interest", "lt-road-info convert -eal raw.json -arcgis pages/*.json -road A1,A6 -split road -output out",
+	)
+
+	// Files expanded by the shell follow their flag as separate arguments
+	for rest := args; ; {
+		fs.Parse(rest)
+		rest = fs.Args()
+		for len(rest) > 0 && (!strings.HasPrefix(rest[0], "-") || rest[0] == "-") {
+			switch last {
+			case eal.name:
+				eal.add(rest[0])
+			case arcgis.name:
+				arcgis.add(rest[0])
+			default:
+				fmt.Fprintf(os.Stderr, "Unexpected argument %q: input files must follow -eal or -arcgis\n", rest[0])
+				return exitUsage
+			}
+			rest = rest[1:]
+		}
+		if len(rest) == 0 {
+			break
+		}
+	}
+
+	if len(eal.files) == 0 && len(arcgis.files) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(*configPath, *profile)
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitUsage
+	}
+	var flagErr error
+	setFlags(fs, func(name string) {
+		switch name {
+		case "output":
+			cfg.OutputDir = *outputDir
+		case "backup":
+			cfg.Backup = *backup
+		case "force":
+			cfg.Force = *force
+		case "manifest":
+			cfg.Manifest = *withManifest
+		case "road":
+			flagErr = errors.Join(flagErr, setRoads(cfg, *road))
+		case "split":
+			flagErr = errors.Join(flagErr, setSplit(cfg, *splitKinds))
+		case "vehicle":
+			flagErr = errors.Join(flagErr, setVehicle(cfg, *vehicleSpec))
+		}
+	})
+	if flagErr != nil {
+		slog.Error("Invalid arguments", logging.Err(flagErr))
+		return exitUsage
+	}
+	warnVehicle(cfg)
+
+	type input struct {
+		source source
+		files  []string
+		tracks func(stats *converter.Stats) iter.Seq2[converter.Track, error]
+	}
+	var inputs []input
+	if len(eal.files) > 0 {
+		inputs = append(inputs, input{restrictionsSource, eal.files, func(stats *converter.Stats) iter.Seq2[converter.Track, error] {
+			return converter.EALTracksWithStats(data.EALFeaturesFromFiles(eal.files), stats)
+		}})
+	}
+	if len(arcgis.files) > 0 {
+		inputs = append(inputs, input{speedControlSource, arcgis.files, func(stats *converter.Stats) iter.Seq2[converter.Track, error] {
+			return converter.ArcGISTracksWithStats(data.ArcGISFeaturesFromFiles(arcgis.files), stats)
+		}})
+	}
+
+	if *formats != "" {
+		list := strings.Split(*formats, ",")
+		for i := range list {
+			list[i] = strings.TrimSpace(list[i])
+		}
+		cfg.Sources.Restrictions.Formats = list
+		cfg.Sources.SpeedControl.Formats = list
+	}
+	if err := cfg.Validate(); err != nil {
+		slog.Error("Invalid -format", logging.Err(err))
+		return exitUsage
+	}
+
+	if cfg.OutputDir == "-" {
+		sc, _ := cfg.Source(inputs[0].source.name)
+		if len(inputs) != 1 || len(sc.Formats) != 1 {
+			slog.Error("Writing to stdout requires a single source and format")
+			return exitUsage
+		}
+		if err := writeTracks(os.Stdout, sc.Formats[0], inputs[0].source.documentTitle, sc.Apply(inputs[0].tracks(nil))); err != nil {
+			slog.Error("Failed to convert", "source", inputs[0].source.name, logging.Err(err))
+			return exitFailure
+		}
+		return 0
+	}
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		slog.Error("Failed to create output directory", "path", cfg.OutputDir, logging.Err(err))
+		return exitFailure
+	}
+
+	// The manifest of the last run lists the split files that may be removed
+	previous := &manifest.Manifest{}
+	if cfg.Manifest {
+		if previous, err = manifest.Load(filepath.Join(cfg.OutputDir, manifest.Filename)); err != nil {
+			slog.Error("Failed to load manifest", "path", cfg.OutputDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	opts := converter.OutputOptions{Backup: cfg.Backup, Force: cfg.Force}
+	var results []sourceResult
+	for _, in := range inputs {
+		sc, _ := cfg.Source(in.source.name)
+		outputs := sc.Outputs(cfg.OutputDir)
+		r := sourceResult{source: in.source, outputPath: outputs[0].Path, outputs: outputs, inputs: in.files, fetched: time.Now()}
+		tracks := r.counts.count(sc.Apply(in.tracks(&r.stats)))
+		var written []converter.Track
+		if len(sc.Split) > 0 {
+			tracks = collect(tracks, &written)
+		}
+		r.err = converter.SaveTracks(tracks, in.source.documentTitle, outputs, opts)
+		if r.err != nil {
+			slog.Error("Failed to convert", "source", in.source.name, logging.Err(r.err))
+		} else {
+			slog.Info("Converted", "source", in.source.name, "outputs", outputPaths(outputs))
+		}
+		stale := previous.SplitFiles(cfg.OutputDir, in.source.name)
+		if r.err == nil && (len(sc.Split) > 0 || len(stale) > 0) {
+			files, err := split.Write(sc.Split, outputs, in.source.documentTitle, written, stale, opts)
+			if err != nil {
+				slog.Error("Failed to write split outputs", "source", in.source.name, logging.Err(err))
+			}
+			r.splits = files
+		}
+		results = append(results, r)
+	}
+
+	if cfg.Manifest {
+		if err := writeManifest(cfg.OutputDir, results); err != nil {
+			slog.Error("Failed to write manifest", "path", cfg.OutputDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+	return reportResults(results)
+}
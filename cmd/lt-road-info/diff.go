@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/watch"
+)
+
+// runDiff implements the diff subcommand and returns the exit code
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	var (
+		exitCode = fs.Bool("exit-code", false, fmt.Sprintf("Exit with code %d when the files differ", exitCheckFailed))
+		asJSON   = fs.Bool("json", false, "Print the change as JSON, as sent to watch hooks")
+	)
+	fs.Usage = usage(fs,
+		`List the tracks added and removed between two GPX or GeoJSON files.
+Tracks are matched by restriction or object ID when the file carries
+properties (GeoJSON), and by name otherwise.`,
+		"diff [flags] <old> <new>",
+		"Compare yesterday's restrictions with today's", "lt-road-info diff restrictions-yesterday.geojson lt-road-restrictions.geojson",
+		"Publish only when something changed", "lt-road-info diff -exit-code old.gpx new.gpx || ./publish.sh",
+	)
+	fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	var items [2][]watch.Item
+	for i, path := range fs.Args() {
+		tracks, err := converter.ReadFile(path)
+		if err != nil {
+			slog.Error("Failed to read tracks", "path", path, logging.Err(err))
+			return exitFailure
+		}
+		items[i] = watch.Items(tracks)
+	}
+
+	change := watch.Change{Output: fs.Arg(1)}
+	change.Added, change.Removed = watch.Diff(items[0], items[1])
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(change); err != nil {
+			slog.Error("Failed to write change", logging.Err(err))
+			return exitFailure
+		}
+	} else {
+		for _, item := range change.Removed {
+			fmt.Printf("- %s\n", describeItem(item))
+		}
+		for _, item := range change.Added {
+			fmt.Printf("+ %s\n", describeItem(item))
+		}
+		fmt.Printf("%d added, %d removed\n", len(change.Added), len(change.Removed))
+	}
+
+	if *exitCode && !change.Empty() {
+		return exitCheckFailed
+	}
+	return 0
+}
+
+func describeItem(item watch.Item) string {
+	if item.ID == item.Name {
+		return item.Name
+	}
+	return fmt.Sprintf("%s (%s)", item.Name, item.ID)
+}
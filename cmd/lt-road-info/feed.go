@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+)
+
+// feedStateFilename is the default name of the feed state file in the output directory
+const feedStateFilename = ".lt-road-info-feed.json"
+
+// parseFeedFormats parses a comma-separated list of atom and rss
+func parseFeedFormats(spec string) ([]string, error) {
+	var formats []string
+	for _, format := range strings.Split(spec, ",") {
+		switch format = strings.TrimSpace(format); format {
+		case "":
+		case "atom", "rss":
+			formats = append(formats, format)
+		default:
+			return nil, fmt.Errorf("unknown feed format %q, use atom or rss", format)
+		}
+	}
+	return formats, nil
+}
+
+// feedWriter returns a watch.Source AfterWrite function that updates f and
+// writes it next to gpxPath in each of the formats
+func feedWriter(f *feed.Feed, src source, gpxPath string, formats []string) func([]converter.Track, time.Time) error {
+	outputs := feedOutputs(gpxPath, formats)
+	info := feed.Info{Name: src.name, Title: src.documentTitle}
+
+	return func(tracks []converter.Track, t time.Time) error {
+		f.Update(tracks, t)
+		if err := f.Save(); err != nil {
+			return err
+		}
+		for _, out := range outputs {
+			write := f.WriteAtom
+			if out.Format == "rss" {
+				write = f.WriteRSS
+			}
+			if err := saveFeed(out.Path, func(w io.Writer) error { return write(w, info) }); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// feedOutputs returns the feed files written next to gpxPath
+func feedOutputs(gpxPath string, formats []string) []converter.Output {
+	base := strings.TrimSuffix(gpxPath, filepath.Ext(gpxPath))
+	outputs := make([]converter.Output, len(formats))
+	for i, format := range formats {
+		outputs[i] = converter.Output{Path: base + "." + format, Format: format}
+	}
+	return outputs
+}
+
+func saveFeed(path string, write func(io.Writer) error) error {
+	// Feeds may legitimately shrink or be empty, so skip the size checks
+	out, err := converter.CreateAtomic(path, converter.OutputOptions{Force: true})
+	if err != nil {
+		return fmt.Errorf("failed to create feed file: %w", err)
+	}
+	defer out.Abort()
+
+	if err := write(out); err != nil {
+		return err
+	}
+	return out.Commit(0)
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/config"
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/manifest"
+	"github.com/dimchansky/lt-road-info/internal/split"
+)
+
+// stateFilename is the default name of the guard state file in the output directory
+const stateFilename = ".lt-road-info-state.json"
+
+// sourceResult is the outcome of downloading a single source
+type sourceResult struct {
+	source     source
+	outputPath string
+	unchanged  bool
+	err        error
+
+	// outputs lists every file of the source, including feeds
+	outputs []converter.Output
+
+	// splits lists the split outputs present after the run
+	splits []converter.Output
+
+	// inputs lists the files a conversion read
+	inputs []string
+
+	// The following are set when the outputs were written
+	fetched time.Time
+	stats   converter.Stats
+	counts  trackCounts
+}
+
+// runFetch implements the fetch subcommand and returns the exit code
+func runFetch(args []string) int {
+	fs := flag.NewFlagSet("fetch", flag.ExitOnError)
+	configPath, profile := configFlags(fs)
+	logOpts := logFlags(fs)
+	var (
+		outputDir    = fs.String("output", ".", "Output directory for GPX files, or - to write a single type to stdout")
+		dataType     = fs.String("type", "all", "Type of data to download: all, restrictions, speed-control")
+		cacheDir     = fs.String("cache-dir", "", "Directory for the HTTP cache; enables conditional requests and skips unchanged outputs")
+		backup       = fs.Bool("backup", false, "Keep the replaced GPX files as .bak")
+		force        = fs.Bool("force", false, "Replace existing GPX files even with an empty or much smaller dataset")
+		minCount     = fs.Int("min-features", 1, "Refuse to write a source with fewer upstream features than this (0 disables)")
+		maxDrop      = fs.Float64("max-drop", 50, "Refuse to write a source whose upstream feature count dropped by more than this percentage since the last successful run (0 disables)")
+		statePath    = fs.String("state", "", "File recording the last successful run per source (default <output>/"+stateFilename+")")
+		withManifest = fs.Bool("manifest", true, "Write "+manifest.Filename+" listing the generated files with checksums and counts")
+	)
+	road, splitKinds := roadFlags(fs)
+	vehicleSpec := vehicleFlag(fs)
+	fs.Usage = usage(fs,
+		"Download road information and write it as GPX and/or GeoJSON files.",
+		"fetch [flags]",
+		"Download all data to the current directory", "lt-road-info fetch",
+		"Download only road restrictions", "lt-road-info fetch -type restrictions",
+		"Download to a specific directory with verbose output", "lt-road-info fetch -output /path/to/gpx -verbose",
+		"Log JSON records, e.g. for a log collector", "lt-road-info fetch -output /path/to/gpx -log-format json",
+		"Stream speed control sections to another program", "lt-road-info fetch -type speed-control -output - | gzip > speed.gpx.gz",
+		"Only regenerate files when upstream data changed (prints \"unchanged\" otherwise)", "lt-road-info fetch -output /path/to/gpx -cache-dir ~/.cache/lt-road-info",
+		"Use the garmin profile of a configuration file", "lt-road-info fetch -config lt-road-info.yaml -profile garmin",
+		"Keep only the A1 and A6, with one file per road", "lt-road-info fetch -road A1,A6 -split road",
+		"Write the restrictions that concern a 40-tonne truck, given the limit icon codes", "LT_ROAD_INFO_ICON_CATALOG=limits.csv lt-road-info fetch -type restrictions -vehicle class=truck,weight=40,height=4 -output trucks",
+	)
+	fs.Parse(args)
+
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(*configPath, *profile)
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitUsage
+	}
+	var flagErr error
+	setFlags(fs, func(name string) {
+		switch name {
+		case "output":
+			cfg.OutputDir = *outputDir
+		case "cache-dir":
+			cfg.CacheDir = *cacheDir
+		case "backup":
+			cfg.Backup = *backup
+		case "force":
+			cfg.Force = *force
+		case "min-features":
+			cfg.Guard.MinFeatures = *minCount
+		case "max-drop":
+			cfg.Guard.MaxDrop = *maxDrop
+		case "state":
+			cfg.State = *statePath
+		case "manifest":
+			cfg.Manifest = *withManifest
+		case "road":
+			flagErr = errors.Join(flagErr, setRoads(cfg, *road))
+		case "split":
+			flagErr = errors.Join(flagErr, setSplit(cfg, *splitKinds))
+		case "vehicle":
+			flagErr = errors.Join(flagErr, setVehicle(cfg, *vehicleSpec))
+		}
+	})
+	if flagErr != nil {
+		slog.Error("Invalid arguments", logging.Err(flagErr))
+		return exitUsage
+	}
+	warnVehicle(cfg)
+
+	sources, err := selectSources(cfg, *dataType)
+	if err != nil {
+		slog.Error("Invalid arguments", logging.Err(err))
+		return exitUsage
+	}
+
+	var cache *data.Cache
+	if cfg.CacheDir != "" {
+		if cache, err = data.NewCache(cfg.CacheDir); err != nil {
+			slog.Error("Failed to open HTTP cache", "path", cfg.CacheDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+	httpClient := newHTTPClient(cfg.HTTP, nil)
+
+	if cfg.OutputDir == "-" {
+		if len(sources) != 1 {
+			slog.Error("Writing to stdout requires a single data type: -type restrictions or -type speed-control")
+			return exitFailure
+		}
+		src := sources[0]
+		sc, _ := cfg.Source(src.name)
+		tracks := sc.Apply(src.tracks(data.NewCachingClient(httpClient, cache), nil))
+		if err := writeTracks(os.Stdout, sc.Formats[0], src.documentTitle, tracks); err != nil {
+			slog.Error("Failed to download", "source", src.name, logging.Err(err))
+			return exitFailure
+		}
+		return 0
+	}
+
+	// Ensure output directory exists
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		slog.Error("Failed to create output directory", "path", cfg.OutputDir, logging.Err(err))
+		return exitFailure
+	}
+
+	if cfg.State == "" {
+		cfg.State = filepath.Join(cfg.OutputDir, stateFilename)
+	}
+	state, err := guard.LoadState(cfg.State)
+	if err != nil {
+		slog.Error("Failed to load state", "path", cfg.State, logging.Err(err))
+		return exitFailure
+	}
+
+	// The manifest of the last run lists the split files that may be removed
+	var previous *manifest.Manifest
+	if cfg.Manifest {
+		if previous, err = manifest.Load(filepath.Join(cfg.OutputDir, manifest.Filename)); err != nil {
+			slog.Error("Failed to load manifest", "path", cfg.OutputDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	d := &downloader{
+		config:     cfg,
+		manifest:   previous,
+		cache:      cache,
+		httpClient: httpClient,
+		opts:       converter.OutputOptions{Backup: cfg.Backup, Force: cfg.Force},
+		guard:      guard.New(guard.Thresholds{MinFeatures: cfg.Guard.MinFeatures, MaxDropPercent: cfg.Guard.MaxDrop}, state),
+	}
+	results := d.downloadAll(sources)
+
+	if err := state.Save(); err != nil {
+		slog.Error("Failed to save state", "path", cfg.State, logging.Err(err))
+	}
+	if cfg.Manifest {
+		if err := writeManifest(cfg.OutputDir, results); err != nil {
+			slog.Error("Failed to write manifest", "path", cfg.OutputDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	return reportResults(results)
+}
+
+// downloader holds the settings shared by all source downloads of a run
+type downloader struct {
+	config     *config.Config
+	cache      *data.Cache
+	httpClient *http.Client
+	opts       converter.OutputOptions
+	guard      *guard.Guard
+
+	// manifest is that of the last run, nil if manifests are disabled
+	manifest *manifest.Manifest
+}
+
+// downloadAll downloads every source concurrently. A failing source does not
+// cancel the others, so whatever succeeded is still written to outputDir.
+func (d *downloader) downloadAll(sources []source) []sourceResult {
+	results := make([]sourceResult, len(sources))
+
+	var wg sync.WaitGroup
+	for i, src := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = d.download(src)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (d *downloader) download(src source) sourceResult {
+	sc, _ := d.config.Source(src.name)
+	outputs := sc.Outputs(d.config.OutputDir)
+	outputPath := outputs[0].Path
+	slog.Info("Downloading", "source", src.name, "outputs", outputPaths(outputs))
+	start := time.Now()
+
+	result := sourceResult{
+		source:     src,
+		outputPath: outputPath,
+		outputs:    slices.Concat(outputs, feedOutputs(outputPath, sc.Feed)),
+	}
+
+	opts := d.opts
+	opts.Validate = d.guard.Validator(src.name, func() int { return result.stats.Features })
+
+	// Outputs of other settings are regenerated even if upstream is unchanged
+	config := sc.Fingerprint()
+	client := data.NewCachingClient(d.httpClient, d.cache)
+	tracks := src.tracks(client, &result.stats)
+	if outputsExist(outputs) && d.guard.Current(src.name, config) {
+		tracks = data.FailIfUnchanged(client, tracks)
+	}
+	tracks = result.counts.count(sc.Apply(tracks))
+
+	var written []converter.Track
+	if len(sc.Feed) > 0 || len(sc.Split) > 0 {
+		tracks = collect(tracks, &written)
+	}
+
+	err := converter.SaveTracks(tracks, src.documentTitle, outputs, opts)
+	switch {
+	case errors.Is(err, data.ErrUnchanged):
+		slog.Info("No changes, keeping previous output", "source", src.name, "path", outputPath)
+		result.unchanged = true
+		return result
+	case errors.Is(err, guard.ErrRejected):
+		slog.Warn("Kept previous output", "source", src.name, "path", outputPath, logging.Err(err))
+		result.err = err
+		return result
+	case errors.Is(err, converter.ErrSuspiciousOutput):
+		slog.Warn("Kept previous output, use -force to replace it anyway", "source", src.name, "path", outputPath, logging.Err(err))
+		result.err = err
+		return result
+	case err != nil:
+		slog.Error("Failed to download", "source", src.name, logging.Err(err))
+		result.err = err
+		return result
+	}
+
+	now := time.Now()
+	d.guard.Written(src.name, config, now)
+	result.fetched = start
+	slog.Info("Downloaded", "source", src.name, "outputs", outputPaths(outputs), "duration", now.Sub(start))
+
+	var previous []string
+	if d.manifest != nil {
+		previous = d.manifest.SplitFiles(d.config.OutputDir, src.name)
+	}
+	if len(sc.Split) > 0 || len(previous) > 0 {
+		files, err := split.Write(sc.Split, outputs, src.documentTitle, written, previous, d.opts)
+		if err != nil {
+			slog.Error("Failed to write split outputs", "source", src.name, logging.Err(err))
+		}
+		result.splits = files
+	}
+	if len(sc.Feed) > 0 {
+		if err := d.writeFeed(src, outputPath, sc.Feed, written, now); err != nil {
+			slog.Error("Failed to update feed", "source", src.name, logging.Err(err))
+		}
+	}
+	return result
+}
+
+// writeFeed updates the feed of src with the tracks just written
+func (d *downloader) writeFeed(src source, outputPath string, formats []string, tracks []converter.Track, t time.Time) error {
+	f, err := feed.Load(filepath.Join(d.config.OutputDir, feedStateFilename))
+	if err != nil {
+		return err
+	}
+	return feedWriter(f, src, outputPath, formats)(tracks, t)
+}
+
+// writeTracks writes tracks to w as a single document in format
+func writeTracks(w io.Writer, format, name string, tracks iter.Seq2[converter.Track, error]) error {
+	tw, err := converter.NewTrackWriter(format, w, name, time.Now())
+	if err != nil {
+		return err
+	}
+	for track, err := range tracks {
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteTrack(track); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// collect passes tracks through and appends them to dst as they go by
+func collect(tracks iter.Seq2[converter.Track, error], dst *[]converter.Track) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		for track, err := range tracks {
+			if err == nil {
+				*dst = append(*dst, track)
+			}
+			if !yield(track, err) {
+				return
+			}
+		}
+	}
+}
+
+// trackCounts counts the tracks and points of a stream
+type trackCounts struct {
+	tracks int
+	points int
+}
+
+// count passes tracks through, counting those without an error
+func (c *trackCounts) count(tracks iter.Seq2[converter.Track, error]) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		for track, err := range tracks {
+			if err == nil {
+				c.tracks++
+				for _, segment := range track.Segments {
+					c.points += len(segment)
+				}
+			}
+			if !yield(track, err) {
+				return
+			}
+		}
+	}
+}
+
+func outputsExist(outputs []converter.Output) bool {
+	for _, out := range outputs {
+		if _, err := os.Stat(out.Path); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func outputPaths(outputs []converter.Output) string {
+	paths := make([]string, len(outputs))
+	for i, out := range outputs {
+		paths[i] = out.Path
+	}
+	return strings.Join(paths, ", ")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/dimchansky/lt-road-info/internal/logging"
+)
+
+// logOptions holds the logging flags shared by all commands
+type logOptions struct {
+	verbose *bool
+	format  *string
+}
+
+// logFlags registers -verbose and -log-format on fs
+func logFlags(fs *flag.FlagSet) logOptions {
+	return logOptions{
+		verbose: fs.Bool("verbose", false, "Enable debug logging: upstream requests, pages, durations and written files"),
+		format:  fs.String("log-format", "", "Log format: text or json (default $LT_ROAD_INFO_LOG_FORMAT, then text)"),
+	}
+}
+
+// setup installs the logger selected by the flags as the slog default.
+// Logs go to stderr so that they never mix with output written to stdout.
+func (o logOptions) setup() error {
+	format := *o.format
+	if format == "" {
+		format = os.Getenv("LT_ROAD_INFO_LOG_FORMAT")
+	}
+	level := slog.LevelInfo
+	if *o.verbose {
+		level = slog.LevelDebug
+	}
+	logger, err := logging.New(os.Stderr, format, level)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+	return nil
+}
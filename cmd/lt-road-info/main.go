@@ -3,75 +3,128 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
+	"iter"
+	"log/slog"
 	"os"
-	"path/filepath"
+	"strings"
 
 	"github.com/dimchansky/lt-road-info/internal/arcgis"
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
 	"github.com/dimchansky/lt-road-info/internal/eismoinfo"
 )
 
-func main() {
-	var (
-		outputDir = flag.String("output", ".", "Output directory for GPX files")
-		dataType  = flag.String("type", "all", "Type of data to download: all, restrictions, speed-control")
-		verbose   = flag.Bool("verbose", false, "Enable verbose logging")
-		help      = flag.Bool("help", false, "Show help message")
-	)
-
-	flag.Parse()
+// Exit codes shared by all commands
+const (
+	exitFailure        = 1 // the command failed; for fetch, nothing was generated
+	exitUsage          = 2 // invalid command, flags or arguments
+	exitPartialFailure = 3 // some sources failed, the rest were written
+	exitCheckFailed    = 4 // verify found invalid data, diff -exit-code found differences
+)
 
-	if *help {
-		printHelp()
-		return
-	}
+// source describes one upstream dataset; its outputs are configured in
+// config.Source
+type source struct {
+	name          string
+	title         string
+	documentTitle string
+	url           string
+	tracks        func(client *data.Client, stats *converter.Stats) iter.Seq2[converter.Track, error]
+}
 
-	if *verbose {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+var (
+	restrictionsSource = source{
+		name:          "restrictions",
+		title:         "road restrictions",
+		documentTitle: converter.RestrictionsTitle,
+		url:           data.EALURL,
+		tracks:        eismoinfo.Tracks,
 	}
-
-	// Ensure output directory exists
-	if err := os.MkdirAll(*outputDir, 0755); err != nil {
-		log.Fatalf("Failed to create output directory: %v", err)
+	speedControlSource = source{
+		name:          "speed-control",
+		title:         "speed control sections",
+		documentTitle: converter.SpeedControlTitle,
+		url:           data.ArcGISLayerURL,
+		tracks:        arcgis.Tracks,
 	}
+)
 
-	switch *dataType {
-	case "all":
-		downloadAll(*outputDir)
-	case "restrictions":
-		downloadRestrictions(*outputDir)
-	case "speed-control":
-		downloadSpeedControl(*outputDir)
-	default:
-		log.Fatalf("Unknown data type: %s. Use 'all', 'restrictions', or 'speed-control'", *dataType)
-	}
+// command is a subcommand of lt-road-info
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
 }
 
-func downloadAll(outputDir string) {
-	downloadRestrictions(outputDir)
-	downloadSpeedControl(outputDir)
+// commands lists the subcommands in the order they are shown in the help
+var commands []command
+
+func init() {
+	commands = []command{
+		{"fetch", "Download road information and write GPX/GeoJSON files (default)", runFetch},
+		{"convert", "Convert saved upstream JSON to GPX/GeoJSON without network access", runConvert},
+		{"watch", "Regenerate files whenever upstream changes and run hooks", runWatch},
+		{"serve", "Serve continuously refreshed data, feeds and vector tiles over HTTP", runServe},
+		{"mbtiles", "Export vector tiles into an MBTiles file", runMBTiles},
+		{"diff", "List the tracks added and removed between two GPX/GeoJSON files", runDiff},
+		{"stats", "Summarize GPX/GeoJSON files or the live datasets", runStats},
+		{"roads", "Index restrictions and speed control sections by road number", runRoads},
+		{"verify", "Check the geometry of generated tracks or saved upstream data", runVerify},
+		{"transform", "Check the LKS-94 transformation against published control points (transform verify)", runTransform},
+		{"version", "Print version information", runVersion},
+		{"help", "Show help for a command", runHelp},
+	}
 }
 
-func downloadRestrictions(outputDir string) {
-	outputPath := filepath.Join(outputDir, "lt-road-restrictions.gpx")
-	log.Printf("Downloading road restrictions to %s...", outputPath)
+func main() {
+	args := os.Args[1:]
 
-	if err := eismoinfo.DownloadRestrictions(outputPath); err != nil {
-		log.Fatalf("Failed to download restrictions: %v", err)
+	// Without a command, or with flags only, behave as fetch so that
+	// existing scripts keep working
+	if len(args) == 0 || (strings.HasPrefix(args[0], "-") && !isHelpFlag(args[0])) {
+		os.Exit(runFetch(args))
+	}
+	if isHelpFlag(args[0]) {
+		printHelp()
+		return
 	}
 
-	log.Printf("Successfully downloaded road restrictions to %s", outputPath)
+	if cmd, ok := findCommand(args[0]); ok {
+		os.Exit(cmd.run(args[1:]))
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q. Run 'lt-road-info help' for the list of commands.\n", args[0])
+	os.Exit(exitUsage)
 }
 
-func downloadSpeedControl(outputDir string) {
-	outputPath := filepath.Join(outputDir, "lt-speed-control.gpx")
-	log.Printf("Downloading speed control sections to %s...", outputPath)
+func isHelpFlag(arg string) bool {
+	switch arg {
+	case "-h", "-help", "--help":
+		return true
+	}
+	return false
+}
 
-	if err := arcgis.DownloadSpeedControlSections(outputPath); err != nil {
-		log.Fatalf("Failed to download speed control sections: %v", err)
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
 	}
+	return command{}, false
+}
 
-	log.Printf("Successfully downloaded speed control sections to %s", outputPath)
+// runHelp implements the help subcommand
+func runHelp(args []string) int {
+	if len(args) == 0 {
+		printHelp()
+		return 0
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok || cmd.name == "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n", args[0])
+		return exitUsage
+	}
+	return cmd.run([]string{"-help"})
 }
 
 func printHelp() {
@@ -81,18 +134,89 @@ func printHelp() {
 	fmt.Println("and converts it to GPX format for use in navigation applications.")
 	fmt.Println()
 	fmt.Println("Usage:")
-	fmt.Println("  lt-road-info [flags]")
-	fmt.Println()
-	fmt.Println("Flags:")
-	flag.PrintDefaults()
+	fmt.Println("  lt-road-info <command> [flags] [arguments]")
 	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  # Download all data to current directory")
-	fmt.Println("  lt-road-info")
+	fmt.Println("Commands:")
+	for _, cmd := range commands {
+		fmt.Printf("  %-9s %s\n", cmd.name, cmd.summary)
+	}
 	fmt.Println()
-	fmt.Println("  # Download only road restrictions")
-	fmt.Println("  lt-road-info -type restrictions")
+	fmt.Println("Run 'lt-road-info help <command>' for the flags and examples of a command.")
+	fmt.Println("Flags given without a command are passed to fetch.")
 	fmt.Println()
-	fmt.Println("  # Download to specific directory with verbose output")
-	fmt.Println("  lt-road-info -output /path/to/gpx -verbose")
+	fmt.Println("Exit codes:")
+	fmt.Println("  0  success")
+	fmt.Println("  1  the command failed; fetch could not download anything")
+	fmt.Println("  2  invalid command, flags or arguments")
+	fmt.Println("  3  fetch: some sources failed; the others were still written")
+	fmt.Println("  4  verify found invalid coordinates; transform verify exceeded its error;")
+	fmt.Println("     diff -exit-code found differences")
+}
+
+// selectType returns the sources matching a -type flag value
+func selectType(dataType string) ([]source, error) {
+	switch dataType {
+	case "all":
+		return []source{restrictionsSource, speedControlSource}, nil
+	case restrictionsSource.name:
+		return []source{restrictionsSource}, nil
+	case speedControlSource.name:
+		return []source{speedControlSource}, nil
+	}
+	return nil, fmt.Errorf("unknown data type: %s. Use 'all', 'restrictions', or 'speed-control'", dataType)
+}
+
+// usage returns a FlagSet Usage function printing a description, the
+// synopsis, the flags and examples given as pairs of comment and command
+func usage(fs *flag.FlagSet, description, synopsis string, examples ...string) func() {
+	return func() {
+		out := fs.Output()
+		fmt.Fprintln(out, description)
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Usage:")
+		fmt.Fprintln(out, "  lt-road-info "+synopsis)
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Flags:")
+		fs.PrintDefaults()
+		if len(examples) > 0 {
+			fmt.Fprintln(out)
+			fmt.Fprintln(out, "Examples:")
+			for i := 0; i+1 < len(examples); i += 2 {
+				fmt.Fprintln(out, "  # "+examples[i])
+				fmt.Fprintln(out, "  "+examples[i+1])
+				fmt.Fprintln(out)
+			}
+		}
+	}
+}
+
+// reportResults logs failed sources and returns the process exit code:
+// 0 when all sources succeeded, exitPartialFailure when only some did and
+// exitFailure when none did. When every source was unchanged upstream it
+// prints "unchanged" to stdout so that scripts can skip publishing.
+func reportResults(results []sourceResult) int {
+	var failed []string
+	unchanged := 0
+	for _, r := range results {
+		switch {
+		case r.err != nil:
+			failed = append(failed, r.source.name)
+		case r.unchanged:
+			unchanged++
+		}
+	}
+
+	switch {
+	case unchanged == len(results):
+		fmt.Println("unchanged")
+		return 0
+	case len(failed) == 0:
+		return 0
+	case len(failed) == len(results):
+		slog.Error("All sources failed", "failed", strings.Join(failed, ","))
+		return exitFailure
+	default:
+		slog.Error("Partial failure", "failed", strings.Join(failed, ","))
+		return exitPartialFailure
+	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/manifest"
+)
+
+// writeManifest records the files of results in the manifest of dir.
+// Sources that were not written in this run, including those not run at
+// all, keep their counts from the previous manifest, while their files are
+// hashed again as they are on disk now.
+func writeManifest(dir string, results []sourceResult) error {
+	path := filepath.Join(dir, manifest.Filename)
+	previous, err := manifest.Load(path)
+	if err != nil {
+		return err
+	}
+
+	v, revision := buildVersion()
+	m := &manifest.Manifest{Version: v, Commit: revision, Generated: time.Now().UTC()}
+
+	var ran []string
+	for _, r := range results {
+		ran = append(ran, r.source.name)
+
+		entry := manifest.Source{Name: r.source.name, Inputs: r.inputs}
+		if len(r.inputs) == 0 {
+			entry.URL = r.source.url
+		}
+		switch {
+		case r.err != nil:
+			entry.Status = manifest.StatusFailed
+			entry.Error = r.err.Error()
+		case r.unchanged:
+			entry.Status = manifest.StatusUnchanged
+		default:
+			entry.Status = manifest.StatusWritten
+		}
+
+		if entry.Status == manifest.StatusWritten {
+			fetched := r.fetched.UTC()
+			entry.Fetched = &fetched
+			entry.Features = r.stats.Features
+			entry.Tracks = r.counts.tracks
+			entry.Points = r.counts.points
+			entry.Warnings = manifest.Warnings(r.stats)
+		} else if prev, ok := previous.Source(r.source.name); ok {
+			entry.Fetched = prev.Fetched
+			entry.Features, entry.Tracks, entry.Points = prev.Features, prev.Tracks, prev.Points
+			entry.Warnings = prev.Warnings
+		}
+		m.Sources = append(m.Sources, entry)
+
+		for _, out := range r.outputs {
+			if err := addExisting(m, dir, out.Path, r.source.name, out.Format); err != nil {
+				return err
+			}
+		}
+
+		// Split files stay listed until a written run replaces them, so
+		// that only files listed here are ever removed
+		splits := r.splits
+		if entry.Status != manifest.StatusWritten {
+			splits = nil
+			for _, f := range previous.Files {
+				if f.Split && f.Source == r.source.name {
+					splits = append(splits, converter.Output{Path: filepath.Join(dir, filepath.FromSlash(f.Path)), Format: f.Format})
+				}
+			}
+		}
+		for _, out := range splits {
+			if err := addExistingSplit(m, dir, out.Path, r.source.name, out.Format); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, prev := range previous.Sources {
+		if slices.Contains(ran, prev.Name) {
+			continue
+		}
+		m.Sources = append(m.Sources, prev)
+		for _, f := range previous.Files {
+			if f.Source != prev.Name {
+				continue
+			}
+			add := addExisting
+			if f.Split {
+				add = addExistingSplit
+			}
+			if err := add(m, dir, filepath.Join(dir, filepath.FromSlash(f.Path)), f.Source, f.Format); err != nil {
+				return err
+			}
+		}
+	}
+
+	return m.Save(path)
+}
+
+// addExisting adds the file at path to m unless it does not exist, as for
+// a source that never succeeded
+func addExisting(m *manifest.Manifest, dir, path, source, format string) error {
+	err := m.AddFile(dir, path, source, format)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// addExistingSplit is addExisting for a file of a split output
+func addExistingSplit(m *manifest.Manifest, dir, path, source, format string) error {
+	err := m.AddSplitFile(dir, path, source, format)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
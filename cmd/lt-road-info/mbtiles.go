@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/tiles"
+)
+
+// runMBTiles implements the mbtiles subcommand and returns the exit code
+func runMBTiles(args []string) int {
+	fs := flag.NewFlagSet("mbtiles", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	var (
+		output   = fs.String("output", "lt-road-info.mbtiles", "MBTiles file to write")
+		dataType = fs.String("type", "all", "Type of data to include: all, restrictions, speed-control")
+		minZoom  = fs.Int("min-zoom", 5, "Lowest zoom level to generate")
+		maxZoom  = fs.Int("max-zoom", 14, "Highest zoom level to generate")
+		cacheDir = fs.String("cache-dir", "", "Directory for the HTTP cache used for upstream requests")
+	)
+	fs.Usage = usage(fs,
+		"Export road information as vector tiles in a single MBTiles file.\nEvery data type becomes one layer named after it.",
+		"mbtiles [flags]",
+		"Export both layers for zoom levels 5 to 14", "lt-road-info mbtiles -output lt-road-info.mbtiles",
+		"Export restrictions only, down to street level", "lt-road-info mbtiles -type restrictions -max-zoom 16",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if *minZoom < 0 || *minZoom > *maxZoom || *maxZoom > 22 {
+		slog.Error("Invalid zoom range", "min_zoom", *minZoom, "max_zoom", *maxZoom)
+		return exitUsage
+	}
+
+	sources, err := selectType(*dataType)
+	if err != nil {
+		slog.Error("Invalid arguments", logging.Err(err))
+		return exitUsage
+	}
+
+	var cache *data.Cache
+	if *cacheDir != "" {
+		if cache, err = data.NewCache(*cacheDir); err != nil {
+			slog.Error("Failed to open HTTP cache", "path", *cacheDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	var layers []tiles.Layer
+	for _, src := range sources {
+		slog.Info("Downloading", "source", src.name)
+		var tracks []converter.Track
+		for track, err := range src.tracks(data.NewCachingClient(nil, cache), nil) {
+			if err != nil {
+				slog.Error("Failed to download", "source", src.name, logging.Err(err))
+				return exitFailure
+			}
+			tracks = append(tracks, track)
+		}
+		layers = append(layers, tiles.Layer{Name: src.name, Tracks: tracks})
+	}
+
+	meta := tiles.Metadata{
+		Name:        "Lithuanian Road Information",
+		Description: "Road restrictions and average speed control sections in Lithuania",
+		Attribution: "eismoinfo.lt, gis.ktvis.lt",
+	}
+	if err := tiles.WriteMBTiles(*output, tiles.NewIndex(layers, *maxZoom), meta, *minZoom); err != nil {
+		slog.Error("Failed to write tiles", "path", *output, logging.Err(err))
+		return exitFailure
+	}
+
+	slog.Info("Wrote tiles", "path", *output, "min_zoom", *minZoom, "max_zoom", *maxZoom)
+	return 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/roads"
+)
+
+// runRoads implements the roads subcommand and returns the exit code
+func runRoads(args []string) int {
+	fs := flag.NewFlagSet("roads", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	var (
+		dataType = fs.String("type", "all", "Type of live data to index when no files are given: all, restrictions, speed-control")
+		cacheDir = fs.String("cache-dir", "", "Directory for the HTTP cache used for upstream requests")
+		road     = fs.String("road", "", "List the restrictions and sections of these roads only, e.g. A1,A6")
+		asJSON   = fs.Bool("json", false, "Print the index as JSON, including every restriction and section")
+	)
+	fs.Usage = usage(fs,
+		`Index restrictions and speed control sections by road number: magistral
+roads A1 to A19, national roads KK101 and up and four-digit regional roads.
+Speed control sections carry their road number; for restrictions it is taken
+from the name, so restrictions whose name does not mention the road are only
+counted as unknown. The data comes from GPX or GeoJSON files, or else from
+upstream.`,
+		"roads [flags] [file...]",
+		"List the roads with restrictions or speed control sections", "lt-road-info roads",
+		"Show everything on the A1 and A6 in the generated files", "lt-road-info roads -road A1,A6 lt-road-restrictions.geojson lt-speed-control.geojson",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	selected, err := roads.Parse(*road)
+	if err != nil {
+		slog.Error("Invalid arguments", logging.Err(err))
+		return exitUsage
+	}
+
+	index := roads.NewIndex()
+	if fs.NArg() > 0 {
+		for _, path := range fs.Args() {
+			tracks, err := converter.ReadFile(path)
+			if err != nil {
+				slog.Error("Failed to read tracks", "path", path, logging.Err(err))
+				return exitFailure
+			}
+			for _, track := range tracks {
+				index.Add(trackKind(track), track)
+			}
+		}
+	} else {
+		sources, err := selectType(*dataType)
+		if err != nil {
+			slog.Error("Invalid arguments", logging.Err(err))
+			return exitUsage
+		}
+		var cache *data.Cache
+		if *cacheDir != "" {
+			if cache, err = data.NewCache(*cacheDir); err != nil {
+				slog.Error("Failed to open HTTP cache", "path", *cacheDir, logging.Err(err))
+				return exitFailure
+			}
+		}
+		for _, src := range sources {
+			kind := roads.KindRestriction
+			if src.name == speedControlSource.name {
+				kind = roads.KindSection
+			}
+			for track, err := range src.tracks(data.NewCachingClient(nil, cache), nil) {
+				if err != nil {
+					slog.Error("Failed to download", "source", src.name, logging.Err(err))
+					return exitFailure
+				}
+				index.Add(kind, track)
+			}
+		}
+	}
+
+	list := index.Roads()
+	if len(selected) > 0 {
+		list = slices.DeleteFunc(list, func(r *roads.Road) bool {
+			return !slices.Contains(selected, r.Number)
+		})
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(list); err != nil {
+			slog.Error("Failed to write index", logging.Err(err))
+			return exitFailure
+		}
+		return 0
+	}
+
+	fmt.Printf("%-7s %12s %9s %10s\n", "ROAD", "RESTRICTIONS", "SECTIONS", "LENGTH")
+	for _, r := range list {
+		fmt.Printf("%-7s %12d %9d %7.1f km\n", r.Number, r.Restrictions, r.Sections, r.LengthKm)
+		if len(selected) == 0 {
+			continue
+		}
+		for _, e := range r.Entries {
+			fmt.Printf("          %-11s %s\n", e.Kind, e.Name)
+		}
+	}
+	if len(selected) == 0 && index.Unknown() > 0 {
+		fmt.Printf("\nTracks not naming their road: %d\n", index.Unknown())
+	}
+	return 0
+}
+
+// trackKind tells speed control sections from restrictions in files, by
+// the name every section track is given
+func trackKind(track converter.Track) string {
+	if strings.HasPrefix(track.Name, "Speed Control Section") {
+		return roads.KindSection
+	}
+	return roads.KindRestriction
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/metrics"
+	"github.com/dimchansky/lt-road-info/internal/server"
+)
+
+// runServe implements the serve subcommand and returns the exit code
+func runServe(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	configPath, profile := configFlags(fs)
+	logOpts := logFlags(fs)
+	var (
+		listen   = fs.String("listen", ":8080", "Address to listen on")
+		interval = fs.Duration("interval", 15*time.Minute, "How often to refresh upstream data")
+		cacheDir = fs.String("cache-dir", "", "Directory for the HTTP cache used for upstream requests")
+		minCount = fs.Int("min-features", 1, "Reject refreshes with fewer features than this (0 disables)")
+		maxDrop  = fs.Float64("max-drop", 50, "Reject refreshes whose feature count dropped by more than this percentage (0 disables)")
+		feedPath = fs.String("feed-state", "", "File keeping the restrictions feed history across restarts (default in memory)")
+	)
+	fs.Usage = usage(fs,
+		`Serve continuously refreshed road information over HTTP.
+
+Endpoints:
+  /restrictions.gpx, /restrictions.geojson
+  /speed-control.gpx, /speed-control.geojson
+      ?bbox=minLon,minLat,maxLon,maxLat limits the output to intersecting tracks
+  /restrictions.atom, /restrictions.rss   added and changed restrictions
+  /tiles/{z}/{x}/{y}.pbf   vector tiles with one layer per source
+  /healthz   last refresh time and error per source
+  /metrics   Prometheus metrics of upstream requests and refreshes`,
+		"serve [flags]",
+		"Serve on port 8080, refreshing every 15 minutes", "lt-road-info serve",
+		"Refresh hourly and keep the feed history across restarts", "lt-road-info serve -listen :9000 -interval 1h -feed-state /var/lib/lt-road-info/feed.json",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if *interval <= 0 {
+		slog.Error("Refresh interval must be positive", "interval", *interval)
+		return exitUsage
+	}
+
+	cfg, err := loadConfig(*configPath, *profile)
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitUsage
+	}
+	setFlags(fs, func(name string) {
+		switch name {
+		case "cache-dir":
+			cfg.CacheDir = *cacheDir
+		case "min-features":
+			cfg.Guard.MinFeatures = *minCount
+		case "max-drop":
+			cfg.Guard.MaxDrop = *maxDrop
+		}
+	})
+	selected, err := selectSources(cfg, "all")
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitUsage
+	}
+
+	var cache *data.Cache
+	if cfg.CacheDir != "" {
+		if cache, err = data.NewCache(cfg.CacheDir); err != nil {
+			slog.Error("Failed to open HTTP cache", "path", cfg.CacheDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+	m := metrics.New()
+	httpClient := newHTTPClient(cfg.HTTP, m.Transport(nil))
+
+	restrictionsFeed, err := feed.Load(*feedPath)
+	if err != nil {
+		slog.Error("Failed to load feed", "path", *feedPath, logging.Err(err))
+		return exitFailure
+	}
+
+	var sources []server.Source
+	for _, src := range selected {
+		sc, _ := cfg.Source(src.name)
+		s := server.Source{
+			Name:  src.name,
+			Title: src.documentTitle,
+			Fetch: func(stats *converter.Stats) iter.Seq2[converter.Track, error] {
+				client := data.NewCachingClient(httpClient, cache)
+				return sc.Apply(m.Tracks(src.name, client, stats, src.tracks(client, stats)))
+			},
+		}
+		if src.name == restrictionsSource.name {
+			s.Feed = restrictionsFeed
+		}
+		sources = append(sources, s)
+	}
+	srv := server.New(sources, guard.Thresholds{MinFeatures: cfg.Guard.MinFeatures, MaxDropPercent: cfg.Guard.MaxDrop}, m)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go srv.Run(ctx, *interval)
+
+	httpServer := &http.Server{
+		Addr:              *listen,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		httpServer.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("Serving", "listen", *listen, "interval", *interval)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		slog.Error("Server failed", logging.Err(err))
+		return exitFailure
+	}
+	return 0
+}
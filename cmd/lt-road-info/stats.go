@@ -0,0 +1,152 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+)
+
+// datasetStats summarizes the tracks of one dataset
+type datasetStats struct {
+	Name     string         `json:"name"`
+	Tracks   int            `json:"tracks"`
+	Segments int            `json:"segments"`
+	Points   int            `json:"points"`
+	LengthKm float64        `json:"lengthKm"`
+	BBox     *[4]float64    `json:"bbox,omitempty"`
+	Types    map[string]int `json:"types,omitempty"`
+}
+
+func newDatasetStats(name string, tracks []converter.Track) datasetStats {
+	s := datasetStats{Name: name, Tracks: len(tracks), Types: make(map[string]int)}
+	var length float64
+	for _, track := range tracks {
+		s.Segments += len(track.Segments)
+		for _, segment := range track.Segments {
+			s.Points += len(segment)
+		}
+		length += track.Length()
+		if t, ok := track.Properties["type"].(string); ok && t != "" {
+			s.Types[t]++
+		}
+
+		if len(track.Segments) == 0 {
+			continue
+		}
+		b := track.Bounds()
+		if s.BBox == nil {
+			s.BBox = &[4]float64{b.MinLon, b.MinLat, b.MaxLon, b.MaxLat}
+			continue
+		}
+		s.BBox[0], s.BBox[1] = min(s.BBox[0], b.MinLon), min(s.BBox[1], b.MinLat)
+		s.BBox[2], s.BBox[3] = max(s.BBox[2], b.MaxLon), max(s.BBox[3], b.MaxLat)
+	}
+	s.LengthKm = length / 1000
+	return s
+}
+
+func (s datasetStats) print() {
+	fmt.Println(s.Name)
+	fmt.Printf("  tracks:   %d\n", s.Tracks)
+	fmt.Printf("  segments: %d\n", s.Segments)
+	fmt.Printf("  points:   %d\n", s.Points)
+	fmt.Printf("  length:   %.1f km\n", s.LengthKm)
+	if s.BBox != nil {
+		fmt.Printf("  bbox:     %.5f,%.5f,%.5f,%.5f\n", s.BBox[0], s.BBox[1], s.BBox[2], s.BBox[3])
+	}
+	if len(s.Types) > 0 {
+		fmt.Println("  types:")
+		types := slices.SortedFunc(maps.Keys(s.Types), func(a, b string) int {
+			return cmp.Or(cmp.Compare(s.Types[b], s.Types[a]), cmp.Compare(a, b))
+		})
+		for _, t := range types {
+			fmt.Printf("    %6d  %s\n", s.Types[t], t)
+		}
+	}
+}
+
+// runStats implements the stats subcommand and returns the exit code
+func runStats(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	var (
+		dataType = fs.String("type", "all", "Type of live data to summarize when no files are given: all, restrictions, speed-control")
+		cacheDir = fs.String("cache-dir", "", "Directory for the HTTP cache used for upstream requests")
+		asJSON   = fs.Bool("json", false, "Print the statistics as JSON")
+	)
+	fs.Usage = usage(fs,
+		`Summarize GPX or GeoJSON files: number of tracks, segments and points,
+total length, bounding box and, for GeoJSON restrictions, the count per type.
+Without files the live datasets are downloaded and summarized.`,
+		"stats [flags] [file...]",
+		"Summarize the generated files", "lt-road-info stats lt-road-restrictions.gpx lt-speed-control.gpx",
+		"Summarize the live restrictions as JSON", "lt-road-info stats -type restrictions -json",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	var stats []datasetStats
+	if fs.NArg() > 0 {
+		for _, path := range fs.Args() {
+			tracks, err := converter.ReadFile(path)
+			if err != nil {
+				slog.Error("Failed to read tracks", "path", path, logging.Err(err))
+				return exitFailure
+			}
+			stats = append(stats, newDatasetStats(path, tracks))
+		}
+	} else {
+		sources, err := selectType(*dataType)
+		if err != nil {
+			slog.Error("Invalid arguments", logging.Err(err))
+			return exitUsage
+		}
+		var cache *data.Cache
+		if *cacheDir != "" {
+			if cache, err = data.NewCache(*cacheDir); err != nil {
+				slog.Error("Failed to open HTTP cache", "path", *cacheDir, logging.Err(err))
+				return exitFailure
+			}
+		}
+		for _, src := range sources {
+			var tracks []converter.Track
+			for track, err := range src.tracks(data.NewCachingClient(nil, cache), nil) {
+				if err != nil {
+					slog.Error("Failed to download", "source", src.name, logging.Err(err))
+					return exitFailure
+				}
+				tracks = append(tracks, track)
+			}
+			stats = append(stats, newDatasetStats(src.name, tracks))
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(stats); err != nil {
+			slog.Error("Failed to write statistics", logging.Err(err))
+			return exitFailure
+		}
+		return 0
+	}
+	for i, s := range stats {
+		if i > 0 {
+			fmt.Println()
+		}
+		s.print()
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/transform"
+)
+
+// runTransform implements the transform subcommand and returns the exit code
+func runTransform(args []string) int {
+	// verify is the only transform command, so its help is the command's
+	if len(args) > 0 && isHelpFlag(args[0]) {
+		args = []string{"verify", "-help"}
+	}
+	if len(args) == 0 || args[0] != "verify" {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "Unknown transform command %q\n", args[0])
+		}
+		fmt.Fprintln(os.Stderr, "Usage:\n  lt-road-info transform verify [flags]\n\nRun 'lt-road-info transform verify -help' for the flags.")
+		return exitUsage
+	}
+
+	fs := flag.NewFlagSet("transform verify", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	var (
+		pointsPath = fs.String("points", "", "CSV table of control points (name,source,easting,northing,lat,lon), required")
+		maxError   = fs.Float64("max-error", transform.ToleranceMM, "Largest accepted error at a reference point in millimetres")
+		list       = fs.Bool("list", false, "Print the error at every point")
+	)
+	fs.Usage = usage(fs,
+		`Check the LKS-94 to WGS84 transformation over control points with
+published LKS-94 and ETRS89 coordinates, given with -points, and report the
+max and RMS error in millimetres. No control points are built in yet; see
+docs/transform.md. Exits with code 4 when the max error exceeds -max-error.`,
+		"transform verify [flags]",
+		"Measure the accuracy over published control points", "lt-road-info transform verify -points control-points.csv -list",
+	)
+	fs.Parse(args[1:])
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if *pointsPath == "" {
+		slog.Error("transform verify needs -points: no official control points are built in yet, see docs/transform.md")
+		return exitUsage
+	}
+	f, err := os.Open(*pointsPath)
+	var points []transform.ReferencePoint
+	if err == nil {
+		points, err = transform.ReadReferencePoints(f)
+		f.Close()
+	}
+	if err != nil {
+		slog.Error("Failed to load reference points", "path", *pointsPath, logging.Err(err))
+		return exitFailure
+	}
+	if len(points) == 0 {
+		slog.Error("No reference points", "path", *pointsPath)
+		return exitFailure
+	}
+
+	sources := make(map[string]int)
+	computed := 0
+	for _, p := range points {
+		sources[p.Source]++
+		if p.Computed() {
+			computed++
+		}
+		if *list {
+			acc := transform.MeasureAccuracy([]transform.ReferencePoint{p})
+			fmt.Printf("%-28s %-10s %8.3f mm\n", p.Name, p.Source, acc.MaxMM)
+		}
+	}
+	var counts []string
+	for source, n := range sources {
+		counts = append(counts, fmt.Sprintf("%s: %d", source, n))
+	}
+	slices.Sort(counts)
+
+	acc := transform.MeasureAccuracy(points)
+	fmt.Printf("📐 LKS-94 → WGS84 error over %d reference points (%s)\n", acc.Points, strings.Join(counts, ", "))
+	fmt.Printf("   max %.3f mm at %s\n", acc.MaxMM, acc.Worst.Name)
+	fmt.Printf("   RMS %.3f mm\n", acc.RMSMM)
+	if computed == len(points) {
+		fmt.Println("⚠️  Computed points only: this checks the implementation, not the projection parameters")
+	}
+	if acc.MaxMM > *maxError {
+		fmt.Printf("❌ Max error exceeds %g mm\n", *maxError)
+		return exitCheckFailed
+	}
+	fmt.Printf("✅ Within %g mm\n", *maxError)
+	return 0
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/validate"
+)
+
+// runVerify implements the verify subcommand and returns the exit code
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	logOpts := logFlags(fs)
+	defaults := validate.DefaultOptions()
+	var (
+		last          string
+		eal           = &fileFlag{name: "eal", last: &last}
+		arcgis        = &fileFlag{name: "arcgis", last: &last}
+		dataType      = fs.String("type", "all", "Type of live data to verify when no files are given: all, restrictions, speed-control")
+		minPercent    = fs.Float64("min-percent", defaults.MinInside, "Minimum percentage of coordinates that must lie in Lithuania")
+		maxJump       = fs.Float64("max-jump", defaults.MaxJump/1000, "Longest allowed distance between consecutive vertices in km, 0 disables the check")
+		borderBuffer  = fs.Float64("border-buffer", defaults.BorderBuffer/1000, "Distance in km outside the border at which a coordinate still counts as in Lithuania")
+		strict        = fs.Bool("strict", false, "Fail on warnings too, such as duplicate segments or self-intersections")
+		positionsPath = fs.String("positions", "", "JSON table of expected vertex positions, e.g. testdata/known_positions.json")
+		tolerance     = fs.Float64("tolerance", 1, "Largest distance in metres between a vertex and its expected position")
+		cassettePath  = fs.String("cassette", "", "Replay upstream responses from a cassette instead of the network")
+		recordPath    = fs.String("record", "", "Record the live upstream responses into a cassette")
+		reportPath    = fs.String("report", "", "Write the results as JSON to this file")
+		junitPath     = fs.String("junit", "", "Write the results as JUnit XML to this file")
+		cacheDir      = fs.String("cache-dir", "", "Directory for the HTTP cache used for upstream requests")
+	)
+	fs.Var(eal, "eal", "Saved EAL response (eismoinfo.lt, ?lks=true) to verify instead of live data (repeatable, globs allowed)")
+	fs.Var(arcgis, "arcgis", "Saved ArcGIS query response in LKS94 to verify instead of live data (repeatable, globs allowed)")
+	fs.Usage = usage(fs,
+		`Check the geometry of the generated tracks: coordinates must lie in Lithuania
+and be finite numbers, consecutive vertices must not jump further than
+-max-jump, and swapped axes (the lat/lon mixup that moved tracks to Abu Dhabi)
+are detected. Repeated vertices, duplicate segments, self-intersections and
+upstream coordinates with fewer than two values are reported as warnings.
+Every issue names the restriction ID or ArcGIS object ID of its feature.
+
+The data comes from GPX or GeoJSON files given as arguments, from saved
+upstream JSON (-eal, -arcgis), or else from upstream, live or replayed from a
+cassette. Upstream JSON is checked raw, converted to GPX and GeoJSON and read
+back, testing the complete pipeline. With -positions, vertices are also
+compared with expected positions. Exits with code 4 when validation fails.`,
+		"verify [flags] [file...]",
+		"Verify the live datasets end to end", "lt-road-info verify",
+		"Verify generated files before publishing them", "lt-road-info verify lt-road-restrictions.geojson lt-speed-control.gpx",
+		"Gate CI offline on fixtures with known positions", "lt-road-info verify -eal testdata/eal_known_coords.json -arcgis testdata/arcgis_known_coords.json -positions testdata/known_positions.json -junit verify.xml",
+		"Record the live responses once, then replay them", "lt-road-info verify -record cassette.json && lt-road-info verify -cassette cassette.json",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+	opts := validate.Options{MaxJump: *maxJump * 1000, BorderBuffer: *borderBuffer * 1000, MinInside: *minPercent}
+
+	var positions []validate.Position
+	if *positionsPath != "" {
+		var err error
+		if positions, err = validate.LoadPositions(*positionsPath); err != nil {
+			slog.Error("Failed to load positions", "path", *positionsPath, logging.Err(err))
+			return exitUsage
+		}
+	}
+
+	// Upstream JSON to be checked raw and converted, from files or upstream
+	type input struct {
+		source source
+		tracks func(v *validate.Validator) iter.Seq2[converter.Track, error]
+	}
+	var inputs []input
+	if len(eal.files) > 0 {
+		inputs = append(inputs, input{restrictionsSource, func(v *validate.Validator) iter.Seq2[converter.Track, error] {
+			return converter.EALTracks(v.EALFeatures(data.EALFeaturesFromFiles(eal.files)))
+		}})
+	}
+	if len(arcgis.files) > 0 {
+		inputs = append(inputs, input{speedControlSource, func(v *validate.Validator) iter.Seq2[converter.Track, error] {
+			return converter.ArcGISTracks(v.ArcGISFeatures(data.ArcGISFeaturesFromFiles(arcgis.files)))
+		}})
+	}
+
+	var recorder *data.Recorder
+	if len(inputs) == 0 && fs.NArg() == 0 {
+		sources, err := selectType(*dataType)
+		if err != nil {
+			slog.Error("Invalid arguments", logging.Err(err))
+			return exitUsage
+		}
+
+		httpClient := http.DefaultClient
+		switch {
+		case *cassettePath != "" && *recordPath != "":
+			slog.Error("Invalid arguments", logging.Err(fmt.Errorf("-cassette and -record cannot be combined")))
+			return exitUsage
+		case *cassettePath != "":
+			cassette, err := data.LoadCassette(*cassettePath)
+			if err != nil {
+				slog.Error("Failed to load cassette", "path", *cassettePath, logging.Err(err))
+				return exitFailure
+			}
+			httpClient = &http.Client{Transport: cassette}
+		case *recordPath != "":
+			recorder = &data.Recorder{}
+			httpClient = &http.Client{Transport: recorder}
+		}
+		var cache *data.Cache
+		if *cacheDir != "" && *cassettePath == "" && *recordPath == "" {
+			if cache, err = data.NewCache(*cacheDir); err != nil {
+				slog.Error("Failed to open HTTP cache", "path", *cacheDir, logging.Err(err))
+				return exitFailure
+			}
+		}
+
+		for _, src := range sources {
+			client := data.NewCachingClient(httpClient, cache)
+			inputs = append(inputs, input{src, func(v *validate.Validator) iter.Seq2[converter.Track, error] {
+				return validatedTracks(src, client, v)
+			}})
+		}
+	}
+
+	fmt.Println("🔍 Verifying coordinate transformations...")
+
+	// A dataset without a path failed to convert and only has its report
+	type dataset struct {
+		name      string
+		path      string
+		validator *validate.Validator
+	}
+	var datasets []dataset
+
+	// failure is the exit code of a failed step, returned once the reports
+	// are written
+	failure := 0
+	fail := func(code int) {
+		if failure == 0 {
+			failure = code
+		}
+	}
+	for _, path := range fs.Args() {
+		datasets = append(datasets, dataset{name: filepath.Base(path), path: path, validator: validate.New(filepath.Base(path), opts)})
+	}
+
+	if len(inputs) > 0 {
+		tmpDir, err := os.MkdirTemp("", "lt-road-verify")
+		if err != nil {
+			slog.Error("Failed to create temporary directory", logging.Err(err))
+			return exitFailure
+		}
+		defer os.RemoveAll(tmpDir)
+
+		for _, in := range inputs {
+			fmt.Printf("\n📥 Converting %s...\n", in.source.title)
+			v := validate.New(in.source.title, opts)
+			gpxPath := filepath.Join(tmpDir, in.source.name+".gpx")
+			geojsonPath := filepath.Join(tmpDir, in.source.name+".geojson")
+			outputs := []converter.Output{
+				{Path: gpxPath, Format: converter.FormatGPX},
+				{Path: geojsonPath, Format: converter.FormatGeoJSON},
+			}
+			if err := converter.SaveTracks(in.tracks(v), in.source.documentTitle, outputs, converter.OutputOptions{Force: true}); err != nil {
+				fmt.Printf("❌ Failed to convert %s: %v\n", in.source.title, err)
+				v.Fail(validate.CheckConversion, err)
+				datasets = append(datasets, dataset{name: in.source.title, validator: v})
+				fail(exitFailure)
+				continue
+			}
+			if err := compareGPX(gpxPath, geojsonPath); err != nil {
+				fmt.Printf("❌ %s: %v\n", in.source.title, err)
+				v.Fail(validate.CheckRoundTrip, err)
+				fail(exitCheckFailed)
+			}
+			// GeoJSON keeps the feature IDs that GPX drops
+			datasets = append(datasets, dataset{name: in.source.title, path: geojsonPath, validator: v})
+		}
+	}
+
+	if recorder != nil {
+		if err := recorder.Save(*recordPath); err != nil {
+			slog.Error("Failed to save cassette", "path", *recordPath, logging.Err(err))
+			return exitFailure
+		}
+		fmt.Printf("\n📼 Recorded the upstream responses to %s\n", *recordPath)
+	}
+
+	var reports []*validate.Report
+	var all []converter.Track
+	for _, ds := range datasets {
+		if ds.path == "" {
+			reports = append(reports, ds.validator.Report())
+			continue
+		}
+		fmt.Printf("\n📍 Checking %s...\n", ds.name)
+		tracks, err := converter.ReadFile(ds.path)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			ds.validator.Fail(validate.CheckConversion, err)
+			reports = append(reports, ds.validator.Report())
+			fail(exitFailure)
+			continue
+		}
+		for _, track := range tracks {
+			ds.validator.Track(track)
+		}
+		r := ds.validator.Report()
+		fmt.Printf("   📊 %s: %d tracks, %d/%d coordinates in Lithuania (%.1f%%)\n",
+			ds.name, r.Tracks, r.Inside, r.Points, r.InsidePercent())
+		printReport(r, *strict)
+		reports = append(reports, r)
+		all = append(all, tracks...)
+	}
+
+	if len(positions) > 0 {
+		fmt.Printf("\n📍 Checking known positions...\n")
+		r := validate.CheckPositions("positions", positions, all, *tolerance)
+		fmt.Printf("   📊 %d/%d positions within %g m\n", r.Inside, r.Points, *tolerance)
+		printReport(r, *strict)
+		reports = append(reports, r)
+	}
+
+	if err := writeReports(reports, *reportPath, *junitPath, *strict); err != nil {
+		slog.Error("Failed to write report", logging.Err(err))
+		return exitFailure
+	}
+
+	if failure != 0 {
+		fmt.Println("\n❌ Verification failed")
+		return failure
+	}
+	for _, r := range reports {
+		if !r.Passed(*strict) {
+			fmt.Println("\n❌ Coordinate validation failed")
+			return exitCheckFailed
+		}
+	}
+	fmt.Println("\n✅ All coordinate transformations are correct!")
+	fmt.Println("🇱🇹 All tracks are properly located in Lithuania")
+	return 0
+}
+
+// validatedTracks streams the tracks of src, checking the raw upstream
+// coordinates with v before they are converted
+func validatedTracks(src source, client *data.Client, v *validate.Validator) iter.Seq2[converter.Track, error] {
+	if src.name == restrictionsSource.name {
+		return converter.EALTracks(v.EALFeatures(client.EALFeatures()))
+	}
+	return converter.ArcGISTracks(v.ArcGISFeatures(client.ArcGISFeatures()))
+}
+
+// compareGPX checks that the GPX and GeoJSON written from the same tracks
+// read back with the same number of tracks and points
+func compareGPX(gpxPath, geojsonPath string) error {
+	gpxTracks, err := converter.ReadFile(gpxPath)
+	if err != nil {
+		return err
+	}
+	geojsonTracks, err := converter.ReadFile(geojsonPath)
+	if err != nil {
+		return err
+	}
+	count := func(tracks []converter.Track) (points int) {
+		for _, track := range tracks {
+			for _, segment := range track.Segments {
+				points += len(segment)
+			}
+		}
+		return points
+	}
+	if len(gpxTracks) != len(geojsonTracks) || count(gpxTracks) != count(geojsonTracks) {
+		return fmt.Errorf("GPX has %d tracks with %d points, GeoJSON %d with %d",
+			len(gpxTracks), count(gpxTracks), len(geojsonTracks), count(geojsonTracks))
+	}
+	return nil
+}
+
+// printReport prints the issues of a report and its verdict
+func printReport(r *validate.Report, strict bool) {
+	for _, issue := range r.Issues {
+		icon := "⚠️ "
+		if issue.Severity == validate.SeverityError {
+			icon = "❌"
+		}
+		fmt.Printf("%s %s\n", icon, issue)
+	}
+
+	errors, warnings := r.Count(validate.SeverityError), r.Count(validate.SeverityWarning)
+	switch {
+	case !r.Passed(strict):
+		fmt.Printf("❌ %s validation failed: %d errors, %d warnings\n", r.Name, errors, warnings)
+	case warnings > 0:
+		fmt.Printf("✅ %s validation passed with %d warnings\n", r.Name, warnings)
+	default:
+		fmt.Printf("✅ %s validation passed\n", r.Name)
+	}
+}
+
+// writeReports writes the JSON and JUnit reports to the paths that are set
+func writeReports(reports []*validate.Report, jsonPath, junitPath string, strict bool) error {
+	write := func(path string, fn func(w io.Writer) error) error {
+		if path == "" {
+			return nil
+		}
+		out, err := converter.CreateAtomic(path, converter.OutputOptions{Force: true})
+		if err != nil {
+			return err
+		}
+		defer out.Abort()
+		if err := fn(out); err != nil {
+			return err
+		}
+		return out.Commit(len(reports))
+	}
+	if err := write(jsonPath, func(w io.Writer) error { return validate.WriteJSON(w, reports) }); err != nil {
+		return err
+	}
+	return write(junitPath, func(w io.Writer) error { return validate.WriteJUnit(w, reports, strict) })
+}
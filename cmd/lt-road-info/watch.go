@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"iter"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/config"
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/metrics"
+	"github.com/dimchansky/lt-road-info/internal/notify"
+	"github.com/dimchansky/lt-road-info/internal/schedule"
+	"github.com/dimchansky/lt-road-info/internal/split"
+	"github.com/dimchansky/lt-road-info/internal/watch"
+)
+
+// watchStateFilename is the default name of the watch state file in the output directory
+const watchStateFilename = ".lt-road-info-watch.json"
+
+// stringList is a flag that may be given several times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// runWatch implements the watch subcommand and returns the exit code
+func runWatch(args []string) int {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	configPath, profile := configFlags(fs)
+	logOpts := logFlags(fs)
+	var (
+		outputDir   = fs.String("output", ".", "Output directory for GPX files")
+		dataType    = fs.String("type", "all", "Type of data to watch: all, restrictions, speed-control")
+		spec        = fs.String("schedule", "15m", "When to check upstream: an interval (15m, @every 1h), @hourly, @daily or a cron expression (\"*/10 6-22 * * *\")")
+		cacheDir    = fs.String("cache-dir", "", "Directory for the HTTP cache; enables conditional requests")
+		backup      = fs.Bool("backup", false, "Keep the replaced GPX files as .bak")
+		force       = fs.Bool("force", false, "Replace existing GPX files even with an empty or much smaller dataset")
+		minCount    = fs.Int("min-features", 1, "Refuse to write a source with fewer features than this (0 disables)")
+		maxDrop     = fs.Float64("max-drop", 50, "Refuse to write a source whose feature count dropped by more than this percentage since the last written output (0 disables)")
+		statePath   = fs.String("state", "", "File recording the last written dataset per source (default <output>/"+watchStateFilename+")")
+		once        = fs.Bool("once", false, "Check once and exit instead of following the schedule")
+		feedSpec    = fs.String("feed", "", "Also write a feed of added and changed restrictions: atom, rss or atom,rss")
+		metricsAddr = fs.String("metrics-listen", "", "Address to serve Prometheus metrics on as /metrics, e.g. :9090 (default disabled)")
+		commands    stringList
+		webhooks    stringList
+
+		notifyURLs     stringList
+		notifyParams   stringList
+		notifyFormat   = fs.String("notify-format", "json", "Payload format for -notify: json, slack, discord, telegram or matrix")
+		notifyTemplate = fs.String("notify-template", "", "File with a Go template for -notify payloads, overriding -notify-format")
+		notifySecret   = fs.String("notify-secret", "", "Secret for the "+notify.SignatureHeader+" HMAC header of -notify requests (default $LT_ROAD_INFO_NOTIFY_SECRET)")
+		notifyEvents   = fs.String("notify-events", "", "Comma-separated event types to send with -notify, e.g. restrictions.added,speed-control.added (default all)")
+	)
+	road, splitKinds := roadFlags(fs)
+	vehicleSpec := vehicleFlag(fs)
+	fs.Var(&commands, "on-change", "Shell command to run when tracks appear or disappear; receives the change as JSON on stdin (repeatable)")
+	fs.Var(&webhooks, "webhook", "URL to POST the change JSON to when tracks appear or disappear (repeatable)")
+	fs.Var(&notifyURLs, "notify", "URL to POST one templated message per added or removed track to (repeatable)")
+	fs.Var(&notifyParams, "notify-param", "key=value made available to -notify templates as .Params, e.g. chat_id=-100123 (repeatable)")
+	fs.Usage = usage(fs,
+		`Regenerate GPX files whenever upstream data changes and run hooks on changes.
+
+Hooks receive {"source", "time", "output", "added": [...], "removed": [...]}.
+Commands also get LT_ROAD_INFO_SOURCE, LT_ROAD_INFO_OUTPUT, LT_ROAD_INFO_ADDED
+and LT_ROAD_INFO_REMOVED in their environment. The first check of a source only
+records a baseline.`,
+		"watch [flags]",
+		"Check every 10 minutes during the day and publish changes", `lt-road-info watch -schedule "*/10 6-22 * * *" -on-change ./publish.sh`,
+		"Post new restrictions to a Slack channel", "lt-road-info watch -notify https://hooks.slack.com/services/... -notify-format slack -notify-events restrictions.added",
+		"Check once from an existing scheduler", "lt-road-info watch -once -output /path/to/gpx",
+	)
+	fs.Parse(args)
+	if err := logOpts.setup(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	if *notifySecret == "" {
+		*notifySecret = os.Getenv("LT_ROAD_INFO_NOTIFY_SECRET")
+	}
+
+	cfg, err := loadConfig(*configPath, *profile)
+	if err != nil {
+		slog.Error("Invalid configuration", logging.Err(err))
+		return exitUsage
+	}
+	var flagErr error
+	setFlags(fs, func(name string) {
+		switch name {
+		case "output":
+			cfg.OutputDir = *outputDir
+		case "cache-dir":
+			cfg.CacheDir = *cacheDir
+		case "backup":
+			cfg.Backup = *backup
+		case "force":
+			cfg.Force = *force
+		case "min-features":
+			cfg.Guard.MinFeatures = *minCount
+		case "max-drop":
+			cfg.Guard.MaxDrop = *maxDrop
+		case "schedule":
+			cfg.Watch.Schedule = *spec
+		case "metrics-listen":
+			cfg.Watch.MetricsListen = *metricsAddr
+		case "feed":
+			formats, err := parseFeedFormats(*feedSpec)
+			if err != nil {
+				flagErr = fmt.Errorf("invalid -feed: %w", err)
+			}
+			cfg.Sources.Restrictions.Feed = formats
+		case "road":
+			flagErr = errors.Join(flagErr, setRoads(cfg, *road))
+		case "split":
+			flagErr = errors.Join(flagErr, setSplit(cfg, *splitKinds))
+		case "vehicle":
+			flagErr = errors.Join(flagErr, setVehicle(cfg, *vehicleSpec))
+		}
+	})
+	if flagErr != nil {
+		slog.Error("Invalid arguments", logging.Err(flagErr))
+		return exitUsage
+	}
+	warnVehicle(cfg)
+	cfg.Hooks.Commands = append(cfg.Hooks.Commands, commands...)
+	cfg.Hooks.Webhooks = append(cfg.Hooks.Webhooks, webhooks...)
+
+	sched, err := schedule.Parse(cfg.Watch.Schedule)
+	if err != nil {
+		slog.Error("Invalid schedule", "schedule", cfg.Watch.Schedule, logging.Err(err))
+		return exitUsage
+	}
+
+	selected, err := selectSources(cfg, *dataType)
+	if err != nil {
+		slog.Error("Invalid arguments", logging.Err(err))
+		return exitUsage
+	}
+
+	var cache *data.Cache
+	if cfg.CacheDir != "" {
+		if cache, err = data.NewCache(cfg.CacheDir); err != nil {
+			slog.Error("Failed to open HTTP cache", "path", cfg.CacheDir, logging.Err(err))
+			return exitFailure
+		}
+	}
+	var m *metrics.Metrics
+	if cfg.Watch.MetricsListen != "" && !*once {
+		m = metrics.New()
+	}
+	httpClient := newHTTPClient(cfg.HTTP, m.Transport(nil))
+
+	if err := os.MkdirAll(cfg.OutputDir, 0755); err != nil {
+		slog.Error("Failed to create output directory", "path", cfg.OutputDir, logging.Err(err))
+		return exitFailure
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(cfg.OutputDir, watchStateFilename)
+	}
+	state, err := watch.LoadState(*statePath)
+	if err != nil {
+		slog.Error("Failed to load state", "path", *statePath, logging.Err(err))
+		return exitFailure
+	}
+
+	feedFormats := cfg.Sources.Restrictions.Feed
+	var restrictionsFeed *feed.Feed
+	if len(feedFormats) > 0 && cfg.Sources.Restrictions.Enabled {
+		if restrictionsFeed, err = feed.Load(filepath.Join(cfg.OutputDir, feedStateFilename)); err != nil {
+			slog.Error("Failed to load feed", logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	var sources []watch.Source
+	for _, src := range selected {
+		sc, _ := cfg.Source(src.name)
+		outputs := sc.Outputs(cfg.OutputDir)
+		outputPath := outputs[0].Path
+		var afterWrite func([]converter.Track, time.Time) error
+		if restrictionsFeed != nil && src.name == restrictionsSource.name {
+			afterWrite = feedWriter(restrictionsFeed, src, outputPath, feedFormats)
+		}
+		if len(sc.Split) > 0 {
+			writeFeed := afterWrite
+			opts := converter.OutputOptions{Backup: cfg.Backup}
+
+			// watch keeps no manifest, so only the split files written
+			// since it started are removed when their group disappears
+			var previous []string
+			afterWrite = func(tracks []converter.Track, t time.Time) error {
+				files, err := split.Write(sc.Split, outputs, src.documentTitle, tracks, previous, opts)
+				previous = previous[:0]
+				for _, f := range files {
+					previous = append(previous, f.Path)
+				}
+				if writeFeed != nil {
+					err = errors.Join(err, writeFeed(tracks, t))
+				}
+				return err
+			}
+		}
+		sources = append(sources, watch.Source{
+			Name:    src.name,
+			Title:   src.title,
+			Path:    outputPath,
+			Outputs: outputs,
+			Config:  sc.Fingerprint(),
+			Fetch: func(stats *converter.Stats, conditional bool) iter.Seq2[converter.Track, error] {
+				client := data.NewCachingClient(httpClient, cache)
+				tracks := m.Tracks(src.name, client, stats, src.tracks(client, stats))
+				if !conditional {
+					return sc.Apply(tracks)
+				}
+				return sc.Apply(data.FailIfUnchanged(client, tracks))
+			},
+			AfterWrite: afterWrite,
+		})
+	}
+
+	var hooks []watch.Hook
+	for _, command := range cfg.Hooks.Commands {
+		hooks = append(hooks, watch.CommandHook{Command: command})
+	}
+	for _, url := range cfg.Hooks.Webhooks {
+		hooks = append(hooks, watch.NewWebhookHook(url, nil))
+	}
+	targets, err := notifyTargets(cfg.Hooks.Notify)
+	if err != nil {
+		slog.Error("Invalid notification settings", logging.Err(err))
+		return exitFailure
+	}
+	if len(notifyURLs) > 0 {
+		flagTargets, err := newNotifyTargets(notifyURLs, *notifyFormat, *notifyTemplate, *notifySecret, *notifyEvents, notifyParams)
+		if err != nil {
+			slog.Error("Invalid notification settings", logging.Err(err))
+			return exitFailure
+		}
+		targets = append(targets, flagTargets...)
+	}
+	if len(targets) > 0 {
+		notifier, err := notify.NewNotifier(targets, nil)
+		if err != nil {
+			slog.Error("Invalid notification settings", logging.Err(err))
+			return exitFailure
+		}
+		hooks = append(hooks, notifier)
+	}
+
+	w := watch.New(sources, state, watch.Config{
+		Output:     converter.OutputOptions{Backup: cfg.Backup, Force: cfg.Force},
+		Thresholds: guard.Thresholds{MinFeatures: cfg.Guard.MinFeatures, MaxDropPercent: cfg.Guard.MaxDrop},
+		Hooks:      hooks,
+		Metrics:    m,
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		var results []sourceResult
+		for i, r := range w.Check(ctx) {
+			results = append(results, sourceResult{source: selected[i], outputPath: sources[i].Path, unchanged: r.Unchanged, err: r.Err})
+		}
+		return reportResults(results)
+	}
+
+	if m != nil {
+		if err := serveMetrics(ctx, cfg.Watch.MetricsListen, m); err != nil {
+			slog.Error("Failed to serve metrics", "listen", cfg.Watch.MetricsListen, logging.Err(err))
+			return exitFailure
+		}
+	}
+
+	slog.Info("Watching", "sources", len(sources), "schedule", cfg.Watch.Schedule)
+	w.Run(ctx, sched)
+	return 0
+}
+
+// serveMetrics serves m as /metrics on addr until ctx is done
+func serveMetrics(ctx context.Context, addr string, m *metrics.Metrics) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	go srv.Serve(listener)
+	slog.Info("Serving metrics", "listen", listener.Addr().String())
+	return nil
+}
+
+// notifyTargets converts the notification targets of the configuration file
+func notifyTargets(configured []config.Notify) ([]notify.Target, error) {
+	targets := make([]notify.Target, len(configured))
+	for i, n := range configured {
+		text := n.Template
+		if n.TemplateFile != "" {
+			content, err := os.ReadFile(n.TemplateFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template: %w", err)
+			}
+			text = string(content)
+		}
+		targets[i] = notify.Target{
+			URL:         n.URL,
+			Format:      n.Format,
+			Template:    text,
+			Params:      n.Params,
+			Secret:      n.Secret,
+			Events:      n.Events,
+			MaxAttempts: n.MaxAttempts,
+		}
+	}
+	return targets, nil
+}
+
+// newNotifyTargets builds targets sending the same kind of payload to every URL
+func newNotifyTargets(urls []string, format, templatePath, secret, events string, params []string) ([]notify.Target, error) {
+	var text string
+	if templatePath != "" {
+		content, err := os.ReadFile(templatePath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template: %w", err)
+		}
+		text = string(content)
+	}
+
+	values := make(map[string]string, len(params))
+	for _, param := range params {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			return nil, fmt.Errorf("-notify-param %q is not key=value", param)
+		}
+		values[key] = value
+	}
+
+	var types []string
+	for _, t := range strings.Split(events, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+
+	targets := make([]notify.Target, len(urls))
+	for i, url := range urls {
+		targets[i] = notify.Target{
+			URL:      url,
+			Format:   format,
+			Template: text,
+			Params:   values,
+			Secret:   secret,
+			Events:   types,
+		}
+	}
+	return targets, nil
+}
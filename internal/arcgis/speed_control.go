@@ -1,7 +1,10 @@
 package arcgis
 
 import (
+	"io"
+	"iter"
 	"net/http"
+	"os"
 
 	"github.com/dimchansky/lt-road-info/internal/converter"
 	"github.com/dimchansky/lt-road-info/internal/data"
@@ -15,15 +18,36 @@ func DownloadSpeedControlSections(outputPath string) error {
 // DownloadSpeedControlSectionsWithClient downloads speed control sections using a custom HTTP client
 // This allows for testing with go-vcr or other HTTP interceptors
 func DownloadSpeedControlSectionsWithClient(httpClient *http.Client, outputPath string) error {
-	// Create data client
-	client := data.NewClient(httpClient)
+	return DownloadSpeedControlSectionsWithDataClient(data.NewClient(httpClient), outputPath)
+}
+
+// DownloadSpeedControlSectionsWithDataClient downloads speed control sections using a
+// configured data client. If the client is caching and upstream reported no changes,
+// the existing output is kept and data.ErrUnchanged is returned.
+func DownloadSpeedControlSectionsWithDataClient(client *data.Client, outputPath string) error {
+	return DownloadSpeedControlSectionsWithOptions(client, outputPath, converter.OutputOptions{})
+}
 
-	// Fetch data
-	features, err := client.FetchArcGISData()
-	if err != nil {
-		return err
+// DownloadSpeedControlSectionsWithOptions downloads speed control sections using a
+// configured data client and replaces outputPath according to opts
+func DownloadSpeedControlSectionsWithOptions(client *data.Client, outputPath string, opts converter.OutputOptions) error {
+	// Stream data straight into the converter
+	features := client.ArcGISFeatures()
+	if _, err := os.Stat(outputPath); err == nil {
+		features = data.FailIfUnchanged(client, features)
 	}
 
-	// Convert to GPX
-	return converter.ArcGISToGPX(features, outputPath)
+	return converter.ArcGISFeaturesToGPXWithOptions(features, outputPath, opts)
+}
+
+// WriteSpeedControlSections downloads speed control sections and writes them
+// as GPX to w as they are decoded, e.g. to stdout or an HTTP response
+func WriteSpeedControlSections(client *data.Client, w io.Writer) error {
+	return converter.WriteArcGISGPX(w, client.ArcGISFeatures())
+}
+
+// Tracks streams speed control sections from upstream as WGS84 tracks. The
+// conversion counts are added to stats, if not nil, once the stream ends.
+func Tracks(client *data.Client, stats *converter.Stats) iter.Seq2[converter.Track, error] {
+	return converter.ArcGISTracksWithStats(client.ArcGISFeatures(), stats)
 }
@@ -27,6 +27,10 @@ func TestDownloadSpeedControlWithMockedAPI(t *testing.T) {
 
 	queryServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("returnIdsOnly") == "true" {
+			w.Write([]byte(`{"objectIdFieldName": "OBJECTID", "objectIds": [1]}`))
+			return
+		}
 		w.Write(testData)
 	}))
 	defer queryServer.Close()
@@ -148,9 +152,11 @@ func (amt *arcgisMockTransport) RoundTrip(req *http.Request) (*http.Response, er
 		req.URL, _ = req.URL.Parse(amt.serviceInfoURL)
 	}
 
-	// Route query requests
+	// Route query requests, keeping the query parameters
 	if strings.Contains(url, "MapServer/13/query") {
+		rawQuery := req.URL.RawQuery
 		req.URL, _ = req.URL.Parse(amt.queryURL)
+		req.URL.RawQuery = rawQuery
 	}
 
 	return amt.transport.RoundTrip(req)
@@ -0,0 +1,347 @@
+// Package config loads the YAML configuration file of lt-road-info, with
+// named profiles and environment variable overrides.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix starts the names of the environment variables that override
+// configuration keys, e.g. LT_ROAD_INFO_HTTP_TIMEOUT for http.timeout
+const EnvPrefix = "LT_ROAD_INFO_"
+
+// Config is the complete configuration
+type Config struct {
+	OutputDir string `yaml:"output_dir"`
+	CacheDir  string `yaml:"cache_dir"`
+
+	// State is the guard state file, default <output_dir>/.lt-road-info-state.json
+	State string `yaml:"state"`
+
+	Backup bool `yaml:"backup"`
+	Force  bool `yaml:"force"`
+
+	// Manifest writes manifest.json to the output directory
+	Manifest bool `yaml:"manifest"`
+
+	// IconCatalog is a CSV file of icon codes adding to the embedded icon
+	// catalogue, in its format
+	IconCatalog string `yaml:"icon_catalog"`
+
+	HTTP    HTTP    `yaml:"http"`
+	Guard   Guard   `yaml:"guard"`
+	Sources Sources `yaml:"sources"`
+	Watch   Watch   `yaml:"watch"`
+	Hooks   Hooks   `yaml:"hooks"`
+}
+
+// HTTP configures upstream requests
+type HTTP struct {
+	// Timeout bounds a whole request including reading the body; 0 disables it
+	Timeout Duration `yaml:"timeout"`
+
+	// Retries is the number of retries of a request failing with a network
+	// error, 429 or 5xx
+	Retries int `yaml:"retries"`
+
+	// RetryDelay is the wait before the first retry; it doubles for each
+	// further one
+	RetryDelay Duration `yaml:"retry_delay"`
+}
+
+// Guard configures the sanity checks applied before outputs are replaced
+type Guard struct {
+	MinFeatures int     `yaml:"min_features"`
+	MaxDrop     float64 `yaml:"max_drop"`
+}
+
+// Sources configures each upstream dataset
+type Sources struct {
+	Restrictions Source `yaml:"restrictions"`
+	SpeedControl Source `yaml:"speed-control"`
+}
+
+// Source configures the outputs generated from one dataset
+type Source struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Filename is the output name without extension; every format adds
+	// its own
+	Filename string   `yaml:"filename"`
+	Formats  []string `yaml:"formats"`
+
+	Filter Filter `yaml:"filter"`
+	Style  Style  `yaml:"style"`
+
+	// Simplify is the Douglas-Peucker tolerance in metres; 0 disables it
+	Simplify float64 `yaml:"simplify"`
+
+	// Feed lists the feed formats (atom, rss) to write next to the outputs
+	Feed []string `yaml:"feed"`
+
+	// Split lists the ways (road, category) to split the outputs into
+	// further files next to them, e.g. lt-road-restrictions-A1.gpx
+	Split []string `yaml:"split"`
+}
+
+// Filter selects the tracks written to the outputs of a source
+type Filter struct {
+	// BBox keeps tracks intersecting [minLon, minLat, maxLon, maxLat]
+	BBox []float64 `yaml:"bbox"`
+
+	// Include keeps only tracks whose properties match one of the listed
+	// patterns for every key; "name" matches the track name. Patterns use
+	// path.Match syntax, e.g. "*remontas*".
+	Include map[string][]string `yaml:"include"`
+
+	// Exclude drops tracks with a property matching one of the patterns
+	Exclude map[string][]string `yaml:"exclude"`
+
+	// Roads keeps only tracks on the listed roads, e.g. [A1, A6]
+	Roads []string `yaml:"roads"`
+
+	// Vehicle keeps only restrictions applying to this vehicle
+	Vehicle Vehicle `yaml:"vehicle"`
+}
+
+// Vehicle describes the vehicle restrictions are filtered for; zero fields
+// are unknown
+type Vehicle struct {
+	// Class is car, van, truck or bus
+	Class string `yaml:"class"`
+
+	// Weight and AxleLoad are in tonnes
+	Weight   float64 `yaml:"weight"`
+	AxleLoad float64 `yaml:"axle_load"`
+
+	// Height, Width and Length are in metres
+	Height float64 `yaml:"height"`
+	Width  float64 `yaml:"width"`
+	Length float64 `yaml:"length"`
+}
+
+// Style is the line style written to outputs that support styling
+type Style struct {
+	Color   string  `yaml:"color"`
+	Width   float64 `yaml:"width"`
+	Opacity float64 `yaml:"opacity"`
+}
+
+// Watch configures the watch command
+type Watch struct {
+	Schedule string `yaml:"schedule"`
+
+	// MetricsListen is the address /metrics is served on, if any
+	MetricsListen string `yaml:"metrics_listen"`
+}
+
+// Hooks are run by the watch command when tracks appear or disappear
+type Hooks struct {
+	Commands []string `yaml:"commands"`
+	Webhooks []string `yaml:"webhooks"`
+	Notify   []Notify `yaml:"notify"`
+}
+
+// Notify is a templated webhook target, see the notify package
+type Notify struct {
+	URL          string            `yaml:"url"`
+	Format       string            `yaml:"format"`
+	Template     string            `yaml:"template"`
+	TemplateFile string            `yaml:"template_file"`
+	Secret       string            `yaml:"secret"`
+	Events       []string          `yaml:"events"`
+	Params       map[string]string `yaml:"params"`
+	MaxAttempts  int               `yaml:"max_attempts"`
+}
+
+// Duration is a time.Duration written as "30s" or "2m"
+type Duration time.Duration
+
+// UnmarshalYAML parses a Go duration string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := time.ParseDuration(node.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid duration %q", node.Line, node.Value)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalYAML writes the duration string
+func (d Duration) MarshalYAML() (any, error) {
+	return time.Duration(d).String(), nil
+}
+
+// Default returns the configuration used without a config file
+func Default() *Config {
+	return &Config{
+		OutputDir: ".",
+		Manifest:  true,
+		HTTP:      HTTP{RetryDelay: Duration(2 * time.Second)},
+		Guard:     Guard{MinFeatures: 1, MaxDrop: 50},
+		Sources: Sources{
+			Restrictions: Source{Enabled: true, Filename: "lt-road-restrictions", Formats: []string{"gpx"}},
+			SpeedControl: Source{Enabled: true, Filename: "lt-speed-control", Formats: []string{"gpx"}},
+		},
+		Watch: Watch{Schedule: "15m"},
+	}
+}
+
+// Source returns the configuration of the named source
+func (c *Config) Source(name string) (*Source, bool) {
+	switch name {
+	case "restrictions":
+		return &c.Sources.Restrictions, true
+	case "speed-control":
+		return &c.Sources.SpeedControl, true
+	}
+	return nil, false
+}
+
+// Load reads the configuration file at path on top of the defaults,
+// applies the named profile and the environment overrides in env (as
+// returned by os.Environ) and validates the result. An empty path uses
+// the defaults; an empty profile uses the file's top-level settings only.
+func Load(path, profile string, env []string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if err := cfg.decode(content, profile); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %q requires a config file", profile)
+	}
+
+	if err := cfg.applyEnv(env); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decode merges the document, with the profile applied, into cfg.
+// Unknown keys are rejected.
+func (c *Config) decode(content []byte, profile string) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		if profile != "" {
+			return fmt.Errorf("profile %q not found: the file is empty", profile)
+		}
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected a mapping of settings", root.Line)
+	}
+
+	profiles := removeKey(root, "profiles")
+	if profile != "" {
+		selected, available := findProfile(profiles, profile)
+		if selected == nil {
+			return fmt.Errorf("profile %q not found (available: %s)", profile, strings.Join(available, ", "))
+		}
+		if selected.Kind != yaml.MappingNode {
+			return fmt.Errorf("line %d: profiles.%s must be a mapping of settings", selected.Line, profile)
+		}
+		merge(root, selected)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	if err := enc.Encode(root); err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(&buf)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil {
+		return unknownKeyError(err)
+	}
+	return nil
+}
+
+// removeKey deletes key from a mapping node and returns its value
+func removeKey(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			value := mapping.Content[i+1]
+			mapping.Content = slices.Delete(mapping.Content, i, i+2)
+			return value
+		}
+	}
+	return nil
+}
+
+func findProfile(profiles *yaml.Node, name string) (*yaml.Node, []string) {
+	if profiles == nil || profiles.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	var available []string
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		if profiles.Content[i].Value == name {
+			return profiles.Content[i+1], nil
+		}
+		available = append(available, profiles.Content[i].Value)
+	}
+	return nil, available
+}
+
+// merge deep-merges the mapping src into dst: nested mappings are merged
+// key by key, anything else in src replaces the value in dst
+func merge(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+
+		found := false
+		for j := 0; j+1 < len(dst.Content); j += 2 {
+			if dst.Content[j].Value != key.Value {
+				continue
+			}
+			found = true
+			if dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+				merge(dst.Content[j+1], value)
+			} else {
+				dst.Content[j+1] = value
+			}
+			break
+		}
+		if !found {
+			dst.Content = append(dst.Content, key, value)
+		}
+	}
+}
+
+// unknownKeyError rewrites yaml.v3's "field x not found in type config.T"
+// into a message naming the key
+func unknownKeyError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+	msgs := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		if before, after, ok := strings.Cut(msg, ": field "); ok {
+			field, _, _ := strings.Cut(after, " not found")
+			msg = fmt.Sprintf("%s: unknown key %q", before, field)
+		}
+		msgs[i] = msg
+	}
+	return errors.New(strings.Join(msgs, "; "))
+}
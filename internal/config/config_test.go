@@ -0,0 +1,257 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+const testConfig = `
+output_dir: out
+http:
+  timeout: 30s
+  retries: 2
+sources:
+  restrictions:
+    formats: [gpx, geojson]
+    style:
+      color: "#d62728"
+      width: 4
+  speed-control:
+    enabled: false
+profiles:
+  garmin:
+    sources:
+      restrictions:
+        formats: [gpx]
+        simplify: 5
+  web:
+    output_dir: /srv/www
+`
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "lt-road-info.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig), "", nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OutputDir != "out" || time.Duration(cfg.HTTP.Timeout) != 30*time.Second || cfg.HTTP.Retries != 2 {
+		t.Errorf("unexpected top-level settings: %+v", cfg)
+	}
+	r := cfg.Sources.Restrictions
+	if !r.Enabled || r.Filename != "lt-road-restrictions" {
+		t.Errorf("defaults of restrictions lost: %+v", r)
+	}
+	if !slices.Equal(r.Formats, []string{"gpx", "geojson"}) || r.Style.Color != "#d62728" {
+		t.Errorf("unexpected restrictions: %+v", r)
+	}
+	if cfg.Sources.SpeedControl.Enabled {
+		t.Error("speed-control should be disabled")
+	}
+	if cfg.Guard.MaxDrop != 50 {
+		t.Errorf("guard default lost: %+v", cfg.Guard)
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	cfg, err := Load(writeConfig(t, testConfig), "garmin", nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	r := cfg.Sources.Restrictions
+	if !slices.Equal(r.Formats, []string{"gpx"}) || r.Simplify != 5 {
+		t.Errorf("profile not applied: %+v", r)
+	}
+	if r.Style.Color != "#d62728" || cfg.OutputDir != "out" {
+		t.Errorf("settings outside the profile lost: %+v", cfg)
+	}
+
+	_, err = Load(writeConfig(t, testConfig), "phone", nil)
+	if err == nil || !strings.Contains(err.Error(), `profile "phone" not found (available: garmin, web)`) {
+		t.Errorf("unexpected error for a missing profile: %v", err)
+	}
+}
+
+func TestLoadEnv(t *testing.T) {
+	env := []string{
+		"LT_ROAD_INFO_OUTPUT_DIR=/data",
+		"LT_ROAD_INFO_HTTP_TIMEOUT=1m",
+		"LT_ROAD_INFO_SOURCES_SPEED_CONTROL_ENABLED=true",
+		"LT_ROAD_INFO_SOURCES_SPEED_CONTROL_FORMATS=geojson, gpx",
+		"LT_ROAD_INFO_SOURCES_RESTRICTIONS_FILTER_BBOX=21,54,27,57",
+		"PATH=/usr/bin",
+	}
+	cfg, err := Load(writeConfig(t, testConfig), "web", env)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.OutputDir != "/data" || time.Duration(cfg.HTTP.Timeout) != time.Minute {
+		t.Errorf("environment should override file and profile: %+v", cfg)
+	}
+	s := cfg.Sources.SpeedControl
+	if !s.Enabled || !slices.Equal(s.Formats, []string{"geojson", "gpx"}) {
+		t.Errorf("unexpected speed-control: %+v", s)
+	}
+	if !slices.Equal(cfg.Sources.Restrictions.Filter.BBox, []float64{21, 54, 27, 57}) {
+		t.Errorf("unexpected bbox: %v", cfg.Sources.Restrictions.Filter.BBox)
+	}
+
+	_, err = Load("", "", []string{"LT_ROAD_INFO_HTTP_RETRIES=many"})
+	if err == nil || !strings.Contains(err.Error(), "LT_ROAD_INFO_HTTP_RETRIES (http.retries)") {
+		t.Errorf("unexpected error for a bad variable: %v", err)
+	}
+
+	_, err = Load("", "", []string{
+		"LT_ROAD_INFO_HTTP_TIMOUT=1m",
+		"LT_ROAD_INFO_OUTPUTDIR=/data",
+		"LT_ROAD_INFO_HTTP_TIMEOUT=1m",
+		"LT_ROAD_INFO_PROFILE=web",
+		"LT_ROAD_INFO_LOG_FORMAT=json",
+		"LT_ROAD_INFO_ADDED=3",
+	})
+	if err == nil {
+		t.Fatal("expected an error for misspelt variables")
+	}
+	want := "LT_ROAD_INFO_HTTP_TIMOUT: matches no configuration key\nLT_ROAD_INFO_OUTPUTDIR: matches no configuration key"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestLoadErrorsNameKey(t *testing.T) {
+	tests := []struct {
+		config string
+		want   string
+	}{
+		{"sources:\n  restrictions:\n    formats: [gpx, kml]\n", `sources.restrictions.formats[1]: unknown format "kml"`},
+		{"http:\n  timeout: soon\n", `invalid duration "soon"`},
+		{"http:\n  timeuot: 30s\n", `unknown key "timeuot"`},
+		{"sources:\n  restrictions:\n    style:\n      color: red\n", "sources.restrictions.style: color"},
+		{"sources:\n  speed-control:\n    feed: [atom]\n", "sources.speed-control.feed: feeds are only available for restrictions"},
+		{"sources:\n  speed-control:\n    filename: lt-road-restrictions\n", "sources.speed-control.filename"},
+		{"watch:\n  schedule: sometimes\n", "watch.schedule"},
+		{"hooks:\n  notify:\n    - format: slack\n", "hooks.notify[0].url: must not be empty"},
+		{"guard:\n  max_drop: 150\n", "guard.max_drop"},
+		{"sources:\n  restrictions:\n    filter:\n      roads: [A1, Vilnius]\n", `sources.restrictions.filter.roads[1]: invalid road number "Vilnius"`},
+		{"sources:\n  restrictions:\n    filter:\n      vehicle:\n        class: tank\n", `sources.restrictions.filter.vehicle: unknown class "tank"`},
+		{"sources:\n  restrictions:\n    split: [county]\n", `sources.restrictions.split[0]: unknown split "county"`},
+	}
+	for _, tt := range tests {
+		_, err := Load(writeConfig(t, tt.config), "", nil)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Load(%q) error = %v, want it to contain %q", tt.config, err, tt.want)
+		}
+	}
+}
+
+func TestSourceApply(t *testing.T) {
+	src := Source{
+		Filter: Filter{
+			BBox:    []float64{23, 54, 26, 56},
+			Include: map[string][]string{"type": {"Darbai*", "Uždarymas"}},
+			Exclude: map[string][]string{"name": {"*bandymas*"}},
+		},
+		Style: Style{Color: "#123456"},
+	}
+	line := [][]converter.Point{{{Lat: 55, Lon: 24}, {Lat: 55.001, Lon: 24.001}}}
+	tracks := []converter.Track{
+		{Name: "A1 remontas", Segments: line, Properties: map[string]any{"type": "Darbai kelyje"}},
+		{Name: "A2", Segments: line, Properties: map[string]any{"type": "Eismo ribojimas"}},
+		{Name: "A3 bandymas", Segments: line, Properties: map[string]any{"type": "Uždarymas"}},
+		{Name: "Riga", Segments: [][]converter.Point{{{Lat: 56.9, Lon: 24.1}}}, Properties: map[string]any{"type": "Uždarymas"}},
+	}
+
+	var got []string
+	for track, err := range src.Apply(seq(tracks)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if track.Style == nil || track.Style.Color != "#123456" {
+			t.Errorf("%s: style not applied", track.Name)
+		}
+		got = append(got, track.Name)
+	}
+	if !slices.Equal(got, []string{"A1 remontas"}) {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFilterRoads(t *testing.T) {
+	f := Filter{Roads: []string{"a1", "101"}}
+	tests := []struct {
+		track converter.Track
+		want  bool
+	}{
+		{converter.Track{Name: "Kelio remontas A1"}, true},
+		{converter.Track{Name: "Kelio remontas A10"}, false},
+		{converter.Track{Name: "Speed Control Section 1", Properties: map[string]any{"kelionr": "101"}}, true},
+		{converter.Track{Name: "Kelio remontas"}, false},
+	}
+	for _, tt := range tests {
+		if got := f.Match(tt.track); got != tt.want {
+			t.Errorf("Match(%q) = %v, want %v", tt.track.Name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterVehicle(t *testing.T) {
+	f := Filter{Vehicle: Vehicle{Class: "van", Weight: 3.5, Height: 2.8}}
+	tests := []struct {
+		props map[string]any
+		want  bool
+	}{
+		// A kind from the feature name is not enough to drop a restriction
+		{map[string]any{"type": "Masės ribojimas", "icon": "999", "kind": "weight", "kindSource": "feature", "iconValue": 10.0}, true},
+		{map[string]any{"type": "Kelio remontas", "icon": "76", "kind": "speed-limit", "kindSource": "icon", "iconValue": 50.0}, true},
+	}
+	for _, tt := range tests {
+		if got := f.Match(converter.Track{Name: "restriction", Properties: tt.props}); got != tt.want {
+			t.Errorf("Match(%v) = %v, want %v", tt.props, got, tt.want)
+		}
+	}
+}
+
+func seq(tracks []converter.Track) func(func(converter.Track, error) bool) {
+	return func(yield func(converter.Track, error) bool) {
+		for _, track := range tracks {
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
+
+func TestSourceFingerprint(t *testing.T) {
+	base := Default().Sources.Restrictions
+	if base.Fingerprint() != Default().Sources.Restrictions.Fingerprint() {
+		t.Error("Expected equal settings to have equal fingerprints")
+	}
+
+	for name, change := range map[string]func(*Source){
+		"formats":  func(s *Source) { s.Formats = append(s.Formats, "geojson") },
+		"simplify": func(s *Source) { s.Simplify = 5 },
+		"style":    func(s *Source) { s.Style.Color = "#ff0000" },
+		"roads":    func(s *Source) { s.Filter.Roads = []string{"A1"} },
+		"vehicle":  func(s *Source) { s.Filter.Vehicle.Height = 4 },
+		"include":  func(s *Source) { s.Filter.Include = map[string][]string{"name": {"*A1*"}} },
+	} {
+		s := Default().Sources.Restrictions
+		change(&s)
+		if s.Fingerprint() == base.Fingerprint() {
+			t.Errorf("Expected a change of %s to change the fingerprint", name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envVariables are the variables with EnvPrefix that are not keys: those
+// read by the commands themselves and those set for hook commands, which
+// may run lt-road-info again
+var envVariables = map[string]bool{
+	"LT_ROAD_INFO_CONFIG":        true,
+	"LT_ROAD_INFO_PROFILE":       true,
+	"LT_ROAD_INFO_LOG_FORMAT":    true,
+	"LT_ROAD_INFO_NOTIFY_SECRET": true,
+	"LT_ROAD_INFO_SOURCE":        true,
+	"LT_ROAD_INFO_OUTPUT":        true,
+	"LT_ROAD_INFO_ADDED":         true,
+	"LT_ROAD_INFO_REMOVED":       true,
+}
+
+// applyEnv overrides scalar and list keys from environment variables named
+// after the key path: http.timeout is LT_ROAD_INFO_HTTP_TIMEOUT and
+// sources.speed-control.formats is LT_ROAD_INFO_SOURCES_SPEED_CONTROL_FORMATS.
+// Lists are comma-separated. Maps and hooks.notify can only be set in the
+// file. A variable with EnvPrefix that matches no key is rejected, so a
+// misspelt override is not silently ignored.
+func (c *Config) applyEnv(env []string) error {
+	vars := make(map[string]string)
+	for _, kv := range env {
+		if name, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(name, EnvPrefix) && !envVariables[name] {
+			vars[name] = value
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	if err := applyEnvStruct(reflect.ValueOf(c).Elem(), "", vars); err != nil {
+		return err
+	}
+
+	// applyEnvStruct removes the variables it used
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		errs = append(errs, fmt.Errorf("%s: matches no configuration key", name))
+	}
+	return errors.Join(errs...)
+}
+
+func applyEnvStruct(v reflect.Value, path string, vars map[string]string) error {
+	t := v.Type()
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if key == "" || key == "-" {
+			continue
+		}
+		if path != "" {
+			key = path + "." + key
+		}
+
+		field := v.Field(i)
+		if field.Kind() == reflect.Struct {
+			if err := applyEnvStruct(field, key, vars); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := EnvName(key)
+		value, ok := vars[name]
+		if !ok {
+			continue
+		}
+		delete(vars, name)
+		if err := setValue(field, value); err != nil {
+			return fmt.Errorf("%s (%s): %w", name, key, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable overriding a key path
+func EnvName(key string) string {
+	r := strings.NewReplacer(".", "_", "-", "_")
+	return EnvPrefix + strings.ToUpper(r.Replace(key))
+}
+
+var durationType = reflect.TypeFor[Duration]()
+
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var parts []string
+		if value != "" {
+			parts = strings.Split(value, ",")
+		}
+		list := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+		for i, part := range parts {
+			if err := setValue(list.Index(i), strings.TrimSpace(part)); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		field.Set(list)
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
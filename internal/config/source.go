@@ -0,0 +1,135 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"path/filepath"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/icons"
+	"github.com/dimchansky/lt-road-info/internal/roads"
+	"github.com/dimchansky/lt-road-info/internal/vehicle"
+)
+
+// converter returns the style in the converter's representation
+func (s Style) converter() converter.Style {
+	return converter.Style{Color: s.Color, Width: s.Width, Opacity: s.Opacity}
+}
+
+// vehicle returns the vehicle in the vehicle package's representation
+func (v Vehicle) vehicle() vehicle.Vehicle {
+	return vehicle.Vehicle{Class: v.Class, Weight: v.Weight, AxleLoad: v.AxleLoad, Height: v.Height, Width: v.Width, Length: v.Length}
+}
+
+// Outputs returns one output per configured format in dir
+func (s *Source) Outputs(dir string) []converter.Output {
+	outputs := make([]converter.Output, len(s.Formats))
+	for i, format := range s.Formats {
+		outputs[i] = converter.Output{
+			Path:   filepath.Join(dir, s.Filename+"."+format),
+			Format: format,
+		}
+	}
+	return outputs
+}
+
+// Fingerprint identifies the settings the outputs of s are generated with,
+// including the icon catalogue in effect. Outputs recorded with another
+// fingerprint are stale even when upstream has not changed.
+func (s *Source) Fingerprint() string {
+	// Map keys are sorted, so the encoding is deterministic
+	content, _ := json.Marshal(s)
+	sum := sha256.Sum256(append(content, icons.Digest()...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Apply filters, simplifies and styles tracks as configured
+func (s *Source) Apply(tracks iter.Seq2[converter.Track, error]) iter.Seq2[converter.Track, error] {
+	style := s.Style.converter()
+	return func(yield func(converter.Track, error) bool) {
+		for track, err := range tracks {
+			if err != nil {
+				yield(converter.Track{}, err)
+				return
+			}
+			if !s.Filter.Match(track) {
+				continue
+			}
+			if s.Simplify > 0 {
+				track = converter.Simplify(track, s.Simplify)
+			}
+			if style != (converter.Style{}) {
+				track.Style = &style
+			}
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Match reports whether a track passes the filter
+func (f Filter) Match(track converter.Track) bool {
+	if len(f.BBox) == 4 {
+		bbox := converter.BBox{MinLon: f.BBox[0], MinLat: f.BBox[1], MaxLon: f.BBox[2], MaxLat: f.BBox[3]}
+		if !bbox.Intersects(track.Bounds()) {
+			return false
+		}
+	}
+	if len(f.Roads) > 0 && !f.onRoads(track) {
+		return false
+	}
+	if f.Vehicle != (Vehicle{}) && !f.Vehicle.vehicle().Applies(track) {
+		return false
+	}
+	for key, patterns := range f.Include {
+		value, ok := property(track, key)
+		if !ok || !matchAny(patterns, value) {
+			return false
+		}
+	}
+	for key, patterns := range f.Exclude {
+		if value, ok := property(track, key); ok && matchAny(patterns, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// onRoads reports whether a track lies on one of the filter's roads
+func (f Filter) onRoads(track converter.Track) bool {
+	number := roads.Number(track)
+	if number == "" {
+		return false
+	}
+	for _, road := range f.Roads {
+		if n, _ := roads.Normalize(road); n == number {
+			return true
+		}
+	}
+	return false
+}
+
+// property returns a track property as text; "name" falls back to the
+// track name
+func property(track converter.Track, key string) (string, bool) {
+	if v, ok := track.Properties[key]; ok && v != nil {
+		return fmt.Sprint(v), true
+	}
+	if key == "name" {
+		return track.Name, true
+	}
+	return "", false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(pattern, value); matched {
+			return true
+		}
+	}
+	return false
+}
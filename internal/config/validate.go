@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/notify"
+	"github.com/dimchansky/lt-road-info/internal/roads"
+	"github.com/dimchansky/lt-road-info/internal/schedule"
+	"github.com/dimchansky/lt-road-info/internal/split"
+)
+
+// FeedFormats are the valid values of a source's feed list
+var FeedFormats = []string{"atom", "rss"}
+
+// SplitKinds are the valid values of a source's split list
+var SplitKinds = split.Kinds
+
+// maxRetries bounds http.retries so a misconfiguration cannot stall a run
+// for hours
+const maxRetries = 10
+
+// Validate checks every key and reports all problems at once, each
+// prefixed with the offending key path
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	if c.OutputDir == "" {
+		fail("output_dir", "must not be empty")
+	}
+	if c.HTTP.Timeout < 0 {
+		fail("http.timeout", "must not be negative")
+	}
+	if c.HTTP.Retries < 0 || c.HTTP.Retries > maxRetries {
+		fail("http.retries", "must be between 0 and %d, got %d", maxRetries, c.HTTP.Retries)
+	}
+	if c.HTTP.RetryDelay < 0 {
+		fail("http.retry_delay", "must not be negative")
+	}
+	if c.Guard.MinFeatures < 0 {
+		fail("guard.min_features", "must not be negative")
+	}
+	if c.Guard.MaxDrop < 0 || c.Guard.MaxDrop > 100 {
+		fail("guard.max_drop", "must be a percentage between 0 and 100, got %v", c.Guard.MaxDrop)
+	}
+
+	filenames := make(map[string]string)
+	for _, name := range []string{"restrictions", "speed-control"} {
+		src, _ := c.Source(name)
+		key := "sources." + name
+		if src.Filename == "" {
+			fail(key+".filename", "must not be empty")
+		} else if filepath.Base(src.Filename) != src.Filename {
+			fail(key+".filename", "must be a file name without directories, got %q", src.Filename)
+		} else if other, ok := filenames[src.Filename]; ok && src.Enabled {
+			fail(key+".filename", "%q is already used by sources.%s", src.Filename, other)
+		} else if src.Enabled {
+			filenames[src.Filename] = name
+		}
+
+		if src.Enabled && len(src.Formats) == 0 {
+			fail(key+".formats", "must list at least one of %s", strings.Join(converter.Formats, ", "))
+		}
+		for i, format := range src.Formats {
+			if !slices.Contains(converter.Formats, format) {
+				fail(fmt.Sprintf("%s.formats[%d]", key, i), "unknown format %q, expected one of %s", format, strings.Join(converter.Formats, ", "))
+			}
+		}
+
+		if bbox := src.Filter.BBox; len(bbox) > 0 {
+			if len(bbox) != 4 {
+				fail(key+".filter.bbox", "must be [minLon, minLat, maxLon, maxLat], got %d values", len(bbox))
+			} else if bbox[0] > bbox[2] || bbox[1] > bbox[3] {
+				fail(key+".filter.bbox", "minimum exceeds maximum")
+			}
+		}
+		for _, f := range []struct {
+			key      string
+			patterns map[string][]string
+		}{{"include", src.Filter.Include}, {"exclude", src.Filter.Exclude}} {
+			for prop, patterns := range f.patterns {
+				for i, pattern := range patterns {
+					if !validPattern(pattern) {
+						fail(fmt.Sprintf("%s.filter.%s.%s[%d]", key, f.key, prop, i), "malformed pattern %q", pattern)
+					}
+				}
+			}
+		}
+
+		for i, road := range src.Filter.Roads {
+			if _, ok := roads.Normalize(road); !ok {
+				fail(fmt.Sprintf("%s.filter.roads[%d]", key, i), "invalid road number %q, expected e.g. A1 or KK101", road)
+			}
+		}
+
+		if err := src.Filter.Vehicle.vehicle().Validate(); err != nil {
+			fail(key+".filter.vehicle", "%v", err)
+		}
+
+		if err := src.Style.converter().Validate(); err != nil {
+			fail(key+".style", "%v", err)
+		}
+		if src.Simplify < 0 || math.IsNaN(src.Simplify) {
+			fail(key+".simplify", "must be a non-negative tolerance in metres")
+		}
+		for i, format := range src.Feed {
+			if !slices.Contains(FeedFormats, format) {
+				fail(fmt.Sprintf("%s.feed[%d]", key, i), "unknown feed format %q, expected one of %s", format, strings.Join(FeedFormats, ", "))
+			}
+		}
+		if len(src.Feed) > 0 && name != "restrictions" {
+			fail(key+".feed", "feeds are only available for restrictions")
+		}
+		for i, kind := range src.Split {
+			if !slices.Contains(SplitKinds, kind) {
+				fail(fmt.Sprintf("%s.split[%d]", key, i), "unknown split %q, expected one of %s", kind, strings.Join(SplitKinds, ", "))
+			}
+		}
+	}
+
+	if _, err := schedule.Parse(c.Watch.Schedule); err != nil {
+		fail("watch.schedule", "%v", err)
+	}
+
+	for i, n := range c.Hooks.Notify {
+		key := fmt.Sprintf("hooks.notify[%d]", i)
+		if n.URL == "" {
+			fail(key+".url", "must not be empty")
+		}
+		if n.Template != "" && n.TemplateFile != "" {
+			fail(key, "template and template_file are mutually exclusive")
+		}
+		if n.Format != "" {
+			if _, ok := notify.Presets[n.Format]; !ok {
+				fail(key+".format", "unknown format %q", n.Format)
+			}
+		}
+		if n.MaxAttempts < 0 {
+			fail(key+".max_attempts", "must not be negative")
+		}
+	}
+	for i, url := range c.Hooks.Webhooks {
+		if url == "" {
+			fail(fmt.Sprintf("hooks.webhooks[%d]", i), "must not be empty")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func validPattern(pattern string) bool {
+	_, err := filepath.Match(pattern, "")
+	return err == nil
+}
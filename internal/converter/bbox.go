@@ -0,0 +1,57 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBox is a WGS84 bounding box
+type BBox struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+}
+
+// ParseBBox parses a bounding box in the "minLon,minLat,maxLon,maxLat"
+// order used by GeoJSON and OGC APIs
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, fmt.Errorf("bbox must have 4 comma-separated values, got %d", len(parts))
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return BBox{}, fmt.Errorf("invalid bbox value %q", part)
+		}
+		v[i] = f
+	}
+
+	b := BBox{MinLon: v[0], MinLat: v[1], MaxLon: v[2], MaxLat: v[3]}
+	if b.MinLon > b.MaxLon || b.MinLat > b.MaxLat {
+		return BBox{}, fmt.Errorf("bbox minimum exceeds maximum: %s", s)
+	}
+	return b, nil
+}
+
+// Intersects reports whether two bounding boxes overlap
+func (b BBox) Intersects(o BBox) bool {
+	return b.MinLon <= o.MaxLon && o.MinLon <= b.MaxLon &&
+		b.MinLat <= o.MaxLat && o.MinLat <= b.MaxLat
+}
+
+// Bounds returns the bounding box of all points of the track
+func (t Track) Bounds() BBox {
+	b := BBox{MinLon: math.Inf(1), MinLat: math.Inf(1), MaxLon: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, segment := range t.Segments {
+		for _, p := range segment {
+			b.MinLon = math.Min(b.MinLon, p.Lon)
+			b.MinLat = math.Min(b.MinLat, p.Lat)
+			b.MaxLon = math.Max(b.MaxLon, p.Lon)
+			b.MaxLat = math.Max(b.MaxLat, p.Lat)
+		}
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package converter
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"time"
+)
+
+// Output formats
+const (
+	FormatGPX     = "gpx"
+	FormatGeoJSON = "geojson"
+)
+
+// Formats lists the supported output formats; each is also the file extension
+var Formats = []string{FormatGPX, FormatGeoJSON}
+
+// TrackWriter writes tracks into a document one at a time
+type TrackWriter interface {
+	WriteTrack(track Track) error
+	Close() error
+}
+
+// NewTrackWriter starts a document of the given format named name on w
+func NewTrackWriter(format string, w io.Writer, name string, t time.Time) (TrackWriter, error) {
+	switch format {
+	case FormatGPX:
+		return NewGPXWriter(w, name, t)
+	case FormatGeoJSON:
+		return NewGeoJSONWriter(w, name)
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// Output is a file written by SaveTracks
+type Output struct {
+	Path   string
+	Format string
+}
+
+// SaveTracks writes tracks into every output at once, reading the stream
+// only once. The outputs are replaced together: none is replaced if the
+// stream fails or any output is refused, and those already replaced are
+// restored if replacing a later one fails.
+func SaveTracks(tracks iter.Seq2[Track, error], name string, outputs []Output, opts OutputOptions) error {
+	type open struct {
+		file   *AtomicFile
+		writer TrackWriter
+	}
+
+	now := time.Now()
+	files := make([]open, 0, len(outputs))
+	defer func() {
+		for _, f := range files {
+			f.file.Abort()
+		}
+	}()
+
+	for _, out := range outputs {
+		file, err := CreateAtomic(out.Path, opts)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", out.Path, err)
+		}
+		files = append(files, open{file: file})
+
+		writer, err := NewTrackWriter(out.Format, file, name, now)
+		if err != nil {
+			return err
+		}
+		files[len(files)-1].writer = writer
+	}
+
+	count := 0
+	for track, err := range tracks {
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := f.writer.WriteTrack(track); err != nil {
+				return err
+			}
+		}
+		count++
+	}
+
+	for _, f := range files {
+		if err := f.writer.Close(); err != nil {
+			return err
+		}
+	}
+	atomic := make([]*AtomicFile, len(files))
+	for i, f := range files {
+		atomic[i] = f.file
+	}
+	if f, err := commitAll(atomic, count); err != nil {
+		return fmt.Errorf("failed to write %s: %w", f.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	gpxgo "github.com/tkrajina/gpxgo/gpx"
+)
+
+func tracksOf(tracks []Track, err error) iter.Seq2[Track, error] {
+	return func(yield func(Track, error) bool) {
+		for _, track := range tracks {
+			if !yield(track, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(Track{}, err)
+		}
+	}
+}
+
+func TestSaveTracks(t *testing.T) {
+	dir := t.TempDir()
+	outputs := []Output{
+		{Path: filepath.Join(dir, "out.gpx"), Format: FormatGPX},
+		{Path: filepath.Join(dir, "out.geojson"), Format: FormatGeoJSON},
+	}
+	styled := Track{
+		Name:     "A1",
+		Segments: [][]Point{{{Lat: 54.68, Lon: 25.27}, {Lat: 54.69, Lon: 25.28}}},
+		Style:    &Style{Color: "#D62728", Width: 4, Opacity: 0.8},
+	}
+
+	if err := SaveTracks(tracksOf([]Track{styled}, nil), "Test", outputs, OutputOptions{}); err != nil {
+		t.Fatalf("SaveTracks failed: %v", err)
+	}
+
+	gpx, err := os.ReadFile(outputs[0].Path)
+	if err != nil {
+		t.Fatalf("GPX not written: %v", err)
+	}
+	parsed, err := gpxgo.ParseBytes(gpx)
+	if err != nil {
+		t.Fatalf("Invalid GPX: %v", err)
+	}
+	if len(parsed.Tracks) != 1 || len(parsed.Tracks[0].Segments[0].Points) != 2 {
+		t.Errorf("Unexpected GPX content: %+v", parsed.Tracks)
+	}
+	compact := strings.Join(strings.Fields(string(gpx)), "")
+	if !strings.Contains(compact, `<extensions><linexmlns="http://www.topografix.com/GPX/gpx_style/0/2"><color>D62728</color><opacity>0.8</opacity><width>4</width></line></extensions>`) {
+		t.Errorf("Expected a gpx_style line extension, got %s", gpx)
+	}
+
+	content, err := os.ReadFile(outputs[1].Path)
+	if err != nil {
+		t.Fatalf("GeoJSON not written: %v", err)
+	}
+	var collection struct {
+		Features []struct {
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(content, &collection); err != nil {
+		t.Fatalf("Invalid GeoJSON: %v", err)
+	}
+	props := collection.Features[0].Properties
+	if props["stroke"] != "#d62728" || props["stroke-width"] != 4.0 || props["stroke-opacity"] != 0.8 {
+		t.Errorf("Expected simplestyle properties, got %v", props)
+	}
+
+	// A failing stream keeps both existing files
+	upstream := errors.New("upstream failed")
+	if err := SaveTracks(tracksOf([]Track{styled}, upstream), "Test", outputs, OutputOptions{}); !errors.Is(err, upstream) {
+		t.Fatalf("Expected the stream error, got %v", err)
+	}
+	if again, _ := os.ReadFile(outputs[0].Path); string(again) != string(gpx) {
+		t.Error("GPX output was replaced despite the error")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".*.tmp")); len(matches) != 0 {
+		t.Errorf("Temporary files left behind: %v", matches)
+	}
+
+	if err := SaveTracks(tracksOf(nil, nil), "Test", []Output{{Path: filepath.Join(dir, "x.kml"), Format: "kml"}}, OutputOptions{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// The outputs of SaveTracks are replaced together or not at all
+func TestSaveTracksAllOrNothing(t *testing.T) {
+	track := Track{Name: "A1", Segments: [][]Point{{{Lat: 54.68, Lon: 25.27}, {Lat: 54.69, Lon: 25.28}}}}
+	setup := func(t *testing.T) []Output {
+		dir := t.TempDir()
+		outputs := []Output{
+			{Path: filepath.Join(dir, "out.gpx"), Format: FormatGPX},
+			{Path: filepath.Join(dir, "out.geojson"), Format: FormatGeoJSON},
+		}
+		for _, out := range outputs {
+			if err := os.WriteFile(out.Path, []byte("old "+out.Format), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return outputs
+	}
+	expectOld := func(t *testing.T, outputs []Output) {
+		t.Helper()
+		for _, out := range outputs {
+			if content, _ := os.ReadFile(out.Path); string(content) != "old "+out.Format {
+				t.Errorf("%s was replaced: %.40q", out.Format, content)
+			}
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(outputs[0].Path)); len(entries) != len(outputs) {
+			t.Errorf("Expected no leftover files, got %d entries", len(entries))
+		}
+	}
+
+	t.Run("refused", func(t *testing.T) {
+		outputs := setup(t)
+		// Refuse the second output only, after the first passed
+		calls := 0
+		opts := OutputOptions{Validate: func(int) error {
+			if calls++; calls == 2 {
+				return errRejectedByTest
+			}
+			return nil
+		}}
+		if err := SaveTracks(tracksOf([]Track{track}, nil), "Test", outputs, opts); !errors.Is(err, errRejectedByTest) {
+			t.Fatalf("Expected the validator's error, got %v", err)
+		}
+		expectOld(t, outputs)
+	})
+
+	t.Run("rename fails", func(t *testing.T) {
+		outputs := setup(t)
+		// A directory in place of the second output cannot be renamed over
+		if err := os.Remove(outputs[1].Path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(outputs[1].Path, "dir"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveTracks(tracksOf([]Track{track}, nil), "Test", outputs, OutputOptions{Force: true, Backup: true}); err == nil {
+			t.Fatal("Expected an error")
+		}
+		if content, _ := os.ReadFile(outputs[0].Path); string(content) != "old gpx" {
+			t.Errorf("GPX was not restored: %.40q", content)
+		}
+		if _, err := os.Stat(outputs[0].Path + ".bak"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected no backup of a restored output, got %v", err)
+		}
+	})
+
+	t.Run("backup", func(t *testing.T) {
+		outputs := setup(t)
+		if err := SaveTracks(tracksOf([]Track{track}, nil), "Test", outputs, OutputOptions{Force: true, Backup: true}); err != nil {
+			t.Fatalf("SaveTracks failed: %v", err)
+		}
+		for _, out := range outputs {
+			if content, _ := os.ReadFile(out.Path + ".bak"); string(content) != "old "+out.Format {
+				t.Errorf("%s backup: %q", out.Format, content)
+			}
+		}
+		if entries, _ := os.ReadDir(filepath.Dir(outputs[0].Path)); len(entries) != 4 {
+			t.Errorf("Expected the outputs and their backups only, got %d entries", len(entries))
+		}
+	})
+}
+
+func TestStyleValidate(t *testing.T) {
+	valid := []Style{{}, {Color: "#00ff00"}, {Color: "A0B1C2", Width: 3, Opacity: 1}}
+	for _, s := range valid {
+		if err := s.Validate(); err != nil {
+			t.Errorf("Expected %+v to be valid: %v", s, err)
+		}
+	}
+	invalid := []Style{{Color: "red"}, {Color: "#12345"}, {Color: "#12345g"}, {Width: -1}, {Opacity: 1.5}}
+	for _, s := range invalid {
+		if err := s.Validate(); err == nil {
+			t.Errorf("Expected %+v to be invalid", s)
+		}
+	}
+}
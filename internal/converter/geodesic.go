@@ -0,0 +1,91 @@
+package converter
+
+import "math"
+
+// WGS84 ellipsoid
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	wgs84B = wgs84A * (1 - wgs84F)
+)
+
+// Geodesic returns the length in metres of the geodesic from a to b on the
+// WGS84 ellipsoid and its initial bearing in degrees clockwise from north,
+// using Vincenty's inverse formula. It is accurate to well under a
+// millimetre; for nearly antipodal points, where the iteration does not
+// converge, it falls back to the great-circle distance.
+func Geodesic(a, b Point) (distance, bearing float64) {
+	const rad = math.Pi / 180
+	if a == b {
+		return 0, 0
+	}
+
+	l := (b.Lon - a.Lon) * rad
+	u1 := math.Atan((1 - wgs84F) * math.Tan(a.Lat*rad))
+	u2 := math.Atan((1 - wgs84F) * math.Tan(b.Lat*rad))
+	sinU1, cosU1 := math.Sincos(u1)
+	sinU2, cosU2 := math.Sincos(u2)
+
+	lambda := l
+	var sinSigma, cosSigma, sigma, cos2Alpha, cos2SigmaM, sinLambda, cosLambda float64
+	converged := false
+	for range 200 {
+		sinLambda, cosLambda = math.Sincos(lambda)
+		sinSigma = math.Hypot(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda)
+		if sinSigma == 0 {
+			return 0, 0
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cos2Alpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cos2Alpha != 0 {
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cos2Alpha
+		}
+		c := wgs84F / 16 * cos2Alpha * (4 + wgs84F*(4-3*cos2Alpha))
+		prev := lambda
+		lambda = l + (1-c)*wgs84F*sinAlpha*(sigma+c*sinSigma*(cos2SigmaM+c*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prev) < 1e-12 {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return Distance(a, b), sphericalBearing(a, b)
+	}
+
+	u2sq := cos2Alpha * (wgs84A*wgs84A - wgs84B*wgs84B) / (wgs84B * wgs84B)
+	aa := 1 + u2sq/16384*(4096+u2sq*(-768+u2sq*(320-175*u2sq)))
+	bb := u2sq / 1024 * (256 + u2sq*(-128+u2sq*(74-47*u2sq)))
+	deltaSigma := bb * sinSigma * (cos2SigmaM + bb/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		bb/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	distance = wgs84B * aa * (sigma - deltaSigma)
+
+	bearing = math.Atan2(cosU2*sinLambda, cosU1*sinU2-sinU1*cosU2*cosLambda) / rad
+	return distance, math.Mod(bearing+360, 360)
+}
+
+// sphericalBearing returns the initial great-circle bearing from a to b in
+// degrees clockwise from north
+func sphericalBearing(a, b Point) float64 {
+	const rad = math.Pi / 180
+	lat1, lat2 := a.Lat*rad, b.Lat*rad
+	dLon := (b.Lon - a.Lon) * rad
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+	return math.Mod(math.Atan2(y, x)/rad+360, 360)
+}
+
+// GeodesicLength returns the total length of the track's segments in
+// metres on the WGS84 ellipsoid
+func (t Track) GeodesicLength() float64 {
+	var length float64
+	for _, segment := range t.Segments {
+		for i := 1; i < len(segment); i++ {
+			d, _ := Geodesic(segment[i-1], segment[i])
+			length += d
+		}
+	}
+	return length
+}
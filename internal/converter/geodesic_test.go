@@ -0,0 +1,38 @@
+package converter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGeodesic(t *testing.T) {
+	// Vincenty's own example: Flinders Peak to Buninyong
+	flinders := Point{Lat: -(37 + 57/60.0 + 3.72030/3600), Lon: 144 + 25/60.0 + 29.52440/3600}
+	buninyong := Point{Lat: -(37 + 39/60.0 + 10.15610/3600), Lon: 143 + 55/60.0 + 35.38390/3600}
+	d, bearing := Geodesic(flinders, buninyong)
+	if math.Abs(d-54972.271) > 0.001 {
+		t.Errorf("distance = %.4f m, want 54972.271 m", d)
+	}
+	if want := 306 + 52/60.0 + 5.37/3600; math.Abs(bearing-want) > 1e-5 {
+		t.Errorf("bearing = %.6f°, want %.6f°", bearing, want)
+	}
+
+	// Due east along a parallel and due north along a meridian
+	if _, bearing := Geodesic(Point{Lat: 0, Lon: 25}, Point{Lat: 0, Lon: 26}); math.Abs(bearing-90) > 1e-9 {
+		t.Errorf("bearing east = %v", bearing)
+	}
+	if d, bearing := Geodesic(Point{Lat: 54, Lon: 25}, Point{Lat: 55, Lon: 25}); math.Abs(d-111315) > 5 || bearing != 0 {
+		t.Errorf("one degree north = %.0f m, %v°", d, bearing)
+	}
+	if d, _ := Geodesic(Point{Lat: 54, Lon: 25}, Point{Lat: 54, Lon: 25}); d != 0 {
+		t.Errorf("distance to itself = %v", d)
+	}
+}
+
+func TestGeodesicLength(t *testing.T) {
+	track := Track{Segments: [][]Point{{{Lat: 54.6872, Lon: 25.2797}, {Lat: 54.8985, Lon: 23.9036}}}}
+	geodesic, spherical := track.GeodesicLength(), track.Length()
+	if diff := math.Abs(geodesic - spherical); diff == 0 || diff > 0.005*spherical {
+		t.Errorf("geodesic %.0f m, spherical %.0f m", geodesic, spherical)
+	}
+}
@@ -0,0 +1,92 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// GeoJSONWriter writes a GeoJSON FeatureCollection feature by feature, so
+// that no more than one track is held in memory regardless of the output size
+type GeoJSONWriter struct {
+	w        *bufio.Writer
+	features int
+}
+
+type geoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// NewGeoJSONWriter writes the opening of a FeatureCollection with the given
+// name to w and returns a writer for the features
+func NewGeoJSONWriter(w io.Writer, name string) (*GeoJSONWriter, error) {
+	nameJSON, err := json.Marshal(name)
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`{"type":"FeatureCollection","name":`)
+	bw.Write(nameJSON)
+	bw.WriteString(`,"features":[`)
+
+	return &GeoJSONWriter{w: bw}, nil
+}
+
+// WriteTrack writes a track as a MultiLineString feature. Coordinates are
+// written in GeoJSON order: longitude, latitude.
+func (g *GeoJSONWriter) WriteTrack(track Track) error {
+	feature := geoJSONFeature{
+		Type: "Feature",
+		Geometry: geoJSONGeometry{
+			Type:        "MultiLineString",
+			Coordinates: make([][][2]float64, len(track.Segments)),
+		},
+		Properties: make(map[string]any, len(track.Properties)+2),
+	}
+	for name, value := range track.Properties {
+		feature.Properties[name] = value
+	}
+	track.Style.geoJSON(feature.Properties)
+	feature.Properties["name"] = track.Name
+	if track.Description != "" {
+		feature.Properties["description"] = track.Description
+	}
+	for i, segment := range track.Segments {
+		line := make([][2]float64, len(segment))
+		for j, p := range segment {
+			line[j] = [2]float64{p.Lon, p.Lat}
+		}
+		feature.Geometry.Coordinates[i] = line
+	}
+
+	featureJSON, err := json.Marshal(feature)
+	if err != nil {
+		return fmt.Errorf("failed to write GeoJSON feature: %w", err)
+	}
+
+	if g.features > 0 {
+		g.w.WriteByte(',')
+	}
+	g.w.WriteByte('\n')
+	if _, err := g.w.Write(featureJSON); err != nil {
+		return fmt.Errorf("failed to write GeoJSON feature: %w", err)
+	}
+	g.features++
+	return nil
+}
+
+// Close writes the end of the FeatureCollection and flushes buffered output.
+// It does not close the underlying writer.
+func (g *GeoJSONWriter) Close() error {
+	g.w.WriteString("\n]}\n")
+	return g.w.Flush()
+}
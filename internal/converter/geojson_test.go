@@ -0,0 +1,76 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	gw, err := NewGeoJSONWriter(&buf, "Sections")
+	if err != nil {
+		t.Fatalf("Failed to create GeoJSON writer: %v", err)
+	}
+	if err := gw.WriteTrack(Track{Name: `A1 "Vilnius"`, Segments: [][]Point{{{Lat: 54.69, Lon: 25.05}, {Lat: 54.70, Lon: 25.06}}}}); err != nil {
+		t.Fatalf("Failed to write track: %v", err)
+	}
+	if err := gw.WriteTrack(Track{Name: "Second", Segments: [][]Point{{{Lat: 55.0, Lon: 24.0}}}}); err != nil {
+		t.Fatalf("Failed to write track: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to close GeoJSON writer: %v", err)
+	}
+
+	var collection struct {
+		Type     string `json:"type"`
+		Name     string `json:"name"`
+		Features []struct {
+			Geometry struct {
+				Type        string         `json:"type"`
+				Coordinates [][][2]float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]string `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("Failed to parse GeoJSON: %v\n%s", err, buf.String())
+	}
+
+	if collection.Type != "FeatureCollection" || collection.Name != "Sections" || len(collection.Features) != 2 {
+		t.Fatalf("Unexpected collection: %+v", collection)
+	}
+	first := collection.Features[0]
+	if first.Properties["name"] != `A1 "Vilnius"` || first.Geometry.Type != "MultiLineString" {
+		t.Errorf("Unexpected first feature: %+v", first)
+	}
+	// GeoJSON uses [lon, lat] order
+	if c := first.Geometry.Coordinates[0][0]; c != [2]float64{25.05, 54.69} {
+		t.Errorf("Expected [lon, lat] = [25.05, 54.69], got %v", c)
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	b, err := ParseBBox("20.9, 53.8,26.9,56.5")
+	if err != nil {
+		t.Fatalf("Failed to parse bbox: %v", err)
+	}
+	if b != (BBox{MinLon: 20.9, MinLat: 53.8, MaxLon: 26.9, MaxLat: 56.5}) {
+		t.Errorf("Unexpected bbox: %+v", b)
+	}
+
+	for _, invalid := range []string{"", "1,2,3", "a,b,c,d", "5,5,1,1", "NaN,1,2,3"} {
+		if _, err := ParseBBox(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+
+	track := Track{Segments: [][]Point{{{Lat: 54.6, Lon: 25.2}, {Lat: 54.7, Lon: 25.3}}}}
+	if !b.Intersects(track.Bounds()) {
+		t.Error("Track inside Lithuania should intersect its bbox")
+	}
+	if (BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1}).Intersects(track.Bounds()) {
+		t.Error("Track should not intersect a distant bbox")
+	}
+}
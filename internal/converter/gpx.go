@@ -3,126 +3,110 @@ package converter
 
 import (
 	"fmt"
-	"os"
+	"io"
+	"iter"
 	"time"
 
 	"github.com/dimchansky/lt-road-info/internal/data"
-	"github.com/dimchansky/lt-road-info/internal/transform"
-	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// Document names of the generated files
+const (
+	RestrictionsTitle = "Lithuanian Road Restrictions"
+	SpeedControlTitle = "Lithuanian Speed Control Sections"
 )
 
 // EALToGPX converts EAL data to GPX format and saves to file
 func EALToGPX(layers []data.EALLayer, outputPath string) error {
-	// Create GPX
-	gpxData := gpx.GPX{
-		Version: "1.1",
-		Creator: "lt-road-info",
-		Name:    "Lithuanian Road Restrictions",
-		Time:    &time.Time{},
-	}
-	*gpxData.Time = time.Now()
-
-	// Process all features from all layers
-	for _, layer := range layers {
-		for _, feature := range layer.Features {
-			// Process each restriction within the feature
-			for _, restriction := range feature.Restrictions {
-				track := gpx.GPXTrack{
-					Name: fmt.Sprintf("%s - %s", feature.Name, getRestrictionDescription(restriction)),
-				}
-
-				// Process all paths in the restriction
-				for _, path := range restriction.Lines.Paths {
-					segment := gpx.GPXTrackSegment{}
-
-					// Convert coordinates to GPX points
-					for _, coord := range path {
-						if len(coord) >= 2 {
-							lat, lon := transform.LKS94ToWGS84(coord[0], coord[1])
-							segment.Points = append(segment.Points, gpx.GPXPoint{
-								Point: gpx.Point{
-									Latitude:  lat,
-									Longitude: lon,
-								},
-							})
-						}
-					}
-
-					if len(segment.Points) > 0 {
-						track.Segments = append(track.Segments, segment)
-					}
-				}
-
-				if len(track.Segments) > 0 {
-					gpxData.Tracks = append(gpxData.Tracks, track)
-				}
-			}
-		}
-	}
+	return EALFeaturesToGPX(data.EALFeaturesOf(layers), outputPath)
+}
+
+// EALFeaturesToGPX converts a stream of EAL features to GPX format and saves
+// to file using the default OutputOptions
+func EALFeaturesToGPX(features iter.Seq2[data.EALFeature, error], outputPath string) error {
+	return EALFeaturesToGPXWithOptions(features, outputPath, OutputOptions{})
+}
+
+// EALFeaturesToGPXWithOptions converts a stream of EAL features to GPX format
+// and atomically replaces outputPath. An existing file is kept if the stream
+// yields an error or the result is refused by the OutputOptions checks.
+func EALFeaturesToGPXWithOptions(features iter.Seq2[data.EALFeature, error], outputPath string, opts OutputOptions) error {
+	return saveGPX(outputPath, opts, RestrictionsTitle, EALTracks(features))
+}
 
-	// Save to file
-	return saveGPX(gpxData, outputPath)
+// WriteEALGPX converts a stream of EAL features to GPX format and writes it
+// incrementally to w
+func WriteEALGPX(w io.Writer, features iter.Seq2[data.EALFeature, error]) error {
+	_, err := writeGPX(w, RestrictionsTitle, EALTracks(features))
+	return err
 }
 
 // ArcGISToGPX converts ArcGIS speed control data to GPX format
 func ArcGISToGPX(features []data.ArcGISFeature, outputPath string) error {
-	// Create GPX
-	gpxData := gpx.GPX{
-		Version: "1.1",
-		Creator: "lt-road-info",
-		Name:    "Lithuanian Speed Control Sections",
-		Time:    &time.Time{},
-	}
-	*gpxData.Time = time.Now()
+	return ArcGISFeaturesToGPX(data.ArcGISFeaturesOf(features), outputPath)
+}
 
-	// Process features
-	for i, feature := range features {
-		track := gpx.GPXTrack{
-			Name: fmt.Sprintf("Speed Control Section %d", i+1),
-		}
+// ArcGISFeaturesToGPX converts a stream of ArcGIS speed control features to
+// GPX format and saves to file using the default OutputOptions
+func ArcGISFeaturesToGPX(features iter.Seq2[data.ArcGISFeature, error], outputPath string) error {
+	return ArcGISFeaturesToGPXWithOptions(features, outputPath, OutputOptions{})
+}
 
-		// Add description if available
-		if desc := getArcGISFeatureDescription(feature); desc != "" {
-			track.Name = fmt.Sprintf("%s - %s", track.Name, desc)
-		}
+// ArcGISFeaturesToGPXWithOptions converts a stream of ArcGIS speed control
+// features to GPX format and atomically replaces outputPath. An existing file
+// is kept if the stream yields an error or the result is refused by the
+// OutputOptions checks.
+func ArcGISFeaturesToGPXWithOptions(features iter.Seq2[data.ArcGISFeature, error], outputPath string, opts OutputOptions) error {
+	return saveGPX(outputPath, opts, SpeedControlTitle, ArcGISTracks(features))
+}
 
-		// Process geometry paths
-		for _, path := range feature.Geometry.Paths {
-			segment := gpx.GPXTrackSegment{}
-
-			for _, coord := range path {
-				if len(coord) >= 2 {
-					lat, lon := transform.LKS94ToWGS84(coord[0], coord[1])
-					segment.Points = append(segment.Points, gpx.GPXPoint{
-						Point: gpx.Point{
-							Latitude:  lat,
-							Longitude: lon,
-						},
-					})
-				}
-			}
-
-			if len(segment.Points) > 0 {
-				track.Segments = append(track.Segments, segment)
-			}
-		}
+// WriteArcGISGPX converts a stream of ArcGIS speed control features to GPX
+// format and writes it incrementally to w
+func WriteArcGISGPX(w io.Writer, features iter.Seq2[data.ArcGISFeature, error]) error {
+	_, err := writeGPX(w, SpeedControlTitle, ArcGISTracks(features))
+	return err
+}
 
-		if len(track.Segments) > 0 {
-			gpxData.Tracks = append(gpxData.Tracks, track)
+// TracksToGPX writes already converted tracks as a GPX document named name
+// and atomically replaces outputPath, like EALFeaturesToGPXWithOptions
+func TracksToGPX(tracks iter.Seq2[Track, error], name, outputPath string, opts OutputOptions) error {
+	return saveGPX(outputPath, opts, name, tracks)
+}
+
+// writeGPX writes tracks as a GPX document and returns the number of tracks
+func writeGPX(w io.Writer, name string, tracks iter.Seq2[Track, error]) (int, error) {
+	gw, err := NewGPXWriter(w, name, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to write GPX header: %w", err)
+	}
+
+	count := 0
+	for track, err := range tracks {
+		if err != nil {
+			return count, err
+		}
+		if err := gw.WriteTrack(track); err != nil {
+			return count, err
 		}
+		count++
 	}
 
-	// Save to file
-	return saveGPX(gpxData, outputPath)
+	return count, gw.Close()
 }
 
-func saveGPX(gpxData gpx.GPX, outputPath string) error {
-	xmlBytes, err := gpxData.ToXml(gpx.ToXmlParams{Version: "1.1", Indent: true})
+func saveGPX(outputPath string, opts OutputOptions, name string, tracks iter.Seq2[Track, error]) error {
+	f, err := CreateAtomic(outputPath, opts)
+	if err != nil {
+		return fmt.Errorf("failed to create GPX file: %w", err)
+	}
+	defer f.Abort()
+
+	count, err := writeGPX(f, name, tracks)
 	if err != nil {
-		return fmt.Errorf("failed to generate GPX XML: %w", err)
+		return err
 	}
 
-	if err := os.WriteFile(outputPath, xmlBytes, 0644); err != nil {
+	if err := f.Commit(count); err != nil {
 		return fmt.Errorf("failed to write GPX file: %w", err)
 	}
 
@@ -0,0 +1,81 @@
+package converter
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// GPXWriter writes a GPX 1.1 document track by track, so that no more than
+// one track is held in memory regardless of the size of the output
+type GPXWriter struct {
+	w   *bufio.Writer
+	enc *xml.Encoder
+}
+
+type gpxTrack struct {
+	XMLName    xml.Name       `xml:"trk"`
+	Name       string         `xml:"name,omitempty"`
+	Desc       string         `xml:"desc,omitempty"`
+	Extensions *gpxExtensions `xml:"extensions,omitempty"`
+	Segments   []gpxSegment   `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// NewGPXWriter writes the GPX header with the given document name and time
+// to w and returns a writer for the tracks
+func NewGPXWriter(w io.Writer, name string, t time.Time) (*GPXWriter, error) {
+	bw := bufio.NewWriter(w)
+
+	bw.WriteString(xml.Header)
+	bw.WriteString(`<gpx xmlns="http://www.topografix.com/GPX/1/1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ` +
+		`xsi:schemaLocation="http://www.topografix.com/GPX/1/1 http://www.topografix.com/GPX/1/1/gpx.xsd" ` +
+		`version="1.1" creator="lt-road-info">` + "\n")
+	bw.WriteString("\t<metadata>\n\t\t<name>")
+	if err := xml.EscapeText(bw, []byte(name)); err != nil {
+		return nil, err
+	}
+	bw.WriteString("</name>\n\t\t<time>" + t.UTC().Format(time.RFC3339) + "</time>\n\t</metadata>\n")
+
+	enc := xml.NewEncoder(bw)
+	enc.Indent("\t", "\t")
+
+	return &GPXWriter{w: bw, enc: enc}, nil
+}
+
+// WriteTrack writes a single track
+func (g *GPXWriter) WriteTrack(track Track) error {
+	trk := gpxTrack{Name: track.Name, Desc: track.Description, Extensions: track.Style.gpx()}
+	for _, segment := range track.Segments {
+		seg := gpxSegment{Points: make([]gpxPoint, len(segment))}
+		for i, p := range segment {
+			seg.Points[i] = gpxPoint{Lat: p.Lat, Lon: p.Lon}
+		}
+		trk.Segments = append(trk.Segments, seg)
+	}
+
+	if err := g.enc.Encode(trk); err != nil {
+		return fmt.Errorf("failed to write GPX track: %w", err)
+	}
+	return nil
+}
+
+// Close writes the closing tag and flushes buffered output. It does not
+// close the underlying writer.
+func (g *GPXWriter) Close() error {
+	if err := g.enc.Flush(); err != nil {
+		return err
+	}
+	g.w.WriteString("\n</gpx>\n")
+	return g.w.Flush()
+}
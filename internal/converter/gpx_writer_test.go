@@ -0,0 +1,90 @@
+package converter
+
+import (
+	"bytes"
+	"errors"
+	"iter"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+func TestGPXWriter(t *testing.T) {
+	var buf bytes.Buffer
+
+	gw, err := NewGPXWriter(&buf, "Roads <&> Sections", time.Date(2025, 6, 9, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Failed to create GPX writer: %v", err)
+	}
+
+	tracks := []Track{
+		{Name: "A1 & A2", Segments: [][]Point{{{Lat: 54.69, Lon: 25.05}, {Lat: 54.70, Lon: 25.06}}}},
+		{Name: "Second", Segments: [][]Point{{{Lat: 55.0, Lon: 24.0}}, {{Lat: 55.1, Lon: 24.1}}}},
+	}
+	for _, track := range tracks {
+		if err := gw.WriteTrack(track); err != nil {
+			t.Fatalf("Failed to write track: %v", err)
+		}
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to close GPX writer: %v", err)
+	}
+
+	parsed, err := gpx.ParseBytes(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse written GPX: %v\n%s", err, buf.String())
+	}
+
+	if parsed.Name != "Roads <&> Sections" {
+		t.Errorf("Expected escaped document name to round-trip, got %q", parsed.Name)
+	}
+	if len(parsed.Tracks) != len(tracks) {
+		t.Fatalf("Expected %d tracks, got %d", len(tracks), len(parsed.Tracks))
+	}
+	if parsed.Tracks[0].Name != "A1 & A2" {
+		t.Errorf("Expected track name %q, got %q", "A1 & A2", parsed.Tracks[0].Name)
+	}
+	if len(parsed.Tracks[1].Segments) != 2 {
+		t.Errorf("Expected 2 segments in second track, got %d", len(parsed.Tracks[1].Segments))
+	}
+	if p := parsed.Tracks[0].Segments[0].Points[1]; p.Latitude != 54.70 || p.Longitude != 25.06 {
+		t.Errorf("Expected point [54.70, 25.06], got [%f, %f]", p.Latitude, p.Longitude)
+	}
+}
+
+func TestEALFeaturesToGPXKeepsExistingFileOnError(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "restrictions.gpx")
+	if err := os.WriteFile(outputPath, []byte("previous"), 0644); err != nil {
+		t.Fatalf("Failed to write existing file: %v", err)
+	}
+
+	upstreamErr := errors.New("connection reset")
+	features := func(yield func(data.EALFeature, error) bool) {
+		if !yield(data.EALFeature{Name: "Partial"}, nil) {
+			return
+		}
+		yield(data.EALFeature{}, upstreamErr)
+	}
+
+	err := EALFeaturesToGPX(iter.Seq2[data.EALFeature, error](features), outputPath)
+	if !errors.Is(err, upstreamErr) {
+		t.Fatalf("Expected upstream error, got %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if string(content) != "previous" {
+		t.Errorf("Existing file should be kept, got %q", content)
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(outputPath))
+	if len(entries) != 1 {
+		t.Errorf("Temporary files should be cleaned up, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,24 @@
+package converter
+
+import "math"
+
+// Distance returns the great-circle distance between two points in metres
+func Distance(a, b Point) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Lon - a.Lon) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Length returns the total length of the track's segments in metres
+func (t Track) Length() float64 {
+	var length float64
+	for _, segment := range t.Segments {
+		for i := 1; i < len(segment); i++ {
+			length += Distance(segment[i-1], segment[i])
+		}
+	}
+	return length
+}
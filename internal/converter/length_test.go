@@ -0,0 +1,22 @@
+package converter
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTrackLength(t *testing.T) {
+	// One degree of latitude is about 111.2 km
+	track := Track{Segments: [][]Point{
+		{{Lat: 54, Lon: 25}, {Lat: 55, Lon: 25}},
+		{{Lat: 55, Lon: 24}},
+	}}
+	if got := track.Length(); math.Abs(got-111195) > 10 {
+		t.Errorf("Length() = %.0f m, want about 111195 m", got)
+	}
+
+	// Vilnius to Kaunas is about 92 km as the crow flies
+	if got := Distance(Point{Lat: 54.6872, Lon: 25.2797}, Point{Lat: 54.8985, Lon: 23.9036}); math.Abs(got-91500) > 1500 {
+		t.Errorf("Distance(Vilnius, Kaunas) = %.0f m", got)
+	}
+}
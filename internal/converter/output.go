@@ -0,0 +1,251 @@
+package converter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+)
+
+// DefaultMinSizeRatio is the smallest size, relative to the file it
+// replaces, that a generated file may have without being forced
+const DefaultMinSizeRatio = 0.2
+
+// ErrSuspiciousOutput is returned when a generated file would replace an
+// existing one with an empty or suspiciously small dataset
+var ErrSuspiciousOutput = errors.New("refusing to replace existing output")
+
+// OutputOptions control how generated files replace existing ones
+type OutputOptions struct {
+	// Backup keeps the replaced file as <name>.bak
+	Backup bool
+
+	// Force replaces an existing file even with an empty or suspiciously
+	// small dataset
+	Force bool
+
+	// MinSizeRatio overrides DefaultMinSizeRatio when positive
+	MinSizeRatio float64
+
+	// Validate, if set, is called with the number of items written before
+	// anything is replaced. An error keeps the existing file, even with Force.
+	Validate func(items int) error
+}
+
+// AtomicFile is an output file that is written to a temporary file in the
+// destination directory and only renamed over the destination on Commit.
+// Readers of the destination therefore never see a partially written file.
+type AtomicFile struct {
+	path string
+	tmp  *os.File
+	opts OutputOptions
+	done bool
+
+	// Set by prepare and replace
+	size    int64
+	items   int
+	existed bool
+	old     string
+}
+
+// CreateAtomic starts writing a new version of path
+func CreateAtomic(path string, opts OutputOptions) (*AtomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &AtomicFile{path: path, tmp: tmp, opts: opts}, nil
+}
+
+// Write writes to the temporary file
+func (f *AtomicFile) Write(p []byte) (int, error) {
+	return f.tmp.Write(p)
+}
+
+// Commit replaces the destination with the written content. items is the
+// number of tracks or features written; an empty dataset or a file much
+// smaller than the one it replaces is refused with ErrSuspiciousOutput
+// unless OutputOptions.Force is set. The temporary file is removed on error.
+func (f *AtomicFile) Commit(items int) error {
+	_, err := commitAll([]*AtomicFile{f}, items)
+	return err
+}
+
+// commitAll commits files as one: every file is checked before any is
+// replaced, and when replacing one fails, those already replaced are
+// restored, so that readers never see new and old files mixed. It returns
+// the file that failed. All temporary files are removed on error.
+func commitAll(files []*AtomicFile, items int) (*AtomicFile, error) {
+	for _, f := range files {
+		defer f.Abort()
+		if f.done {
+			return f, errors.New("output already committed or aborted")
+		}
+	}
+	for _, f := range files {
+		if err := f.prepare(items); err != nil {
+			return f, err
+		}
+	}
+
+	for i, f := range files {
+		// Every file but the last keeps its old version until all are
+		// replaced, so that it can be restored
+		if err := f.replace(i < len(files)-1); err != nil {
+			errs := []error{err}
+			for j := i - 1; j >= 0; j-- {
+				if err := files[j].restore(); err != nil {
+					errs = append(errs, fmt.Errorf("failed to restore %s: %w", files[j].path, err))
+				}
+			}
+			return f, errors.Join(errs...)
+		}
+	}
+	for _, f := range files {
+		f.finish()
+	}
+	return nil, nil
+}
+
+// prepare checks the written content and closes the temporary file
+func (f *AtomicFile) prepare(items int) error {
+	if f.opts.Validate != nil {
+		if err := f.opts.Validate(items); err != nil {
+			return err
+		}
+	}
+
+	if err := f.tmp.Sync(); err != nil {
+		return err
+	}
+	info, err := f.tmp.Stat()
+	if err != nil {
+		return err
+	}
+	if err := f.tmp.Close(); err != nil {
+		return err
+	}
+	f.size, f.items = info.Size(), items
+
+	existing, err := os.Stat(f.path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return os.Chmod(f.tmp.Name(), 0644)
+	case err != nil:
+		return err
+	}
+	f.existed = true
+
+	if !f.opts.Force {
+		if err := f.checkReplacement(existing.Size(), info.Size(), items); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(f.tmp.Name(), 0644)
+}
+
+// replace renames the temporary file over the destination. The old version
+// is kept aside for restore if keep is set, and for the backup if wanted.
+func (f *AtomicFile) replace(keep bool) error {
+	if f.existed && (keep || f.opts.Backup) {
+		f.old = f.tmp.Name() + ".old"
+		if err := backup(f.path, f.old); err != nil {
+			f.old = ""
+			return fmt.Errorf("failed to back up %s: %w", f.path, err)
+		}
+	}
+	if err := os.Rename(f.tmp.Name(), f.path); err != nil {
+		if f.old != "" {
+			os.Remove(f.old)
+		}
+		return err
+	}
+	f.done = true
+	return nil
+}
+
+// restore puts the version replaced by replace back
+func (f *AtomicFile) restore() error {
+	if !f.existed {
+		return os.Remove(f.path)
+	}
+	if f.old == "" {
+		return fmt.Errorf("no previous version of %s kept", f.path)
+	}
+	return os.Rename(f.old, f.path)
+}
+
+// finish keeps the replaced version as the backup, if wanted, or removes it
+func (f *AtomicFile) finish() {
+	slog.Debug("Wrote output", "path", f.path, "bytes", f.size, "items", f.items)
+	if f.old == "" {
+		return
+	}
+	if !f.opts.Backup {
+		os.Remove(f.old)
+		return
+	}
+	bak := f.path + ".bak"
+	if err := os.Remove(bak); err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Failed to replace backup", "path", bak, slog.Group("error", slog.String("message", err.Error())))
+	}
+	if err := os.Rename(f.old, bak); err != nil {
+		slog.Warn("Failed to keep backup", "path", bak, slog.Group("error", slog.String("message", err.Error())))
+		os.Remove(f.old)
+	}
+}
+
+// Abort discards the written content. It is a no-op after Commit.
+func (f *AtomicFile) Abort() {
+	if f.done {
+		return
+	}
+	f.done = true
+	f.tmp.Close()
+	os.Remove(f.tmp.Name())
+}
+
+func (f *AtomicFile) checkReplacement(oldSize, newSize int64, items int) error {
+	if items == 0 {
+		return fmt.Errorf("%w %s with an empty dataset", ErrSuspiciousOutput, f.path)
+	}
+
+	ratio := f.opts.MinSizeRatio
+	if ratio <= 0 {
+		ratio = DefaultMinSizeRatio
+	}
+	if float64(newSize) < ratio*float64(oldSize) {
+		return fmt.Errorf("%w %s (%d bytes) with %d bytes, less than %.0f%% of its size",
+			ErrSuspiciousOutput, f.path, oldSize, newSize, ratio*100)
+	}
+	return nil
+}
+
+// backup replaces bakPath with the current content of path. A hard link is
+// used where possible so that the rotation costs no extra I/O.
+func backup(path, bakPath string) error {
+	if err := os.Remove(bakPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(path, bakPath); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(bakPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
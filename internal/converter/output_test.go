@@ -0,0 +1,143 @@
+package converter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var errRejectedByTest = errors.New("rejected by test validator")
+
+func TestAtomicFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		content     string
+		items       int
+		opts        OutputOptions
+		wantErr     error
+		wantContent string
+		wantBackup  string
+	}{
+		{
+			name:        "new file",
+			content:     "new",
+			items:       1,
+			wantContent: "new",
+		},
+		{
+			name:        "new empty file",
+			content:     "",
+			items:       0,
+			wantContent: "",
+		},
+		{
+			name:        "replace with backup",
+			existing:    "old content",
+			content:     "new content",
+			items:       3,
+			opts:        OutputOptions{Backup: true},
+			wantContent: "new content",
+			wantBackup:  "old content",
+		},
+		{
+			name:        "refuse empty dataset",
+			existing:    "old content",
+			content:     "header only",
+			items:       0,
+			opts:        OutputOptions{Backup: true},
+			wantErr:     ErrSuspiciousOutput,
+			wantContent: "old content",
+		},
+		{
+			name:        "refuse much smaller file",
+			existing:    strings.Repeat("x", 1000),
+			content:     strings.Repeat("y", 100),
+			items:       1,
+			wantErr:     ErrSuspiciousOutput,
+			wantContent: strings.Repeat("x", 1000),
+		},
+		{
+			name:        "custom size ratio",
+			existing:    strings.Repeat("x", 1000),
+			content:     strings.Repeat("y", 100),
+			items:       1,
+			opts:        OutputOptions{MinSizeRatio: 0.05},
+			wantContent: strings.Repeat("y", 100),
+		},
+		{
+			name:        "validator rejects even when forced",
+			existing:    "old content",
+			content:     "new content",
+			items:       1,
+			opts:        OutputOptions{Force: true, Validate: func(int) error { return errRejectedByTest }},
+			wantErr:     errRejectedByTest,
+			wantContent: "old content",
+		},
+		{
+			name:        "force empty dataset",
+			existing:    "old content",
+			content:     "",
+			items:       0,
+			opts:        OutputOptions{Force: true},
+			wantContent: "",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "out.gpx")
+			if tc.existing != "" {
+				if err := os.WriteFile(path, []byte(tc.existing), 0644); err != nil {
+					t.Fatalf("Failed to write existing file: %v", err)
+				}
+			}
+
+			f, err := CreateAtomic(path, tc.opts)
+			if err != nil {
+				t.Fatalf("Failed to create atomic file: %v", err)
+			}
+			if _, err := f.Write([]byte(tc.content)); err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+
+			// Nothing is visible before the commit
+			if tc.existing == "" {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("Destination should not exist before commit, stat error: %v", err)
+				}
+			}
+
+			err = f.Commit(tc.items)
+			if !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Expected error %v, got %v", tc.wantErr, err)
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("Failed to read destination: %v", err)
+			}
+			if string(content) != tc.wantContent {
+				t.Errorf("Expected content %q, got %q", tc.wantContent, content)
+			}
+
+			backup, err := os.ReadFile(path + ".bak")
+			switch {
+			case tc.wantBackup == "" && err == nil:
+				t.Errorf("Unexpected backup file with content %q", backup)
+			case tc.wantBackup != "" && string(backup) != tc.wantBackup:
+				t.Errorf("Expected backup %q, got %q (error: %v)", tc.wantBackup, backup, err)
+			}
+
+			entries, _ := os.ReadDir(dir)
+			for _, entry := range entries {
+				if strings.HasSuffix(entry.Name(), ".tmp") {
+					t.Errorf("Temporary file %s was left behind", entry.Name())
+				}
+			}
+		})
+	}
+}
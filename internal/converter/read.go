@@ -0,0 +1,126 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// ReadTracks reads a GPX or GeoJSON document, such as one written by this
+// package, telling the formats apart by their first character. GeoJSON
+// properties other than the name are kept; GPX only carries names and
+// geometry.
+func ReadTracks(r io.Reader) ([]Track, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return nil, fmt.Errorf("empty document")
+	case trimmed[0] == '<':
+		return readGPX(content)
+	case trimmed[0] == '{':
+		return readGeoJSON(content)
+	}
+	return nil, fmt.Errorf("unrecognized document: expected GPX or GeoJSON")
+}
+
+// ReadFile reads the GPX or GeoJSON file at path
+func ReadFile(path string) ([]Track, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tracks, err := ReadTracks(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return tracks, nil
+}
+
+func readGPX(content []byte) ([]Track, error) {
+	doc, err := gpx.ParseBytes(content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse GPX: %w", err)
+	}
+
+	tracks := make([]Track, 0, len(doc.Tracks))
+	for _, t := range doc.Tracks {
+		track := Track{Name: t.Name, Description: t.Description}
+		for _, s := range t.Segments {
+			segment := make([]Point, len(s.Points))
+			for i, p := range s.Points {
+				segment[i] = Point{Lat: p.Latitude, Lon: p.Longitude}
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
+
+func readGeoJSON(content []byte) ([]Track, error) {
+	var doc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]any `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse GeoJSON: %w", err)
+	}
+	if doc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("expected a GeoJSON FeatureCollection, got %q", doc.Type)
+	}
+
+	tracks := make([]Track, 0, len(doc.Features))
+	for i, f := range doc.Features {
+		var lines [][][2]float64
+		switch f.Geometry.Type {
+		case "LineString":
+			var line [][2]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &line); err != nil {
+				return nil, fmt.Errorf("feature %d: invalid coordinates: %w", i, err)
+			}
+			lines = [][][2]float64{line}
+		case "MultiLineString":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &lines); err != nil {
+				return nil, fmt.Errorf("feature %d: invalid coordinates: %w", i, err)
+			}
+		default:
+			return nil, fmt.Errorf("feature %d: unsupported geometry %q", i, f.Geometry.Type)
+		}
+
+		track := Track{Properties: f.Properties}
+		if name, ok := f.Properties["name"].(string); ok {
+			track.Name = name
+			delete(track.Properties, "name")
+		}
+		if desc, ok := f.Properties["description"].(string); ok {
+			track.Description = desc
+			delete(track.Properties, "description")
+		}
+		for _, line := range lines {
+			segment := make([]Point, len(line))
+			for j, c := range line {
+				segment[j] = Point{Lat: c[1], Lon: c[0]}
+			}
+			track.Segments = append(track.Segments, segment)
+		}
+		tracks = append(tracks, track)
+	}
+	return tracks, nil
+}
@@ -0,0 +1,65 @@
+package converter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadTracksRoundTrip(t *testing.T) {
+	tracks := []Track{
+		{
+			Name:       "A1 & A2",
+			Segments:   [][]Point{{{Lat: 54.69, Lon: 25.05}, {Lat: 54.70, Lon: 25.06}}},
+			Properties: map[string]any{"id": "r1", "type": "Darbai"},
+		},
+		{Name: "Second", Description: "Length 3.19 km", Segments: [][]Point{{{Lat: 55.0, Lon: 24.0}, {Lat: 55.1, Lon: 24.1}}, {{Lat: 55.2, Lon: 24.2}, {Lat: 55.3, Lon: 24.3}}}},
+	}
+
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		w, err := NewTrackWriter(format, &buf, "Test", time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, track := range tracks {
+			if err := w.WriteTrack(track); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := ReadTracks(&buf)
+		if err != nil {
+			t.Fatalf("%s: ReadTracks failed: %v", format, err)
+		}
+		if len(got) != len(tracks) {
+			t.Fatalf("%s: got %d tracks, want %d", format, len(got), len(tracks))
+		}
+		for i, track := range got {
+			if track.Name != tracks[i].Name {
+				t.Errorf("%s: track %d name = %q, want %q", format, i, track.Name, tracks[i].Name)
+			}
+			if track.Description != tracks[i].Description {
+				t.Errorf("%s: track %d description = %q, want %q", format, i, track.Description, tracks[i].Description)
+			}
+			if len(track.Segments) != len(tracks[i].Segments) || track.Segments[0][1] != tracks[i].Segments[0][1] {
+				t.Errorf("%s: track %d segments = %v", format, i, track.Segments)
+			}
+		}
+		if format == FormatGeoJSON && got[0].Properties["id"] != "r1" {
+			t.Errorf("geojson: properties not kept: %v", got[0].Properties)
+		}
+	}
+}
+
+func TestReadTracksRejectsUnknown(t *testing.T) {
+	for _, doc := range []string{"", "plain text", `{"type":"Feature"}`} {
+		if _, err := ReadTracks(strings.NewReader(doc)); err == nil {
+			t.Errorf("ReadTracks(%q) succeeded", doc)
+		}
+	}
+}
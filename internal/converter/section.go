@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Section holds the figures of a speed control section, where the average
+// speed between entry and exit is enforced
+type Section struct {
+	// Length is the geodesic length of the path in metres
+	Length float64
+
+	// Entry and Exit are the ends of the path in its upstream order; a
+	// section enforced in both directions is entered at either end
+	Entry Point
+	Exit  Point
+
+	// Bearing is the initial direction from entry to exit in degrees
+	// clockwise from north
+	Bearing float64
+
+	// SpeedLimit is in km/h, 0 when upstream publishes none
+	SpeedLimit float64
+
+	// MinTransit is the shortest time in which the section can be driven
+	// without exceeding the speed limit on average, rounded up to a whole
+	// second; 0 without a speed limit
+	MinTransit time.Duration
+}
+
+// NewSection computes the figures of a section from its WGS84 path and
+// speed limit in km/h
+func NewSection(segments [][]Point, speedLimit float64) Section {
+	s := Section{Length: Track{Segments: segments}.GeodesicLength(), SpeedLimit: speedLimit}
+	if len(segments) == 0 || len(segments[0]) == 0 {
+		return s
+	}
+	last := segments[len(segments)-1]
+	s.Entry, s.Exit = segments[0][0], last[len(last)-1]
+	_, s.Bearing = Geodesic(s.Entry, s.Exit)
+	if speedLimit > 0 {
+		seconds := math.Ceil(s.Length / (speedLimit / 3.6))
+		s.MinTransit = time.Duration(seconds) * time.Second
+	}
+	return s
+}
+
+// SpeedLimit returns the speed_limit attribute of a speed control feature
+// in km/h, 0 if it has none. The attribute is matched case-insensitively
+// and may be a number or numeric text.
+func SpeedLimit(attributes map[string]any) float64 {
+	for name, value := range attributes {
+		if !strings.EqualFold(name, "speed_limit") {
+			continue
+		}
+		switch v := value.(type) {
+		case float64:
+			return v
+		case int:
+			return float64(v)
+		case string:
+			if n, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// Description summarizes the section for the GPX desc element and the
+// GeoJSON description property, e.g. "Length 3.19 km, 90 km/h: at least
+// 2 min 8 s. Entry 54.69391, 25.05672, exit 54.71512, 25.09123, bearing 47°."
+func (s Section) Description() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Length %.2f km", s.Length/1000)
+	if s.MinTransit > 0 {
+		fmt.Fprintf(&b, ", %g km/h: at least %s", s.SpeedLimit, formatTransit(s.MinTransit))
+	}
+	fmt.Fprintf(&b, ". Entry %.5f, %.5f, exit %.5f, %.5f, bearing %.0f°.",
+		s.Entry.Lat, s.Entry.Lon, s.Exit.Lat, s.Exit.Lon, s.Bearing)
+	return b.String()
+}
+
+// addProperties adds the figures to the properties of a track
+func (s Section) addProperties(props map[string]any) {
+	props["lengthM"] = math.Round(s.Length*10) / 10
+	props["entryLat"], props["entryLon"] = s.Entry.Lat, s.Entry.Lon
+	props["exitLat"], props["exitLon"] = s.Exit.Lat, s.Exit.Lon
+	props["bearing"] = math.Round(s.Bearing*10) / 10
+	if s.MinTransit > 0 {
+		props["minTransitSeconds"] = int(s.MinTransit / time.Second)
+	}
+}
+
+// formatTransit writes a duration as "1 h 2 min 3 s", leaving out leading
+// zero units
+func formatTransit(d time.Duration) string {
+	seconds := int(d / time.Second)
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	switch {
+	case h > 0:
+		return fmt.Sprintf("%d h %d min %d s", h, m, s)
+	case m > 0:
+		return fmt.Sprintf("%d min %d s", m, s)
+	}
+	return fmt.Sprintf("%d s", s)
+}
@@ -0,0 +1,112 @@
+package converter
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/data"
+)
+
+func TestNewSection(t *testing.T) {
+	entry, exit := Point{Lat: 54.0, Lon: 25.0}, Point{Lat: 54.01, Lon: 25.01}
+	segments := [][]Point{{entry, {Lat: 54.005, Lon: 25.0}}, {{Lat: 54.005, Lon: 25.005}, exit}}
+	s := NewSection(segments, 90)
+
+	if want := (Track{Segments: segments}).GeodesicLength(); s.Length != want {
+		t.Errorf("Length = %v, want %v", s.Length, want)
+	}
+	if s.Entry != entry || s.Exit != exit {
+		t.Errorf("Entry %v, Exit %v", s.Entry, s.Exit)
+	}
+	if s.Bearing < 30 || s.Bearing > 32 {
+		t.Errorf("Bearing = %.2f°, want about 31°", s.Bearing)
+	}
+	// 90 km/h is 25 m/s; the time is rounded up to whole seconds
+	if want := time.Duration(math.Ceil(s.Length/25)) * time.Second; s.MinTransit != want {
+		t.Errorf("MinTransit = %v, want %v", s.MinTransit, want)
+	}
+
+	if s := NewSection(segments, 0); s.MinTransit != 0 {
+		t.Errorf("MinTransit without speed limit = %v", s.MinTransit)
+	}
+	if s := NewSection(nil, 90); s != (Section{SpeedLimit: 90}) {
+		t.Errorf("empty section = %+v", s)
+	}
+}
+
+func TestSectionDescription(t *testing.T) {
+	s := Section{
+		Length: 3190, Entry: Point{Lat: 54.693908, Lon: 25.056723}, Exit: Point{Lat: 54.715121, Lon: 25.091234},
+		Bearing: 46.6, SpeedLimit: 90, MinTransit: 128 * time.Second,
+	}
+	if got, want := s.Description(), "Length 3.19 km, 90 km/h: at least 2 min 8 s. Entry 54.69391, 25.05672, exit 54.71512, 25.09123, bearing 47°."; got != want {
+		t.Errorf("Description() = %q, want %q", got, want)
+	}
+	s.SpeedLimit, s.MinTransit = 0, 0
+	if got, want := s.Description(), "Length 3.19 km. Entry 54.69391, 25.05672, exit 54.71512, 25.09123, bearing 47°."; got != want {
+		t.Errorf("Description() = %q, want %q", got, want)
+	}
+}
+
+func TestFormatTransit(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		45 * time.Second:   "45 s",
+		128 * time.Second:  "2 min 8 s",
+		3723 * time.Second: "1 h 2 min 3 s",
+	} {
+		if got := formatTransit(d); got != want {
+			t.Errorf("formatTransit(%v) = %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestSpeedLimit(t *testing.T) {
+	tests := []struct {
+		attributes map[string]any
+		want       float64
+	}{
+		{map[string]any{"speed_limit": float64(90)}, 90},
+		{map[string]any{"SPEED_LIMIT": " 70 "}, 70},
+		{map[string]any{"kelionr": "101", "kryptis": "abiem"}, 0},
+		{map[string]any{"speed_limit": "unknown"}, 0},
+	}
+	for _, tt := range tests {
+		if got := SpeedLimit(tt.attributes); got != tt.want {
+			t.Errorf("SpeedLimit(%v) = %v, want %v", tt.attributes, got, tt.want)
+		}
+	}
+}
+
+func TestArcGISTracksSection(t *testing.T) {
+	features := []data.ArcGISFeature{{
+		Attributes: map[string]any{"objectid": float64(1), "speed_limit": float64(90)},
+		Geometry:   data.ArcGISGeometry{Paths: [][][]float64{{{582000, 6061000}, {582500, 6061500}, {583000, 6062500}}}},
+	}}
+	var got []Track
+	for track, err := range ArcGISTracks(data.ArcGISFeaturesOf(features)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, track)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d tracks", len(got))
+	}
+	props := got[0].Properties
+	length, _ := props["lengthM"].(float64)
+	if math.Abs(length-1825) > 5 {
+		t.Errorf("lengthM = %v, want about 1825", props["lengthM"])
+	}
+	if props["minTransitSeconds"] != int(math.Ceil(length/25)) {
+		t.Errorf("minTransitSeconds = %v", props["minTransitSeconds"])
+	}
+	for _, key := range []string{"entryLat", "entryLon", "exitLat", "exitLon", "bearing"} {
+		if _, ok := props[key].(float64); !ok {
+			t.Errorf("%s missing: %v", key, props)
+		}
+	}
+	if got[0].Description == "" {
+		t.Error("description missing")
+	}
+}
@@ -0,0 +1,77 @@
+package converter
+
+import "math"
+
+// earthRadius is the mean Earth radius in metres
+const earthRadius = 6371008.8
+
+// Simplify returns a copy of track whose segments are reduced with the
+// Douglas-Peucker algorithm: no removed point lies further than tolerance
+// metres from the simplified line. Segment ends are always kept.
+func Simplify(track Track, tolerance float64) Track {
+	if tolerance <= 0 {
+		return track
+	}
+	simplified := track
+	simplified.Segments = make([][]Point, len(track.Segments))
+	for i, segment := range track.Segments {
+		simplified.Segments[i] = simplifySegment(segment, tolerance)
+	}
+	return simplified
+}
+
+func simplifySegment(segment []Point, tolerance float64) []Point {
+	if len(segment) < 3 {
+		return segment
+	}
+
+	// Project to a local equirectangular plane in metres; accurate enough
+	// for the extent of a road section
+	lat0 := segment[0].Lat * math.Pi / 180
+	xy := make([][2]float64, len(segment))
+	for i, p := range segment {
+		xy[i] = [2]float64{
+			p.Lon * math.Pi / 180 * math.Cos(lat0) * earthRadius,
+			p.Lat * math.Pi / 180 * earthRadius,
+		}
+	}
+
+	keep := make([]bool, len(segment))
+	keep[0], keep[len(segment)-1] = true, true
+
+	stack := [][2]int{{0, len(segment) - 1}}
+	for len(stack) > 0 {
+		first, last := stack[len(stack)-1][0], stack[len(stack)-1][1]
+		stack = stack[:len(stack)-1]
+
+		farthest, maxDist := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(xy[i], xy[first], xy[last]); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	result := make([]Point, 0, len(segment))
+	for i, p := range segment {
+		if keep[i] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// segmentDistance returns the distance from p to the segment a-b
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := ((p[0]-a[0])*dx + (p[1]-a[1])*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
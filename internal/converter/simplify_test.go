@@ -0,0 +1,32 @@
+package converter
+
+import "testing"
+
+func TestSimplify(t *testing.T) {
+	// A straight line along a meridian with a 5 m kink in the middle
+	// (0.000045 degrees of longitude is about 2.9 m at 54.7°N, 0.00008 about 5 m)
+	segment := []Point{
+		{Lat: 54.7000, Lon: 25.0000},
+		{Lat: 54.7010, Lon: 25.000045},
+		{Lat: 54.7020, Lon: 25.00008},
+		{Lat: 54.7030, Lon: 25.000045},
+		{Lat: 54.7040, Lon: 25.0000},
+	}
+	track := Track{Name: "kink", Segments: [][]Point{segment, segment[:2]}}
+
+	if got := Simplify(track, 10); len(got.Segments[0]) != 2 {
+		t.Errorf("Expected a 10 m tolerance to straighten the line, got %v", got.Segments[0])
+	}
+	if got := Simplify(track, 4); len(got.Segments[0]) != 3 || got.Segments[0][1] != segment[2] {
+		t.Errorf("Expected a 4 m tolerance to keep the kink, got %v", got.Segments[0])
+	}
+	if got := Simplify(track, 0.1); len(got.Segments[0]) != 5 {
+		t.Errorf("Expected a 0.1 m tolerance to keep every point, got %v", got.Segments[0])
+	}
+	if got := Simplify(track, 10); len(got.Segments[1]) != 2 || got.Name != "kink" {
+		t.Errorf("Expected short segments and other fields untouched, got %+v", got)
+	}
+	if len(track.Segments[0]) != 5 {
+		t.Error("Simplify modified its input")
+	}
+}
@@ -0,0 +1,83 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Style describes how a track should be drawn by applications that
+// support styling. Zero fields are left to the application.
+type Style struct {
+	// Color is a hex RGB color such as "#d62728"
+	Color string
+
+	// Width is the line width in pixels
+	Width float64
+
+	// Opacity ranges from 0 (transparent) to 1 (opaque)
+	Opacity float64
+}
+
+// Validate checks the style fields
+func (s Style) Validate() error {
+	if s.Color != "" {
+		hex := strings.TrimPrefix(s.Color, "#")
+		if len(hex) != 6 || strings.Trim(strings.ToLower(hex), "0123456789abcdef") != "" {
+			return fmt.Errorf("color %q is not a hex RGB color like #d62728", s.Color)
+		}
+	}
+	if s.Width < 0 {
+		return fmt.Errorf("width %v is negative", s.Width)
+	}
+	if s.Opacity < 0 || s.Opacity > 1 {
+		return fmt.Errorf("opacity %v is outside 0-1", s.Opacity)
+	}
+	return nil
+}
+
+// hex returns the color as six upper-case hex digits without "#"
+func (s Style) hex() string {
+	return strings.ToUpper(strings.TrimPrefix(s.Color, "#"))
+}
+
+// gpxStyle is the line element of the GPX style extension
+// (http://www.topografix.com/GPX/gpx_style/0/2), understood by OsmAnd and
+// other apps
+type gpxStyle struct {
+	Namespace string  `xml:"xmlns,attr"`
+	Color     string  `xml:"color,omitempty"`
+	Opacity   float64 `xml:"opacity,omitempty"`
+	Width     float64 `xml:"width,omitempty"`
+}
+
+func (s *Style) gpx() *gpxExtensions {
+	if s == nil || *s == (Style{}) {
+		return nil
+	}
+	return &gpxExtensions{Line: gpxStyle{
+		Namespace: "http://www.topografix.com/GPX/gpx_style/0/2",
+		Color:     s.hex(),
+		Opacity:   s.Opacity,
+		Width:     s.Width,
+	}}
+}
+
+type gpxExtensions struct {
+	Line gpxStyle `xml:"line"`
+}
+
+// geoJSON adds the simplestyle-spec properties of the style to props
+func (s *Style) geoJSON(props map[string]any) {
+	if s == nil {
+		return
+	}
+	if s.Color != "" {
+		props["stroke"] = "#" + strings.ToLower(strings.TrimPrefix(s.Color, "#"))
+	}
+	if s.Width > 0 {
+		props["stroke-width"] = s.Width
+	}
+	if s.Opacity > 0 {
+		props["stroke-opacity"] = s.Opacity
+	}
+}
@@ -0,0 +1,255 @@
+package converter
+
+import (
+	"fmt"
+	"iter"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/icons"
+	"github.com/dimchansky/lt-road-info/internal/transform"
+)
+
+// Point is a WGS84 coordinate
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// Track is a named polyline in WGS84 produced from an upstream feature
+type Track struct {
+	Name     string
+	Segments [][]Point
+
+	// Description, if set, is written as the GPX desc element and the
+	// GeoJSON description property
+	Description string `json:",omitempty"`
+
+	// Properties holds the scalar upstream attributes of the feature
+	Properties map[string]any
+
+	// Style, if set, is written to outputs that support styling
+	Style *Style `json:",omitempty"`
+}
+
+// Stats counts what a conversion consumed, produced and had to skip
+type Stats struct {
+	// Features is the number of upstream features read
+	Features int
+
+	// Tracks is the number of tracks produced
+	Tracks int
+
+	// SkippedCoordinates counts coordinates with fewer than two values
+	SkippedCoordinates int
+
+	// MissingIcons counts restrictions without an icon code
+	MissingIcons int
+
+	// UnknownIcons counts restrictions whose icon code is not in the icon
+	// catalogue; UnknownIconCodes lists those codes
+	UnknownIcons     int
+	UnknownIconCodes []string
+}
+
+// EALTracks converts a stream of EAL features to tracks, one per restriction.
+// Restrictions without any usable coordinates are skipped.
+func EALTracks(features iter.Seq2[data.EALFeature, error]) iter.Seq2[Track, error] {
+	return EALTracksWithStats(features, nil)
+}
+
+// EALTracksWithStats is EALTracks adding its counts to stats, if not nil,
+// once the stream ends
+func EALTracksWithStats(features iter.Seq2[data.EALFeature, error], total *Stats) iter.Seq2[Track, error] {
+	return func(yield func(Track, error) bool) {
+		var stats Stats
+		defer func() { stats.add("restrictions", total) }()
+
+		for feature, err := range features {
+			if err != nil {
+				yield(Track{}, err)
+				return
+			}
+			stats.Features++
+
+			codes := make([]string, len(feature.Restrictions))
+			for i, restriction := range feature.Restrictions {
+				codes[i] = restriction.Icon
+			}
+			kinds := icons.Classify(feature.Icon, feature.Name, codes)
+
+			// Process each restriction within the feature
+			for i, restriction := range feature.Restrictions {
+				segments, skipped := convertPaths(restriction.Lines.Paths)
+				stats.SkippedCoordinates += skipped
+				if restriction.Icon == "" {
+					stats.MissingIcons++
+				} else if _, ok := icons.Lookup(restriction.Icon); !ok {
+					stats.unknownIcon(restriction.Icon)
+				}
+				track := Track{
+					Name:       fmt.Sprintf("%s - %s", feature.Name, getRestrictionDescription(restriction)),
+					Segments:   segments,
+					Properties: restrictionProperties(feature, restriction, kinds[i]),
+				}
+
+				if len(track.Segments) == 0 {
+					continue
+				}
+				stats.Tracks++
+				if !yield(track, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ArcGISTracks converts a stream of ArcGIS speed control features to tracks.
+// Features without any usable coordinates are skipped.
+func ArcGISTracks(features iter.Seq2[data.ArcGISFeature, error]) iter.Seq2[Track, error] {
+	return ArcGISTracksWithStats(features, nil)
+}
+
+// ArcGISTracksWithStats is ArcGISTracks adding its counts to stats, if not
+// nil, once the stream ends
+func ArcGISTracksWithStats(features iter.Seq2[data.ArcGISFeature, error], total *Stats) iter.Seq2[Track, error] {
+	return func(yield func(Track, error) bool) {
+		var stats Stats
+		defer func() { stats.add("speed-control", total) }()
+
+		i := 0
+		for feature, err := range features {
+			if err != nil {
+				yield(Track{}, err)
+				return
+			}
+			stats.Features++
+
+			segments, skipped := convertPaths(feature.Geometry.Paths)
+			stats.SkippedCoordinates += skipped
+			track := Track{
+				Name:       fmt.Sprintf("Speed Control Section %d", i+1),
+				Segments:   segments,
+				Properties: scalarAttributes(feature.Attributes),
+			}
+			i++
+
+			// Add description if available
+			if desc := getArcGISFeatureDescription(feature); desc != "" {
+				track.Name = fmt.Sprintf("%s - %s", track.Name, desc)
+			}
+
+			if len(track.Segments) == 0 {
+				continue
+			}
+			section := NewSection(track.Segments, SpeedLimit(feature.Attributes))
+			section.addProperties(track.Properties)
+			track.Description = section.Description()
+
+			stats.Tracks++
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
+
+// add logs the stats of a finished conversion and adds them to total
+func (s Stats) add(source string, total *Stats) {
+	slog.Debug("Converted features", "source", source, "features", s.Features, "tracks", s.Tracks, "skipped_coordinates", s.SkippedCoordinates)
+	if s.SkippedCoordinates > 0 {
+		slog.Warn("Skipped invalid coordinates", "source", source, "skipped_coordinates", s.SkippedCoordinates)
+	}
+	if s.MissingIcons > 0 {
+		slog.Warn("Restrictions without an icon", "source", source, "missing_icons", s.MissingIcons)
+	}
+	if s.UnknownIcons > 0 {
+		slog.Warn("Restriction icons missing from the icon catalogue", "source", source, "unknown_icons", s.UnknownIcons, "codes", strings.Join(s.UnknownIconCodes, ","))
+	}
+	if total != nil {
+		total.Features += s.Features
+		total.Tracks += s.Tracks
+		total.SkippedCoordinates += s.SkippedCoordinates
+		total.MissingIcons += s.MissingIcons
+		total.UnknownIcons += s.UnknownIcons
+		for _, code := range s.UnknownIconCodes {
+			total.addIconCode(code)
+		}
+	}
+}
+
+// unknownIcon counts a restriction with an icon code missing from the
+// catalogue
+func (s *Stats) unknownIcon(code string) {
+	s.UnknownIcons++
+	s.addIconCode(code)
+}
+
+// addIconCode adds code to the sorted UnknownIconCodes unless present
+func (s *Stats) addIconCode(code string) {
+	if i, found := slices.BinarySearch(s.UnknownIconCodes, code); !found {
+		s.UnknownIconCodes = slices.Insert(s.UnknownIconCodes, i, code)
+	}
+}
+
+// convertPaths transforms LKS-94 paths to WGS84 segments, skipping
+// coordinates with fewer than two values and paths left empty. It also
+// returns the number of skipped coordinates.
+func convertPaths(paths [][][]float64) (segments [][]Point, skipped int) {
+	for _, path := range paths {
+		var segment []Point
+
+		// Convert coordinates to WGS84 points
+		for _, coord := range path {
+			if len(coord) < 2 {
+				skipped++
+				continue
+			}
+			lat, lon := transform.LKS94ToWGS84(coord[0], coord[1])
+			segment = append(segment, Point{Lat: lat, Lon: lon})
+		}
+
+		if len(segment) > 0 {
+			segments = append(segments, segment)
+		}
+	}
+	return segments, skipped
+}
+
+// restrictionProperties describes a restriction classified as class; kind
+// and kindSource are left out if it is unclassified
+func restrictionProperties(feature data.EALFeature, restriction data.EALRestriction, class icons.Classification) map[string]any {
+	props := map[string]any{
+		"id":        restriction.ID,
+		"featureId": feature.ID,
+		"type":      feature.Name,
+		"icon":      restriction.Icon,
+	}
+	if feature.Layer != "" {
+		props["layer"] = feature.Layer
+	}
+	if restriction.IconValue > 0 {
+		props["iconValue"] = restriction.IconValue
+	}
+	if class.Kind != "" {
+		props["kind"] = class.Kind
+		props["kindSource"] = class.Source
+	}
+	props["category"] = icons.Category(class.Kind)
+	return props
+}
+
+// scalarAttributes copies the string, number and boolean attributes
+func scalarAttributes(attributes map[string]interface{}) map[string]any {
+	props := make(map[string]any, len(attributes))
+	for name, value := range attributes {
+		switch value.(type) {
+		case string, float64, int, int64, bool:
+			props[name] = value
+		}
+	}
+	return props
+}
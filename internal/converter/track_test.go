@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/data"
+)
+
+func TestEALTracksWithStats(t *testing.T) {
+	features := []data.EALFeature{
+		{
+			ID:   "MJ:1",
+			Name: "Kelio remontas",
+			Restrictions: []data.EALRestriction{
+				{ID: "TR:1", Icon: "76", Lines: data.EALLines{Paths: [][][]float64{{{532186, 6190040}, {532189}, {532218, 6190080}}}}},
+				{ID: "TR:2", Lines: data.EALLines{Paths: [][][]float64{{{532186, 6190040}, {532218, 6190080}}}}},
+				{ID: "TR:3", Icon: "901", Lines: data.EALLines{Paths: [][][]float64{{{532186}}}}},
+			},
+		},
+	}
+
+	var stats Stats
+	tracks := 0
+	for _, err := range EALTracksWithStats(data.EALFeaturesOf([]data.EALLayer{{Features: features}}), &stats) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		tracks++
+	}
+
+	want := Stats{Features: 1, Tracks: 2, SkippedCoordinates: 2, MissingIcons: 1, UnknownIcons: 1, UnknownIconCodes: []string{"901"}}
+	if !reflect.DeepEqual(stats, want) {
+		t.Errorf("Expected %+v, got %+v", want, stats)
+	}
+	if tracks != want.Tracks {
+		t.Errorf("Expected %d tracks, got %d", want.Tracks, tracks)
+	}
+}
+
+func TestRestrictionCategory(t *testing.T) {
+	feature := data.EALFeature{
+		ID:   "MJ:1",
+		Name: "Masės ribojimas",
+		Restrictions: []data.EALRestriction{
+			{ID: "TR:1", Icon: "76", IconValue: 50},
+			{ID: "TR:2", Icon: "57"},
+			{ID: "TR:3", Icon: "901", IconValue: 10},
+		},
+	}
+	tests := []struct {
+		kind, source any
+		category     string
+	}{
+		{"speed-limit", "icon", "speed-limits"},
+		{"road-works", "icon", "road-works"},
+		{"weight", "feature", "weight"},
+	}
+	for i, tt := range tests {
+		props := tracks(t, feature)[i].Properties
+		if props["kind"] != tt.kind || props["kindSource"] != tt.source || props["category"] != tt.category {
+			t.Errorf("%s: kind %v from %v, category %v; want %v from %v, %v", props["id"], props["kind"], props["kindSource"], props["category"], tt.kind, tt.source, tt.category)
+		}
+	}
+}
+
+// A feature named after one of its restrictions must not give its kind to
+// the others
+func TestMixedRestrictions(t *testing.T) {
+	feature := data.EALFeature{
+		ID:   "MJ:2",
+		Name: "Masės ribojimas",
+		Restrictions: []data.EALRestriction{
+			{ID: "TR:1", Icon: "901", IconValue: 10},
+			{ID: "TR:2", Icon: "902", IconValue: 3.5},
+		},
+	}
+	for _, track := range tracks(t, feature) {
+		if _, ok := track.Properties["kind"]; ok || track.Properties["category"] != "other" {
+			t.Errorf("%s: %v", track.Properties["id"], track.Properties)
+		}
+	}
+}
+
+func tracks(t *testing.T, feature data.EALFeature) []Track {
+	t.Helper()
+	for i := range feature.Restrictions {
+		feature.Restrictions[i].Lines = data.EALLines{Paths: [][][]float64{{{532186, 6190040}, {532218, 6190080}}}}
+	}
+	var result []Track
+	for track, err := range EALTracks(data.EALFeaturesOf([]data.EALLayer{{Features: []data.EALFeature{feature}}})) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, track)
+	}
+	if len(result) != len(feature.Restrictions) {
+		t.Fatalf("got %d tracks, want %d", len(result), len(feature.Restrictions))
+	}
+	return result
+}
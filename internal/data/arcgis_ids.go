@@ -0,0 +1,117 @@
+package data
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// arcgisObjectIDs is a snapshot of the object IDs in an ArcGIS layer
+type arcgisObjectIDs struct {
+	fieldName string
+	ids       []int64
+}
+
+// newArcGISObjectIDs sorts and de-duplicates the IDs of a returnIdsOnly response
+func newArcGISObjectIDs(resp ArcGISObjectIDsResponse) arcgisObjectIDs {
+	ids := slices.Clone(resp.ObjectIDs)
+	slices.Sort(ids)
+	ids = slices.Compact(ids)
+
+	fieldName := resp.ObjectIDFieldName
+	if fieldName == "" {
+		fieldName = "OBJECTID"
+	}
+
+	return arcgisObjectIDs{fieldName: fieldName, ids: ids}
+}
+
+// chunks splits the IDs into consecutive chunks of at most size IDs
+func (o arcgisObjectIDs) chunks(size int) [][]int64 {
+	if size <= 0 {
+		size = len(o.ids)
+	}
+
+	var chunks [][]int64
+	for ids := o.ids; len(ids) > 0; {
+		n := min(size, len(ids))
+		chunks = append(chunks, ids[:n])
+		ids = ids[n:]
+	}
+	return chunks
+}
+
+// order sorts a batch by object ID and drops features already in seen,
+// adding the remaining IDs to it. Features without a readable object ID
+// are kept after the identified ones in the order they were received.
+func (o arcgisObjectIDs) order(batch []ArcGISFeature, seen map[int64]bool) []ArcGISFeature {
+	type identified struct {
+		id      int64
+		feature ArcGISFeature
+	}
+
+	var withID []identified
+	var withoutID []ArcGISFeature
+	for _, feature := range batch {
+		id, ok := o.objectID(feature)
+		if !ok {
+			withoutID = append(withoutID, feature)
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		withID = append(withID, identified{id: id, feature: feature})
+	}
+
+	slices.SortStableFunc(withID, func(a, b identified) int {
+		return cmp.Compare(a.id, b.id)
+	})
+
+	features := make([]ArcGISFeature, 0, len(withID)+len(withoutID))
+	for _, f := range withID {
+		features = append(features, f.feature)
+	}
+	return append(features, withoutID...)
+}
+
+// objectID reads the object ID attribute of a feature. Field names are
+// matched case-insensitively because services are not consistent about
+// the case they report in objectIdFieldName.
+func (o arcgisObjectIDs) objectID(feature ArcGISFeature) (int64, bool) {
+	value, ok := feature.Attributes[o.fieldName]
+	if !ok {
+		for name, v := range feature.Attributes {
+			if strings.EqualFold(name, o.fieldName) {
+				value, ok = v, true
+				break
+			}
+		}
+	}
+	if !ok {
+		return 0, false
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case int:
+		return int64(v), true
+	case int64:
+		return v, true
+	case string:
+		id, err := strconv.ParseInt(v, 10, 64)
+		return id, err == nil
+	}
+	return 0, false
+}
+
+func joinInt64(ids []int64) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrUnchanged is returned by downloaders when a cached upstream response
+// was confirmed current and the previously generated output is still valid
+var ErrUnchanged = errors.New("upstream data unchanged")
+
+// Cache stores upstream response bodies on disk together with their
+// ETag and Last-Modified validators, so that later requests can be
+// made conditional
+type Cache struct {
+	dir string
+}
+
+// cacheEntry is the metadata stored next to each cached body
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+	StoredAt     time.Time `json:"storedAt"`
+}
+
+// NewCache creates a cache in dir, creating the directory if needed
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// lookup returns the cached entry for url, or nil if there is none
+func (c *Cache) lookup(url string) *cacheEntry {
+	metaPath, bodyPath := c.paths(url)
+
+	meta, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil || entry.URL != url {
+		return nil
+	}
+	if _, err := os.Stat(bodyPath); err != nil {
+		return nil
+	}
+
+	return &entry
+}
+
+// open opens the cached body for url
+func (c *Cache) open(url string) (io.ReadCloser, error) {
+	_, bodyPath := c.paths(url)
+	return os.Open(bodyPath)
+}
+
+// tee returns a reader that copies body into a temporary cache file while
+// it is read. The entry only replaces the cached one once the caller has
+// parsed the body and called commit, so a truncated or malformed response
+// never replaces a good entry.
+func (c *Cache) tee(entry cacheEntry, body io.ReadCloser) (*cacheWriter, error) {
+	tmp, err := os.CreateTemp(c.dir, "body-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cache file: %w", err)
+	}
+	return &cacheWriter{cache: c, entry: entry, body: body, tmp: tmp}, nil
+}
+
+func (c *Cache) commit(entry cacheEntry, tmpPath string) error {
+	metaPath, bodyPath := c.paths(entry.URL)
+
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Drop the old metadata first so that a crash between the two renames
+	// leaves no entry rather than a body paired with stale validators
+	if err := os.Remove(metaPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(tmpPath, bodyPath); err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, meta, 0644)
+}
+
+func (c *Cache) paths(url string) (metaPath, bodyPath string) {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(c.dir, name+".json"), filepath.Join(c.dir, name+".body")
+}
+
+// cacheWriter copies a response body into a temporary cache file
+type cacheWriter struct {
+	cache     *Cache
+	entry     cacheEntry
+	body      io.ReadCloser
+	tmp       *os.File
+	err       error
+	committed bool
+}
+
+func (w *cacheWriter) Read(p []byte) (int, error) {
+	n, err := w.body.Read(p)
+	if n > 0 && w.err == nil {
+		_, w.err = w.tmp.Write(p[:n])
+	}
+	return n, err
+}
+
+// commit reads the rest of the body and stores it as the cache entry
+func (w *cacheWriter) commit() error {
+	if _, err := io.Copy(io.Discard, w); err != nil {
+		return err
+	}
+	if err := w.tmp.Close(); err != nil {
+		return err
+	}
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.cache.commit(w.entry, w.tmp.Name()); err != nil {
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	w.committed = true
+	return nil
+}
+
+func (w *cacheWriter) Close() error {
+	if !w.committed {
+		w.tmp.Close()
+		os.Remove(w.tmp.Name())
+	}
+	return w.body.Close()
+}
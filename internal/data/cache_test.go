@@ -0,0 +1,93 @@
+package data
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachingClient_ConditionalRequests(t *testing.T) {
+	testData, err := os.ReadFile("../../testdata/eal_known_coords.json")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+
+	const etag = `"v1"`
+	var fullResponses, notModified int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fullResponses++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("ETag", etag)
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: &redirectTransport{target: server.URL}}
+	cache, err := NewCache(filepath.Join(t.TempDir(), "cache"))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	first := NewCachingClient(httpClient, cache)
+	layers, err := first.FetchEALData()
+	if err != nil {
+		t.Fatalf("First fetch failed: %v", err)
+	}
+	if !first.Modified() {
+		t.Error("First fetch should report modified data")
+	}
+
+	second := NewCachingClient(httpClient, cache)
+	cached, err := second.FetchEALData()
+	if err != nil {
+		t.Fatalf("Second fetch failed: %v", err)
+	}
+	if second.Modified() {
+		t.Error("Second fetch should report unchanged data")
+	}
+
+	if fullResponses != 1 || notModified != 1 {
+		t.Errorf("Expected 1 full and 1 not-modified response, got %d and %d", fullResponses, notModified)
+	}
+	if len(cached) != len(layers) || len(cached[0].Features) != len(layers[0].Features) {
+		t.Errorf("Cached response differs from the original: %d layers vs %d", len(cached), len(layers))
+	}
+}
+
+func TestCachingClient_DoesNotCacheErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"broken"`)
+		w.Write([]byte(`[{"layer": `))
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{Transport: &redirectTransport{target: server.URL}}
+	cacheDir := t.TempDir()
+	cache, err := NewCache(cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	if _, err := NewCachingClient(httpClient, cache).FetchEALData(); err == nil {
+		t.Fatal("Expected a parse error for truncated JSON")
+	}
+
+	if cache.lookup(EALURL) != nil {
+		t.Error("A body that failed to parse should not be cached")
+	}
+
+	entries, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatalf("Failed to read cache directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected empty cache directory, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Interaction is a recorded HTTP exchange. Bodies are stored as text, as
+// both upstream APIs serve JSON.
+type Interaction struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body"`
+}
+
+// Cassette holds upstream responses recorded once and replayed offline, so
+// that a complete run can be repeated without network access
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// LoadCassette reads a cassette written by Recorder.Save
+func LoadCassette(path string) (*Cassette, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Cassette
+	if err := json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// RoundTrip implements http.RoundTripper, answering each request with the
+// first interaction of the same method and URL. A request that was not
+// recorded fails.
+func (c *Cassette) RoundTrip(req *http.Request) (*http.Response, error) {
+	for _, in := range c.Interactions {
+		if in.Method != req.Method || in.URL != req.URL.String() {
+			continue
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header.Clone(),
+			Body:          io.NopCloser(strings.NewReader(in.Body)),
+			ContentLength: int64(len(in.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded response for %s %s", req.Method, req.URL)
+}
+
+// Recorder is an http.RoundTripper recording every exchange into a
+// cassette
+type Recorder struct {
+	// Base performs the requests; nil means http.DefaultTransport
+	Base http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := r.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Header: resp.Header.Clone(),
+		Body:   string(body),
+	})
+	return resp, nil
+}
+
+// Save writes the recorded interactions to path, sorted by URL so that
+// concurrent requests do not reorder the file between recordings
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	c := Cassette{Interactions: slices.Clone(r.cassette.Interactions)}
+	r.mu.Unlock()
+	slices.SortStableFunc(c.Interactions, func(a, b Interaction) int {
+		return strings.Compare(a.URL, b.URL)
+	})
+
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write cassette: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package data
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// redirect sends every request to server, keeping the path and query
+type redirect struct {
+	server *httptest.Server
+}
+
+func (r redirect) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = r.server.Listener.Addr().String()
+	return http.DefaultTransport.RoundTrip(out)
+}
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	testData, err := os.ReadFile("../../testdata/eal_known_coords.json")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	recorder := &Recorder{Base: redirect{server}}
+	recorded := 0
+	for _, err := range NewClient(&http.Client{Transport: recorder}).EALFeatures() {
+		if err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		recorded++
+	}
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatalf("Failed to save cassette: %v", err)
+	}
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		t.Fatalf("Failed to load cassette: %v", err)
+	}
+	if len(cassette.Interactions) != 1 || cassette.Interactions[0].URL != EALURL {
+		t.Fatalf("Expected one interaction for %s, got %+v", EALURL, cassette.Interactions)
+	}
+
+	replayed := 0
+	for _, err := range NewClient(&http.Client{Transport: cassette}).EALFeatures() {
+		if err != nil {
+			t.Fatalf("Failed to replay: %v", err)
+		}
+		replayed++
+	}
+	if replayed == 0 || replayed != recorded {
+		t.Errorf("Expected %d replayed features, got %d", recorded, replayed)
+	}
+	if requests != 1 {
+		t.Errorf("Expected the replay not to reach the server, got %d requests", requests)
+	}
+
+	if _, err := NewClient(&http.Client{Transport: cassette}).FetchArcGISData(); err == nil {
+		t.Error("Expected an error for a request that was not recorded")
+	}
+}
@@ -2,81 +2,191 @@
 package data
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/net/html/charset"
 )
 
+// Upstream endpoints
+const (
+	// EALURL serves the road restrictions in LKS94
+	EALURL = "https://eismoinfo.lt/eismoinfo-backend/layer-dynamic-features/EAL?lks=true"
+
+	// ArcGISLayerURL is the ArcGIS layer with the speed control sections
+	ArcGISLayerURL = "https://gis.ktvis.lt/arcgis/rest/services/PUB/PUB_ITS/MapServer/13"
+)
+
+const (
+	arcgisQueryURL = ArcGISLayerURL + "/query"
+
+	// arcgisMaxConcurrency limits parallel feature requests to the ArcGIS server
+	arcgisMaxConcurrency = 4
+)
+
 // Client handles HTTP requests to Lithuanian traffic APIs
 type Client struct {
 	httpClient *http.Client
+	cache      *Cache
+	modified   atomic.Bool
+	pages      atomic.Int64
 }
 
 // NewClient creates a new API client
 func NewClient(httpClient *http.Client) *Client {
+	return NewCachingClient(httpClient, nil)
+}
+
+// NewCachingClient creates an API client that makes conditional requests
+// using the ETag and Last-Modified validators stored in cache. A 304
+// response is served from the cached body. A nil cache disables caching.
+func NewCachingClient(httpClient *http.Client, cache *Cache) *Client {
 	if httpClient == nil {
 		httpClient = http.DefaultClient
 	}
 	return &Client{
 		httpClient: httpClient,
+		cache:      cache,
 	}
 }
 
+// Modified reports whether any response received by the client carried
+// new content, as opposed to being confirmed current by a 304 response.
+// It is always true after a request made without a cache.
+func (c *Client) Modified() bool {
+	return c.modified.Load()
+}
+
+// Pages returns the number of ArcGIS feature pages fetched by the client
+func (c *Client) Pages() int {
+	return int(c.pages.Load())
+}
+
 // FetchEALData fetches road restrictions from the EAL API
 func (c *Client) FetchEALData() ([]EALLayer, error) {
-	const url = "https://eismoinfo.lt/eismoinfo-backend/layer-dynamic-features/EAL?lks=true"
-
-	resp, err := c.httpClient.Get(url)
+	resp, err := c.get(context.Background(), EALURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch EAL data: %w", err)
 	}
-	defer resp.Body.Close()
+	defer resp.body.Close()
 
-	// Handle character encoding
-	reader, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
-	if err != nil {
-		reader = resp.Body
+	var layers []EALLayer
+	if err := json.NewDecoder(resp.reader()).Decode(&layers); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	body, err := io.ReadAll(reader)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if err := resp.keep(); err != nil {
+		return nil, err
 	}
 
-	var layers []EALLayer
-	if err := json.Unmarshal(body, &layers); err != nil {
-		return nil, fmt.Errorf("failed to parse JSON: %w", err)
+	for i := range layers {
+		for j := range layers[i].Features {
+			layers[i].Features[j].Layer = layers[i].Layer
+		}
 	}
 
 	return layers, nil
 }
 
+// EALFeatures streams road restriction features from the EAL API. The
+// response is decoded incrementally, so only one feature is held in memory
+// at a time. Iteration stops after the first error.
+func (c *Client) EALFeatures() iter.Seq2[EALFeature, error] {
+	return func(yield func(EALFeature, error) bool) {
+		start := time.Now()
+		resp, err := c.get(context.Background(), EALURL)
+		if err != nil {
+			yield(EALFeature{}, fmt.Errorf("failed to fetch EAL data: %w", err))
+			return
+		}
+		defer resp.body.Close()
+
+		features := 0
+		defer func() {
+			slog.Debug("Fetched EAL features", "source", "restrictions", "features", features, "duration", time.Since(start))
+		}()
+
+		err = decodeEALStream(resp.reader(), func(feature EALFeature) bool {
+			features++
+			return yield(feature, nil)
+		})
+		switch {
+		case errors.Is(err, errStopped):
+			return
+		case err != nil:
+			yield(EALFeature{}, fmt.Errorf("failed to parse JSON: %w", err))
+			return
+		}
+
+		if err := resp.keep(); err != nil {
+			yield(EALFeature{}, err)
+		}
+	}
+}
+
 // FetchArcGISData fetches speed control data from ArcGIS API
 func (c *Client) FetchArcGISData() ([]ArcGISFeature, error) {
-	// Get service information first
-	maxRecords, err := c.getMaxRecordCount()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get service info: %w", err)
+	var features []ArcGISFeature
+	for feature, err := range c.ArcGISFeatures() {
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
 	}
+	return features, nil
+}
+
+// ArcGISFeatures streams speed control sections from the ArcGIS API.
+// Features are fetched by object ID chunks sized to the service's
+// maxRecordCount, up to arcgisMaxConcurrency chunks at a time, and are
+// yielded de-duplicated in ascending object ID order. At most
+// arcgisMaxConcurrency chunks are held in memory at once. A chunk the
+// service truncates at its transfer limit is split and fetched again.
+func (c *Client) ArcGISFeatures() iter.Seq2[ArcGISFeature, error] {
+	return func(yield func(ArcGISFeature, error) bool) {
+		start := time.Now()
 
-	// Fetch all features with pagination
-	return c.fetchAllArcGISFeatures(maxRecords)
+		// Get service information first
+		maxRecords, err := c.getMaxRecordCount()
+		if err != nil {
+			yield(ArcGISFeature{}, fmt.Errorf("failed to get service info: %w", err))
+			return
+		}
+
+		// Snapshot the layer by object IDs so that paging is not affected by
+		// features being added or removed while we walk it
+		ids, err := c.fetchArcGISObjectIDs()
+		if err != nil {
+			yield(ArcGISFeature{}, fmt.Errorf("failed to get object IDs: %w", err))
+			return
+		}
+
+		pages, features := c.streamArcGISFeaturesByID(ids, maxRecords, yield)
+		slog.Debug("Fetched ArcGIS features", "source", "speed-control", "features", features, "pages", pages, "duration", time.Since(start))
+	}
 }
 
 func (c *Client) getMaxRecordCount() (int, error) {
-	const baseURL = "https://gis.ktvis.lt/arcgis/rest/services/PUB/PUB_ITS/MapServer/13"
-
-	resp, err := c.httpClient.Get(baseURL + "?f=json")
+	resp, err := c.get(context.Background(), ArcGISLayerURL+"?f=json")
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
+	defer resp.body.Close()
 
 	var info ArcGISServiceInfo
-	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+	if err := json.NewDecoder(resp.body).Decode(&info); err != nil {
+		return 0, err
+	}
+	if err := resp.keep(); err != nil {
 		return 0, err
 	}
 
@@ -86,49 +196,270 @@ func (c *Client) getMaxRecordCount() (int, error) {
 	return 1000, nil // default
 }
 
-func (c *Client) fetchAllArcGISFeatures(maxRecords int) ([]ArcGISFeature, error) {
-	var allFeatures []ArcGISFeature
-	offset := 0
+func (c *Client) fetchArcGISObjectIDs() (arcgisObjectIDs, error) {
+	params := url.Values{
+		"where":         {"1=1"},
+		"returnIdsOnly": {"true"},
+		"f":             {"json"},
+	}
 
-	for {
-		features, hasMore, err := c.fetchArcGISFeatureBatch(offset, maxRecords)
-		if err != nil {
-			return nil, err
+	resp, err := c.get(context.Background(), arcgisQueryURL+"?"+params.Encode())
+	if err != nil {
+		return arcgisObjectIDs{}, err
+	}
+	defer resp.body.Close()
+
+	var result ArcGISObjectIDsResponse
+	if err := json.NewDecoder(resp.body).Decode(&result); err != nil {
+		return arcgisObjectIDs{}, err
+	}
+	if result.Error != nil {
+		return arcgisObjectIDs{}, result.Error
+	}
+	if err := resp.keep(); err != nil {
+		return arcgisObjectIDs{}, err
+	}
+
+	return newArcGISObjectIDs(result), nil
+}
+
+// arcgisBatch is the result of fetching one chunk of object IDs
+type arcgisBatch struct {
+	features []ArcGISFeature
+	duration time.Duration
+	err      error
+}
+
+// streamArcGISFeaturesByID fetches chunks in parallel but yields them in
+// chunk order. A chunk's concurrency slot is only released once it has
+// been yielded, which bounds the number of buffered chunks. It returns the
+// number of pages fetched and features yielded.
+func (c *Client) streamArcGISFeaturesByID(ids arcgisObjectIDs, chunkSize int, yield func(ArcGISFeature, error) bool) (pages, yielded int) {
+	chunks := ids.chunks(chunkSize)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	results := make([]chan arcgisBatch, len(chunks))
+	for i := range results {
+		results[i] = make(chan arcgisBatch, 1)
+	}
+
+	// Slots are acquired in chunk order, so the chunk being waited for
+	// below has always been started
+	sem := make(chan struct{}, arcgisMaxConcurrency)
+	go func() {
+		for i, chunk := range chunks {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			go func() {
+				start := time.Now()
+				features, err := c.fetchArcGISFeatureBatch(ctx, chunk)
+				results[i] <- arcgisBatch{features: features, duration: time.Since(start), err: err}
+			}()
 		}
+	}()
 
-		allFeatures = append(allFeatures, features...)
+	seen := make(map[int64]bool)
+	for i, chunk := range chunks {
+		batch := <-results[i]
+		<-sem
 
-		if !hasMore {
-			break
+		if batch.err != nil {
+			yield(ArcGISFeature{}, fmt.Errorf("failed to fetch object IDs %d-%d: %w", chunk[0], chunk[len(chunk)-1], batch.err))
+			return pages, yielded
 		}
+		pages++
+		c.pages.Add(1)
+		slog.Debug("Fetched ArcGIS page", "source", "speed-control", "page", i, "offset", i*chunkSize,
+			"features", len(batch.features), "duration", batch.duration)
 
-		offset += len(features)
+		for _, feature := range ids.order(batch.features, seen) {
+			if !yield(feature, nil) {
+				return pages, yielded
+			}
+			yielded++
+		}
 	}
-
-	return allFeatures, nil
+	return pages, yielded
 }
 
-func (c *Client) fetchArcGISFeatureBatch(offset, limit int) ([]ArcGISFeature, bool, error) {
-	const queryURL = "https://gis.ktvis.lt/arcgis/rest/services/PUB/PUB_ITS/MapServer/13/query"
+// fetchArcGISFeatureBatch fetches the features of a chunk of object IDs.
+// If the service truncates the response at its transfer limit, the chunk
+// is split in half and each half is fetched again.
+func (c *Client) fetchArcGISFeatureBatch(ctx context.Context, ids []int64) ([]ArcGISFeature, error) {
+	features, exceeded, err := c.queryArcGISFeatures(ctx, ids)
+	if err != nil || !exceeded {
+		return features, err
+	}
+	if len(ids) == 1 {
+		return nil, fmt.Errorf("object ID %d exceeded the transfer limit", ids[0])
+	}
 
-	// Build query parameters
-	params := fmt.Sprintf("?where=1=1&outFields=*&returnGeometry=true&f=json&resultOffset=%d&resultRecordCount=%d&outSR=3346", offset, limit)
+	half := len(ids) / 2
+	slog.Debug("Splitting ArcGIS chunk", "source", "speed-control", "from", ids[0], "to", ids[len(ids)-1], "reason", "exceeded transfer limit")
+	first, err := c.fetchArcGISFeatureBatch(ctx, ids[:half])
+	if err != nil {
+		return nil, err
+	}
+	second, err := c.fetchArcGISFeatureBatch(ctx, ids[half:])
+	if err != nil {
+		return nil, err
+	}
+	return append(first, second...), nil
+}
+
+func (c *Client) queryArcGISFeatures(ctx context.Context, ids []int64) ([]ArcGISFeature, bool, error) {
+	params := url.Values{
+		"objectIds":      {joinInt64(ids)},
+		"outFields":      {"*"},
+		"returnGeometry": {"true"},
+		"outSR":          {"3346"},
+		"f":              {"json"},
+	}
 
-	resp, err := c.httpClient.Get(queryURL + params)
+	resp, err := c.get(ctx, arcgisQueryURL+"?"+params.Encode())
 	if err != nil {
 		return nil, false, err
 	}
-	defer resp.Body.Close()
+	defer resp.body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	var features []ArcGISFeature
+	exceeded, err := decodeArcGISQuery(resp.body, func(feature ArcGISFeature) {
+		features = append(features, feature)
+	})
 	if err != nil {
 		return nil, false, err
 	}
-
-	var result ArcGISQueryResponse
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := resp.keep(); err != nil {
 		return nil, false, err
 	}
 
-	return result.Features, result.ExceededTransfer, nil
+	return features, exceeded, nil
+}
+
+// StatusError reports an upstream response with an unexpected status
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected response status: " + e.Status
+}
+
+// loggedBody logs the size and total duration of a response body once it
+// is closed
+type loggedBody struct {
+	io.ReadCloser
+	url   string
+	start time.Time
+	bytes int64
+}
+
+func (b *loggedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.bytes += int64(n)
+	return n, err
+}
+
+func (b *loggedBody) Close() error {
+	slog.Debug("Read upstream response", "url", b.url, "bytes", b.bytes, "duration", time.Since(b.start))
+	return b.ReadCloser.Close()
+}
+
+// response is an upstream response body, either fresh or from the cache
+type response struct {
+	body        io.ReadCloser
+	contentType string
+}
+
+// reader returns the body decoded from the charset in its Content-Type
+func (r *response) reader() io.Reader {
+	reader, err := charset.NewReader(r.body, r.contentType)
+	if err != nil {
+		return r.body
+	}
+	return reader
+}
+
+// keep stores a fresh body in the cache. It must be called once the body
+// has been parsed successfully; unkept bodies are discarded on Close.
+func (r *response) keep() error {
+	if w, ok := r.body.(*cacheWriter); ok {
+		return w.commit()
+	}
+	return nil
+}
+
+// get performs a GET request. With a cache configured the request carries
+// the stored validators, a 304 response is served from the cached body and
+// a fresh body is written to the cache as it is read. The caller must
+// close the returned body.
+func (c *Client) get(ctx context.Context, url string) (*response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *cacheEntry
+	if c.cache != nil {
+		entry = c.cache.lookup(url)
+	}
+	if entry != nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		slog.Debug("Upstream request failed", "url", url, "duration", time.Since(start), "error", err)
+		return nil, err
+	}
+	slog.Debug("Upstream response", "url", url, "status", resp.StatusCode, "duration", time.Since(start))
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		resp.Body.Close()
+		body, err := c.cache.open(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cached response: %w", err)
+		}
+		return &response{body: body, contentType: entry.ContentType}, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{URL: url, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	resp.Body = &loggedBody{ReadCloser: resp.Body, url: url, start: start}
+
+	c.modified.Store(true)
+
+	contentType := resp.Header.Get("Content-Type")
+	etag, lastModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if c.cache == nil || (etag == "" && lastModified == "") {
+		return &response{body: resp.Body, contentType: contentType}, nil
+	}
+
+	body, err := c.cache.tee(cacheEntry{
+		URL:          url,
+		ETag:         etag,
+		LastModified: lastModified,
+		ContentType:  contentType,
+		StoredAt:     time.Now(),
+	}, resp.Body)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return &response{body: body, contentType: contentType}, nil
 }
@@ -1,6 +1,13 @@
 package data
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -57,3 +64,191 @@ func TestClient_FetchArcGISData(t *testing.T) {
 		}
 	}
 }
+
+func TestClient_FetchArcGISData_ObjectIDChunks(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		query := r.URL.Query()
+
+		switch {
+		case !strings.HasSuffix(r.URL.Path, "/query"):
+			w.Write([]byte(`{"maxRecordCount": 2}`))
+		case query.Get("returnIdsOnly") == "true":
+			w.Write([]byte(`{"objectIdFieldName": "objectid", "objectIds": [5, 3, 1, 3, 4]}`))
+		default:
+			mu.Lock()
+			requests = append(requests, query.Get("objectIds"))
+			mu.Unlock()
+
+			// Every batch also returns feature 3 to simulate overlapping pages
+			var features []string
+			for _, id := range append(strings.Split(query.Get("objectIds"), ","), "3") {
+				features = append(features, `{"attributes": {"objectid": `+id+`}, "geometry": {"paths": []}}`)
+			}
+			w.Write([]byte(`{"features": [` + strings.Join(features, ",") + `]}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&http.Client{Transport: &redirectTransport{target: server.URL}})
+
+	features, err := client.FetchArcGISData()
+	if err != nil {
+		t.Fatalf("Failed to fetch ArcGIS data: %v", err)
+	}
+
+	var got []float64
+	for _, feature := range features {
+		got = append(got, feature.Attributes["objectid"].(float64))
+	}
+	want := []float64{1, 3, 4, 5}
+	if !slices.Equal(got, want) {
+		t.Errorf("Expected object IDs %v, got %v", want, got)
+	}
+
+	slices.Sort(requests)
+	if wantRequests := []string{"1,3", "4,5"}; !slices.Equal(requests, wantRequests) {
+		t.Errorf("Expected chunk requests %v, got %v", wantRequests, requests)
+	}
+
+	// Stopping the stream early must not block on outstanding chunks
+	for feature, err := range client.ArcGISFeatures() {
+		if err != nil {
+			t.Fatalf("Failed to stream ArcGIS data: %v", err)
+		}
+		if id := feature.Attributes["objectid"].(float64); id != 1 {
+			t.Errorf("Expected first streamed object ID 1, got %v", id)
+		}
+		break
+	}
+}
+
+func TestClient_FetchArcGISData_ExceededTransferLimit(t *testing.T) {
+	// newServer returns a service that truncates any query of more than
+	// two object IDs, and always truncates queries for the given ID
+	newServer := func(requests *[]string, mu *sync.Mutex, tooLarge string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			query := r.URL.Query()
+
+			switch {
+			case !strings.HasSuffix(r.URL.Path, "/query"):
+				w.Write([]byte(`{"maxRecordCount": 4}`))
+			case query.Get("returnIdsOnly") == "true":
+				w.Write([]byte(`{"objectIdFieldName": "objectid", "objectIds": [1, 2, 3, 4, 5]}`))
+			default:
+				mu.Lock()
+				*requests = append(*requests, query.Get("objectIds"))
+				mu.Unlock()
+
+				ids := strings.Split(query.Get("objectIds"), ",")
+				exceeded := len(ids) > 2 || slices.Contains(ids, tooLarge)
+				if exceeded {
+					ids = ids[:1]
+				}
+				var features []string
+				for _, id := range ids {
+					features = append(features, `{"attributes": {"objectid": `+id+`}, "geometry": {"paths": []}}`)
+				}
+				fmt.Fprintf(w, `{"features": [%s], "exceededTransferLimit": %t}`, strings.Join(features, ","), exceeded)
+			}
+		}))
+	}
+
+	t.Run("split", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			requests []string
+		)
+		server := newServer(&requests, &mu, "")
+		defer server.Close()
+
+		client := NewClient(&http.Client{Transport: &redirectTransport{target: server.URL}})
+		features, err := client.FetchArcGISData()
+		if err != nil {
+			t.Fatalf("Failed to fetch ArcGIS data: %v", err)
+		}
+
+		var got []float64
+		for _, feature := range features {
+			got = append(got, feature.Attributes["objectid"].(float64))
+		}
+		if want := []float64{1, 2, 3, 4, 5}; !slices.Equal(got, want) {
+			t.Errorf("Expected object IDs %v, got %v", want, got)
+		}
+
+		slices.Sort(requests)
+		if want := []string{"1,2", "1,2,3,4", "3,4", "5"}; !slices.Equal(requests, want) {
+			t.Errorf("Expected requests %v, got %v", want, requests)
+		}
+	})
+
+	t.Run("single ID", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			requests []string
+		)
+		server := newServer(&requests, &mu, "3")
+		defer server.Close()
+
+		client := NewClient(&http.Client{Transport: &redirectTransport{target: server.URL}})
+		_, err := client.FetchArcGISData()
+		if err == nil {
+			t.Fatal("Expected an error")
+		}
+		for _, want := range []string{"object IDs 1-4", "object ID 3 exceeded the transfer limit"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to contain %q, got %v", want, err)
+			}
+		}
+	})
+}
+
+func TestClient_FetchArcGISData_ErrorResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case !strings.HasSuffix(r.URL.Path, "/query"):
+			w.Write([]byte(`{"maxRecordCount": 1000}`))
+		case r.URL.Query().Get("returnIdsOnly") == "true":
+			w.Write([]byte(`{"objectIdFieldName": "objectid", "objectIds": [1]}`))
+		default:
+			w.Write([]byte(`{"error": {"code": 400, "message": "Unable to complete operation."}}`))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(&http.Client{Transport: &redirectTransport{target: server.URL}})
+
+	_, err := client.FetchArcGISData()
+	if err == nil {
+		t.Fatal("Expected an error for an ArcGIS error response")
+	}
+	if !strings.Contains(err.Error(), "Unable to complete operation") {
+		t.Errorf("Expected error to carry the ArcGIS message, got: %v", err)
+	}
+}
+
+// redirectTransport sends every request to target, keeping path and query
+type redirectTransport struct {
+	target string
+}
+
+func (rt *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target, err := url.Parse(rt.target)
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.URL.Scheme = target.Scheme
+	req.URL.Host = target.Host
+	req.Host = target.Host
+
+	return http.DefaultTransport.RoundTrip(req)
+}
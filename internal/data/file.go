@@ -0,0 +1,105 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"os"
+	"slices"
+	"strings"
+)
+
+// lks94WKIDs are the ArcGIS spatial reference IDs of LKS94 / Lithuania TM,
+// the only projection the converters accept
+var lks94WKIDs = []int{3346, 2600}
+
+// EALFeaturesFromFiles streams the features of saved EAL responses, such
+// as a response of the ?lks=true endpoint saved from a browser. Files are
+// decoded one feature at a time.
+func EALFeaturesFromFiles(paths []string) iter.Seq2[EALFeature, error] {
+	return func(yield func(EALFeature, error) bool) {
+		for _, path := range paths {
+			f, err := os.Open(path)
+			if err != nil {
+				yield(EALFeature{}, err)
+				return
+			}
+
+			err = decodeEALStream(f, func(feature EALFeature) bool {
+				return yield(feature, nil)
+			})
+			f.Close()
+			switch {
+			case errors.Is(err, errStopped):
+				return
+			case err != nil:
+				yield(EALFeature{}, fmt.Errorf("failed to parse %s: %w", path, err))
+				return
+			}
+		}
+	}
+}
+
+// ArcGISFeaturesFromFiles streams the features of saved ArcGIS query
+// responses, e.g. one file per page. Geometry must be in LKS94
+// (outSR=3346). A feature whose object ID was already read from an earlier
+// page is skipped, so overlapping pages are harmless.
+func ArcGISFeaturesFromFiles(paths []string) iter.Seq2[ArcGISFeature, error] {
+	return func(yield func(ArcGISFeature, error) bool) {
+		seen := make(map[string]bool)
+		for _, path := range paths {
+			features, err := readArcGISFile(path)
+			if err != nil {
+				yield(ArcGISFeature{}, err)
+				return
+			}
+			for _, feature := range features {
+				if id, ok := objectID(feature); ok {
+					if seen[id] {
+						continue
+					}
+					seen[id] = true
+				}
+				if !yield(feature, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func readArcGISFile(path string) ([]ArcGISFeature, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var page struct {
+		ArcGISQueryResponse
+		SpatialReference *struct {
+			WKID       int `json:"wkid"`
+			LatestWKID int `json:"latestWkid"`
+		} `json:"spatialReference"`
+	}
+	if err := json.Unmarshal(content, &page); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if page.Error != nil {
+		return nil, fmt.Errorf("%s: %w", path, page.Error)
+	}
+	if sr := page.SpatialReference; sr != nil && !slices.Contains(lks94WKIDs, sr.WKID) && !slices.Contains(lks94WKIDs, sr.LatestWKID) {
+		return nil, fmt.Errorf("%s: geometry is in spatial reference %d, save the query with outSR=3346", path, sr.WKID)
+	}
+	return page.Features, nil
+}
+
+// objectID returns the OBJECTID attribute, whatever its case
+func objectID(feature ArcGISFeature) (string, bool) {
+	for name, value := range feature.Attributes {
+		if strings.EqualFold(name, "objectid") && value != nil {
+			return fmt.Sprint(value), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEALFeaturesFromFiles(t *testing.T) {
+	var ids []string
+	for feature, err := range EALFeaturesFromFiles([]string{"../../testdata/eal_known_coords.json"}) {
+		if err != nil {
+			t.Fatalf("Failed to read features: %v", err)
+		}
+		if feature.Layer == "" {
+			t.Errorf("Feature %s has no layer", feature.ID)
+		}
+		ids = append(ids, feature.ID)
+	}
+	if len(ids) == 0 || ids[0] != "TEST:001" {
+		t.Errorf("Unexpected features: %v", ids)
+	}
+
+	for _, err := range EALFeaturesFromFiles([]string{"missing.json"}) {
+		if err == nil {
+			t.Error("Expected an error for a missing file")
+		}
+	}
+}
+
+func TestArcGISFeaturesFromFiles(t *testing.T) {
+	dir := t.TempDir()
+	page := func(name, body string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	first := page("1.json", `{"spatialReference":{"wkid":3346},"features":[
+		{"attributes":{"OBJECTID":1},"geometry":{"paths":[[[568123,6062456],[568140,6062470]]]}},
+		{"attributes":{"OBJECTID":2},"geometry":{"paths":[[[568123,6062456],[568140,6062470]]]}}]}`)
+	second := page("2.json", `{"features":[
+		{"attributes":{"objectid":2},"geometry":{"paths":[]}},
+		{"attributes":{"OBJECTID":3},"geometry":{"paths":[]}}]}`)
+
+	count := 0
+	for _, err := range ArcGISFeaturesFromFiles([]string{first, second}) {
+		if err != nil {
+			t.Fatalf("Failed to read features: %v", err)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 features without the repeated object ID, got %d", count)
+	}
+
+	mercator := page("3857.json", `{"spatialReference":{"wkid":102100,"latestWkid":3857},"features":[]}`)
+	for _, err := range ArcGISFeaturesFromFiles([]string{mercator}) {
+		if err == nil || !strings.Contains(err.Error(), "outSR=3346") {
+			t.Errorf("Expected a spatial reference error, got %v", err)
+		}
+	}
+}
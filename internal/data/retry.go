@@ -0,0 +1,74 @@
+package data
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryWait caps the wait between attempts, including Retry-After
+const maxRetryWait = time.Minute
+
+// RetryTransport retries idempotent requests that failed with a network
+// error, 429 or a 5xx status, waiting Delay before the first retry and
+// doubling it for each further one. A Retry-After header overrides the
+// wait.
+type RetryTransport struct {
+	// Base performs the requests; nil means http.DefaultTransport
+	Base http.RoundTripper
+
+	// Retries is the number of retries after the first attempt
+	Retries int
+
+	// Delay is the wait before the first retry
+	Delay time.Duration
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return base.RoundTrip(req)
+	}
+
+	delay := t.Delay
+	for attempt := 0; ; attempt++ {
+		resp, err := base.RoundTrip(req)
+		if attempt >= t.Retries || !retryable(resp, err) {
+			return resp, err
+		}
+
+		wait := delay
+		attrs := []any{"url", req.URL.String(), "attempt", attempt + 1}
+		if resp != nil {
+			if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(seconds) * time.Second
+			}
+			resp.Body.Close()
+			attrs = append(attrs, "status", resp.StatusCode)
+		} else {
+			attrs = append(attrs, "error", err.Error())
+		}
+		slog.Warn("Retrying upstream request", append(attrs, "wait", min(wait, maxRetryWait))...)
+
+		timer := time.NewTimer(min(wait, maxRetryWait))
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
@@ -0,0 +1,65 @@
+package data
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedTransport answers with the queued results in order
+type scriptedTransport struct {
+	results []any // int status or error
+	calls   int
+}
+
+func (s *scriptedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	result := s.results[min(s.calls, len(s.results)-1)]
+	s.calls++
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: result.(int),
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("body")),
+		Request:    req,
+	}, nil
+}
+
+func TestRetryTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []any
+		retries int
+		method  string
+		calls   int
+		status  int
+	}{
+		{"recovers from errors", []any{errors.New("reset"), 503, 200}, 3, http.MethodGet, 3, 200},
+		{"gives up after retries", []any{500}, 2, http.MethodGet, 3, 500},
+		{"does not retry client errors", []any{404}, 3, http.MethodGet, 1, 404},
+		{"does not retry POST", []any{503, 200}, 3, http.MethodPost, 1, 503},
+		{"no retries configured", []any{503, 200}, 0, http.MethodGet, 1, 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := &scriptedTransport{results: tt.results}
+			client := &http.Client{Transport: &RetryTransport{Base: base, Retries: tt.retries, Delay: time.Millisecond}}
+
+			req, _ := http.NewRequest(tt.method, "http://example.com", nil)
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != tt.status || base.calls != tt.calls {
+				t.Errorf("Expected status %d after %d calls, got %d after %d", tt.status, tt.calls, resp.StatusCode, base.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+)
+
+// errStopped signals that the consumer of a stream stopped early
+var errStopped = errors.New("stream stopped by consumer")
+
+// EALFeaturesOf returns a stream over the features of already decoded layers
+func EALFeaturesOf(layers []EALLayer) iter.Seq2[EALFeature, error] {
+	return func(yield func(EALFeature, error) bool) {
+		for _, layer := range layers {
+			for _, feature := range layer.Features {
+				feature.Layer = layer.Layer
+				if !yield(feature, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// ArcGISFeaturesOf returns a stream over already decoded ArcGIS features
+func ArcGISFeaturesOf(features []ArcGISFeature) iter.Seq2[ArcGISFeature, error] {
+	return func(yield func(ArcGISFeature, error) bool) {
+		for _, feature := range features {
+			if !yield(feature, nil) {
+				return
+			}
+		}
+	}
+}
+
+// FailIfUnchanged passes seq through and, once it is exhausted, yields
+// ErrUnchanged if every response received by c was confirmed current.
+// Consumers that abort on errors therefore never replace their output.
+func FailIfUnchanged[T any](c *Client, seq iter.Seq2[T, error]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for v, err := range seq {
+			if !yield(v, err) || err != nil {
+				return
+			}
+		}
+		if !c.Modified() {
+			var zero T
+			yield(zero, ErrUnchanged)
+		}
+	}
+}
+
+// decodeEALStream decodes an EAL response incrementally, passing every
+// feature to yield together with the ID of its layer. Only one feature is
+// held in memory at a time. It returns errStopped if yield returns false.
+func decodeEALStream(r io.Reader, yield func(EALFeature) bool) error {
+	dec := json.NewDecoder(r)
+
+	if ok, err := beginArray(dec); err != nil || !ok {
+		return err
+	}
+	for dec.More() {
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+
+		// The layer ID precedes the features in every response seen so far
+		var layer string
+		for dec.More() {
+			key, err := readKey(dec)
+			if err != nil {
+				return err
+			}
+
+			switch key {
+			case "layer":
+				if err := dec.Decode(&layer); err != nil {
+					return err
+				}
+			case "features":
+				ok, err := beginArray(dec)
+				if err != nil {
+					return err
+				}
+				for ok && dec.More() {
+					var feature EALFeature
+					if err := dec.Decode(&feature); err != nil {
+						return err
+					}
+					feature.Layer = layer
+					if !yield(feature) {
+						return errStopped
+					}
+				}
+				if ok {
+					if err := expectDelim(dec, ']'); err != nil {
+						return err
+					}
+				}
+			default:
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// decodeArcGISQuery decodes an ArcGIS query response incrementally, passing
+// every feature to fn. It reports whether the service set
+// exceededTransferLimit, meaning the features were truncated. An error
+// reported in the body is returned as an *ArcGISError.
+func decodeArcGISQuery(r io.Reader, fn func(ArcGISFeature)) (exceeded bool, err error) {
+	dec := json.NewDecoder(r)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return false, err
+	}
+	for dec.More() {
+		key, err := readKey(dec)
+		if err != nil {
+			return false, err
+		}
+
+		switch key {
+		case "features":
+			ok, err := beginArray(dec)
+			if err != nil {
+				return false, err
+			}
+			for ok && dec.More() {
+				var feature ArcGISFeature
+				if err := dec.Decode(&feature); err != nil {
+					return false, err
+				}
+				fn(feature)
+			}
+			if ok {
+				if err := expectDelim(dec, ']'); err != nil {
+					return false, err
+				}
+			}
+		case "exceededTransferLimit":
+			if err := dec.Decode(&exceeded); err != nil {
+				return false, err
+			}
+		case "error":
+			var arcgisErr ArcGISError
+			if err := dec.Decode(&arcgisErr); err != nil {
+				return false, err
+			}
+			return false, &arcgisErr
+		default:
+			if err := skipValue(dec); err != nil {
+				return false, err
+			}
+		}
+	}
+	return exceeded, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != want {
+		return fmt.Errorf("unexpected JSON token %v, expected %q", tok, want)
+	}
+	return nil
+}
+
+// beginArray consumes the opening bracket of an array. It returns false
+// without error if the value is null.
+func beginArray(dec *json.Decoder) (bool, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return false, err
+	}
+	if tok == nil {
+		return false, nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return false, fmt.Errorf("unexpected JSON token %v, expected array", tok)
+	}
+	return true, nil
+}
+
+func readKey(dec *json.Decoder) (string, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return "", err
+	}
+	key, ok := tok.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected JSON token %v, expected object key", tok)
+	}
+	return key, nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var skipped json.RawMessage
+	return dec.Decode(&skipped)
+}
@@ -0,0 +1,118 @@
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEALStream(t *testing.T) {
+	testData, err := os.ReadFile("../../testdata/eal_known_coords.json")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+
+	var layers []EALLayer
+	if err := json.Unmarshal(testData, &layers); err != nil {
+		t.Fatalf("Failed to unmarshal test data: %v", err)
+	}
+
+	var streamed []EALFeature
+	err = decodeEALStream(strings.NewReader(string(testData)), func(feature EALFeature) bool {
+		streamed = append(streamed, feature)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode stream: %v", err)
+	}
+
+	i := 0
+	for _, layer := range layers {
+		for _, feature := range layer.Features {
+			if i >= len(streamed) {
+				t.Fatalf("Stream ended after %d features", len(streamed))
+			}
+			if streamed[i].ID != feature.ID || streamed[i].Layer != layer.Layer {
+				t.Errorf("Feature %d: expected %s in layer %s, got %s in layer %s",
+					i, feature.ID, layer.Layer, streamed[i].ID, streamed[i].Layer)
+			}
+			if len(streamed[i].Restrictions) != len(feature.Restrictions) {
+				t.Errorf("Feature %d: expected %d restrictions, got %d",
+					i, len(feature.Restrictions), len(streamed[i].Restrictions))
+			}
+			i++
+		}
+	}
+	if i != len(streamed) {
+		t.Errorf("Expected %d features, got %d", i, len(streamed))
+	}
+}
+
+func TestDecodeEALStream_EarlyStopAndNulls(t *testing.T) {
+	const body = `[
+		{"layer": "A", "name": "empty", "features": null},
+		{"layer": "B", "extra": {"nested": [1, 2]}, "features": [{"id": "1"}, {"id": "2"}]}
+	]`
+
+	var ids []string
+	err := decodeEALStream(strings.NewReader(body), func(feature EALFeature) bool {
+		ids = append(ids, feature.Layer+":"+feature.ID)
+		return false
+	})
+	if !errors.Is(err, errStopped) {
+		t.Errorf("Expected errStopped, got %v", err)
+	}
+	if len(ids) != 1 || ids[0] != "B:1" {
+		t.Errorf("Expected only B:1 before stopping, got %v", ids)
+	}
+
+	if err := decodeEALStream(strings.NewReader(`[{"layer": "A", "features": [{"id": `), func(EALFeature) bool { return true }); err == nil {
+		t.Error("Expected an error for truncated JSON")
+	}
+}
+
+func TestDecodeArcGISQuery(t *testing.T) {
+	testData, err := os.ReadFile("../../testdata/arcgis_sample.json")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+
+	var expected ArcGISQueryResponse
+	if err := json.Unmarshal(testData, &expected); err != nil {
+		t.Fatalf("Failed to unmarshal test data: %v", err)
+	}
+
+	var features []ArcGISFeature
+	exceeded, err := decodeArcGISQuery(strings.NewReader(string(testData)), func(feature ArcGISFeature) {
+		features = append(features, feature)
+	})
+	if err != nil {
+		t.Fatalf("Failed to decode query: %v", err)
+	}
+	if exceeded != expected.ExceededTransfer {
+		t.Errorf("Expected exceeded transfer limit %v, got %v", expected.ExceededTransfer, exceeded)
+	}
+
+	if len(features) != len(expected.Features) {
+		t.Fatalf("Expected %d features, got %d", len(expected.Features), len(features))
+	}
+	for i := range features {
+		if features[i].Attributes["objectid"] != expected.Features[i].Attributes["objectid"] {
+			t.Errorf("Feature %d: expected objectid %v, got %v",
+				i, expected.Features[i].Attributes["objectid"], features[i].Attributes["objectid"])
+		}
+	}
+
+	exceeded, err = decodeArcGISQuery(strings.NewReader(`{"features": [], "exceededTransferLimit": true}`), func(ArcGISFeature) {})
+	if err != nil || !exceeded {
+		t.Errorf("Expected exceeded transfer limit, got %v, %v", exceeded, err)
+	}
+
+	_, err = decodeArcGISQuery(strings.NewReader(`{"error": {"code": 498, "message": "Invalid token."}}`), func(ArcGISFeature) {})
+	var arcgisErr *ArcGISError
+	if !errors.As(err, &arcgisErr) || arcgisErr.Code != 498 {
+		t.Errorf("Expected ArcGIS error 498, got %v", err)
+	}
+}
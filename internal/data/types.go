@@ -1,6 +1,11 @@
 // Package data provides types and clients for accessing Lithuanian road information APIs.
 package data
 
+import (
+	"fmt"
+	"strings"
+)
+
 // EAL (Road Restrictions) Data Types
 
 // EALLayer represents a layer from the EAL API response
@@ -18,6 +23,9 @@ type EALFeature struct {
 	Icon         string           `json:"icon"`
 	Points       []EALPoint       `json:"points"`
 	Restrictions []EALRestriction `json:"restrictions"`
+
+	// Layer is the ID of the layer the feature was read from
+	Layer string `json:"-"`
 }
 
 // EALPoint represents a point with min/max values
@@ -57,6 +65,29 @@ type ArcGISGeometry struct {
 type ArcGISQueryResponse struct {
 	Features         []ArcGISFeature `json:"features"`
 	ExceededTransfer bool            `json:"exceededTransferLimit"`
+	Error            *ArcGISError    `json:"error,omitempty"`
+}
+
+// ArcGISObjectIDsResponse represents the response of a returnIdsOnly query
+type ArcGISObjectIDsResponse struct {
+	ObjectIDFieldName string       `json:"objectIdFieldName"`
+	ObjectIDs         []int64      `json:"objectIds"`
+	Error             *ArcGISError `json:"error,omitempty"`
+}
+
+// ArcGISError represents an error reported in the body of an ArcGIS response
+type ArcGISError struct {
+	Code    int      `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details"`
+}
+
+func (e *ArcGISError) Error() string {
+	msg := fmt.Sprintf("arcgis error %d: %s", e.Code, e.Message)
+	if len(e.Details) > 0 {
+		msg += " (" + strings.Join(e.Details, "; ") + ")"
+	}
+	return msg
 }
 
 // ArcGISServiceInfo represents service metadata
@@ -1,7 +1,10 @@
 package eismoinfo
 
 import (
+	"io"
+	"iter"
 	"net/http"
+	"os"
 
 	"github.com/dimchansky/lt-road-info/internal/converter"
 	"github.com/dimchansky/lt-road-info/internal/data"
@@ -15,15 +18,36 @@ func DownloadRestrictions(outputPath string) error {
 // DownloadRestrictionsWithClient downloads restrictions using a custom HTTP client
 // This allows for testing with go-vcr or other HTTP interceptors
 func DownloadRestrictionsWithClient(httpClient *http.Client, outputPath string) error {
-	// Create data client
-	client := data.NewClient(httpClient)
+	return DownloadRestrictionsWithDataClient(data.NewClient(httpClient), outputPath)
+}
+
+// DownloadRestrictionsWithDataClient downloads restrictions using a configured data client.
+// If the client is caching and upstream reported no changes, the existing output
+// is kept and data.ErrUnchanged is returned.
+func DownloadRestrictionsWithDataClient(client *data.Client, outputPath string) error {
+	return DownloadRestrictionsWithOptions(client, outputPath, converter.OutputOptions{})
+}
 
-	// Fetch data
-	layers, err := client.FetchEALData()
-	if err != nil {
-		return err
+// DownloadRestrictionsWithOptions downloads restrictions using a configured data client and
+// replaces outputPath according to opts
+func DownloadRestrictionsWithOptions(client *data.Client, outputPath string, opts converter.OutputOptions) error {
+	// Stream data straight into the converter
+	features := client.EALFeatures()
+	if _, err := os.Stat(outputPath); err == nil {
+		features = data.FailIfUnchanged(client, features)
 	}
 
-	// Convert to GPX
-	return converter.EALToGPX(layers, outputPath)
+	return converter.EALFeaturesToGPXWithOptions(features, outputPath, opts)
+}
+
+// WriteRestrictions downloads restrictions and writes them as GPX to w as
+// they are decoded, e.g. to stdout or an HTTP response
+func WriteRestrictions(client *data.Client, w io.Writer) error {
+	return converter.WriteEALGPX(w, client.EALFeatures())
+}
+
+// Tracks streams restrictions from upstream as WGS84 tracks. The
+// conversion counts are added to stats, if not nil, once the stream ends.
+func Tracks(client *data.Client, stats *converter.Stats) iter.Seq2[converter.Track, error] {
+	return converter.EALTracksWithStats(client.EALFeatures(), stats)
 }
@@ -1,6 +1,7 @@
 package eismoinfo
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/dimchansky/lt-road-info/internal/data"
 	"github.com/tkrajina/gpxgo/gpx"
 )
 
@@ -89,6 +91,59 @@ func TestDownloadRestrictionsWithMockedAPI(t *testing.T) {
 	}
 }
 
+func TestDownloadRestrictionsSkipsUnchangedData(t *testing.T) {
+	testData, err := os.ReadFile("../../testdata/eal_known_coords.json")
+	if err != nil {
+		t.Fatalf("Failed to load test data: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Write(testData)
+	}))
+	defer server.Close()
+
+	httpClient := &http.Client{
+		Transport: &mockTransport{
+			originalURL: "https://eismoinfo.lt/eismoinfo-backend/layer-dynamic-features/EAL?lks=true",
+			mockURL:     server.URL,
+			transport:   http.DefaultTransport,
+		},
+	}
+
+	tmpDir := t.TempDir()
+	cache, err := data.NewCache(filepath.Join(tmpDir, "cache"))
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+	outputPath := filepath.Join(tmpDir, "restrictions.gpx")
+
+	if err := DownloadRestrictionsWithDataClient(data.NewCachingClient(httpClient, cache), outputPath); err != nil {
+		t.Fatalf("First download failed: %v", err)
+	}
+
+	err = DownloadRestrictionsWithDataClient(data.NewCachingClient(httpClient, cache), outputPath)
+	if !errors.Is(err, data.ErrUnchanged) {
+		t.Fatalf("Expected ErrUnchanged on second download, got: %v", err)
+	}
+
+	// Without an existing output the cached body is converted again
+	if err := os.Remove(outputPath); err != nil {
+		t.Fatalf("Failed to remove output: %v", err)
+	}
+	if err := DownloadRestrictionsWithDataClient(data.NewCachingClient(httpClient, cache), outputPath); err != nil {
+		t.Fatalf("Download with missing output failed: %v", err)
+	}
+	if _, err := os.Stat(outputPath); err != nil {
+		t.Errorf("Output should be regenerated from the cache: %v", err)
+	}
+}
+
 func TestCoordinateTransformationRegression(t *testing.T) {
 	// This test specifically prevents the lat/lon mixup that caused Abu Dhabi coordinates
 	testCases := []struct {
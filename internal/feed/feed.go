@@ -0,0 +1,216 @@
+// Package feed keeps a history of added and changed restrictions and
+// renders it as Atom or RSS 2.0 with GeoRSS geometry.
+package feed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/watch"
+)
+
+const (
+	// DefaultMaxEntries is the number of entries rendered into a feed
+	DefaultMaxEntries = 100
+
+	// retention is how long a restriction is remembered after it was last
+	// listed upstream
+	retention = 30 * 24 * time.Hour
+
+	// maxLinePoints bounds the GeoRSS line of an entry
+	maxLinePoints = 100
+)
+
+// Entry kinds
+const (
+	Added   = "added"
+	Changed = "changed"
+)
+
+// Entry is the feed state of one restriction. The EAL feed carries no
+// validity dates, so the validity period is the time the restriction was
+// listed upstream: from Listed until Unlisted.
+type Entry struct {
+	ID         string         `json:"id"`
+	Title      string         `json:"title"`
+	Kind       string         `json:"kind"`
+	Listed     time.Time      `json:"listed"`
+	Updated    time.Time      `json:"updated"`
+	Unlisted   *time.Time     `json:"unlisted,omitempty"`
+	Properties map[string]any `json:"properties,omitempty"`
+	Line       [][2]float64   `json:"line,omitempty"`
+	Hash       string         `json:"hash"`
+}
+
+// Feed tracks restrictions across updates, optionally persisted in a
+// JSON file
+type Feed struct {
+	path       string
+	maxEntries int
+
+	mu      sync.RWMutex
+	entries map[string]*Entry
+}
+
+// Load reads the feed state at path. A missing file yields an empty feed
+// and an empty path keeps the state in memory only.
+func Load(path string) (*Feed, error) {
+	f := &Feed{path: path, maxEntries: DefaultMaxEntries, entries: make(map[string]*Entry)}
+	if path == "" {
+		return f, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed state: %w", err)
+	}
+	var entries []*Entry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse feed state %s: %w", path, err)
+	}
+	for _, e := range entries {
+		f.entries[e.ID] = e
+	}
+	return f, nil
+}
+
+// Update records the current restrictions at time t and returns the
+// number of restrictions that were added or changed. A restriction that
+// was unlisted and reappears counts as added again.
+func (f *Feed) Update(tracks []converter.Track, t time.Time) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	t = t.UTC().Truncate(time.Second)
+	updated := 0
+	listed := make(map[string]bool, len(tracks))
+	for _, track := range tracks {
+		id := watch.TrackID(track)
+		listed[id] = true
+		hash := hashTrack(track)
+
+		e, ok := f.entries[id]
+		switch {
+		case !ok || e.Unlisted != nil:
+			e = &Entry{ID: id, Kind: Added, Listed: t}
+			f.entries[id] = e
+		case e.Hash != hash:
+			e.Kind = Changed
+		default:
+			continue
+		}
+		e.Title = track.Name
+		e.Updated = t
+		e.Properties = track.Properties
+		e.Line = line(track)
+		e.Hash = hash
+		updated++
+	}
+
+	for id, e := range f.entries {
+		switch {
+		case listed[id]:
+		case e.Unlisted == nil:
+			e.Unlisted = &t
+		case t.Sub(*e.Unlisted) > retention:
+			delete(f.entries, id)
+		}
+	}
+	return updated
+}
+
+// Entries returns the most recently updated entries, newest first
+func (f *Feed) Entries() []Entry {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(f.entries))
+	for _, e := range f.entries {
+		entries = append(entries, *e)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := b.Updated.Compare(a.Updated); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	if len(entries) > f.maxEntries {
+		entries = entries[:f.maxEntries]
+	}
+	return entries
+}
+
+// Save writes the feed state file. It is a no-op for an in-memory feed.
+func (f *Feed) Save() error {
+	if f.path == "" {
+		return nil
+	}
+
+	f.mu.RLock()
+	entries := make([]*Entry, 0, len(f.entries))
+	for _, e := range f.entries {
+		entries = append(entries, e)
+	}
+	slices.SortFunc(entries, func(a, b *Entry) int { return strings.Compare(a.ID, b.ID) })
+	content, err := json.Marshal(entries)
+	f.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write feed state: %w", err)
+	}
+	if err := os.Rename(tmp, f.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write feed state: %w", err)
+	}
+	return nil
+}
+
+// hashTrack fingerprints what a reader would notice changing
+func hashTrack(track converter.Track) string {
+	content, _ := json.Marshal(struct {
+		Name       string
+		Segments   [][]converter.Point
+		Properties map[string]any
+	}{track.Name, track.Segments, track.Properties})
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+// line returns the first segment of track as lat/lon pairs, thinned out
+// evenly to at most maxLinePoints while keeping both ends
+func line(track converter.Track) [][2]float64 {
+	var segment []converter.Point
+	for _, s := range track.Segments {
+		if len(s) >= 2 {
+			segment = s
+			break
+		}
+	}
+	if segment == nil {
+		return nil
+	}
+
+	n := min(len(segment), maxLinePoints)
+	points := make([][2]float64, n)
+	for i := range n {
+		p := segment[i*(len(segment)-1)/(n-1)]
+		points[i] = [2]float64{p.Lat, p.Lon}
+	}
+	return points
+}
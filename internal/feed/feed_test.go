@@ -0,0 +1,168 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func restriction(id, name string, lon float64) converter.Track {
+	return converter.Track{
+		Name:       name,
+		Segments:   [][]converter.Point{{{Lat: 54.68, Lon: lon}, {Lat: 54.69, Lon: lon + 0.01}}},
+		Properties: map[string]any{"id": id},
+	}
+}
+
+var (
+	day1 = time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC)
+	day2 = day1.Add(24 * time.Hour)
+	day3 = day2.Add(24 * time.Hour)
+)
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.json")
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if n := f.Update([]converter.Track{restriction("TR:1", "Road works", 25.27), restriction("TR:2", "Closed", 25.30)}, day1); n != 2 {
+		t.Errorf("Expected 2 added, got %d", n)
+	}
+	if n := f.Update([]converter.Track{restriction("TR:1", "Road works", 25.27), restriction("TR:2", "Closed", 25.30)}, day2); n != 0 {
+		t.Errorf("Expected no changes, got %d", n)
+	}
+
+	// TR:1 moves, TR:2 disappears, TR:3 appears
+	if n := f.Update([]converter.Track{restriction("TR:1", "Road works", 25.40), restriction("TR:3", "Detour", 25.50)}, day3); n != 2 {
+		t.Errorf("Expected 2 updates, got %d", n)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	f, err = Load(path)
+	if err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	entries := f.Entries()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %+v", entries)
+	}
+
+	byID := make(map[string]Entry)
+	for _, e := range entries {
+		byID[e.ID] = e
+	}
+	if e := byID["TR:1"]; e.Kind != Changed || !e.Listed.Equal(day1) || !e.Updated.Equal(day3) {
+		t.Errorf("Unexpected TR:1 entry: %+v", e)
+	}
+	if e := byID["TR:2"]; e.Unlisted == nil || !e.Unlisted.Equal(day3) || !strings.Contains(e.Description(), "no longer listed") {
+		t.Errorf("Unexpected TR:2 entry: %+v", e)
+	}
+	if e := byID["TR:3"]; e.Kind != Added || !strings.Contains(e.Description(), "still in effect") {
+		t.Errorf("Unexpected TR:3 entry: %+v", e)
+	}
+	if entries[len(entries)-1].ID != "TR:2" {
+		t.Errorf("Expected the oldest update last, got %s", entries[len(entries)-1].ID)
+	}
+
+	// Unlisted restrictions are forgotten after the retention period
+	f.Update(nil, day3.Add(retention+time.Hour))
+	f.Update(nil, day3.Add(2*retention+2*time.Hour))
+	if n := len(f.Entries()); n != 0 {
+		t.Errorf("Expected all entries to expire, got %d", n)
+	}
+}
+
+func TestWriteAtom(t *testing.T) {
+	f, _ := Load("")
+	f.Update([]converter.Track{restriction("TR:1", "Road works <A1>", 25.27)}, day1)
+
+	var buf bytes.Buffer
+	if err := f.WriteAtom(&buf, Info{Name: "restrictions", Title: "Restrictions", Link: "https://example.com/restrictions.atom"}); err != nil {
+		t.Fatalf("WriteAtom failed: %v", err)
+	}
+
+	var doc struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		ID      string   `xml:"id"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Summary string `xml:"summary"`
+			Line    string `xml:"http://www.georss.org/georss line"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid Atom: %v\n%s", err, buf.String())
+	}
+	if len(doc.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(doc.Entries))
+	}
+	e := doc.Entries[0]
+	if e.ID != "urn:lt-road-info:restrictions:TR:1" || e.Title != "New: Road works <A1>" {
+		t.Errorf("Unexpected entry %+v", e)
+	}
+	if e.Line != "54.680000 25.270000 54.690000 25.280000" {
+		t.Errorf("Unexpected GeoRSS line %q", e.Line)
+	}
+	if !strings.Contains(e.Summary, "Valid since 2025-06-01 08:00 UTC") {
+		t.Errorf("Expected the validity period in the summary, got %q", e.Summary)
+	}
+}
+
+func TestWriteRSS(t *testing.T) {
+	f, _ := Load("")
+	f.Update([]converter.Track{restriction("TR:1", "Road works", 25.27)}, day1)
+
+	var buf bytes.Buffer
+	if err := f.WriteRSS(&buf, Info{Name: "restrictions", Title: "Restrictions"}); err != nil {
+		t.Fatalf("WriteRSS failed: %v", err)
+	}
+
+	var doc struct {
+		Version string `xml:"version,attr"`
+		Items   []struct {
+			GUID struct {
+				IsPermaLink string `xml:"isPermaLink,attr"`
+				Value       string `xml:",chardata"`
+			} `xml:"guid"`
+			PubDate string `xml:"pubDate"`
+			Link    string `xml:"link"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid RSS: %v\n%s", err, buf.String())
+	}
+	if doc.Version != "2.0" || len(doc.Items) != 1 {
+		t.Fatalf("Unexpected document: %+v", doc)
+	}
+	item := doc.Items[0]
+	if item.GUID.Value != "TR:1" || item.GUID.IsPermaLink != "false" {
+		t.Errorf("Unexpected GUID %+v", item.GUID)
+	}
+	if item.PubDate != "Sun, 01 Jun 2025 08:00:00 +0000" {
+		t.Errorf("Unexpected pubDate %q", item.PubDate)
+	}
+	if !strings.HasPrefix(item.Link, "https://www.openstreetmap.org/?mlat=54.68000&mlon=25.27000") {
+		t.Errorf("Unexpected link %q", item.Link)
+	}
+}
+
+func TestLineThinning(t *testing.T) {
+	var segment []converter.Point
+	for i := range 1000 {
+		segment = append(segment, converter.Point{Lat: float64(i), Lon: float64(i)})
+	}
+	got := line(converter.Track{Segments: [][]converter.Point{segment}})
+	if len(got) != maxLinePoints || got[0][0] != 0 || got[len(got)-1][0] != 999 {
+		t.Errorf("Expected %d points keeping both ends, got %d from %v to %v", maxLinePoints, len(got), got[0], got[len(got)-1])
+	}
+}
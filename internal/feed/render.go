@@ -0,0 +1,242 @@
+package feed
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	georssNamespace = "http://www.georss.org/georss"
+
+	// idPrefix makes restriction IDs valid Atom IRIs
+	idPrefix = "urn:lt-road-info:"
+)
+
+// Info describes the feed document
+type Info struct {
+	// Name is used in the feed ID, e.g. "restrictions"
+	Name  string
+	Title string
+
+	// Link is the address the feed is served from, if any
+	Link string
+}
+
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"feed"`
+	Namespace string      `xml:"xmlns,attr"`
+	GeoRSS    string      `xml:"xmlns:georss,attr"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Author    string      `xml:"author>name"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Links     []atomLink   `xml:"link"`
+	Category  atomCategory `xml:"category"`
+	Summary   string       `xml:"summary"`
+	Line      string       `xml:"georss:line,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// WriteAtom renders the most recent entries as an Atom 1.0 document
+func (f *Feed) WriteAtom(w io.Writer, info Info) error {
+	entries := f.Entries()
+
+	doc := atomFeed{
+		Namespace: atomNamespace,
+		GeoRSS:    georssNamespace,
+		ID:        idPrefix + "feed:" + info.Name,
+		Title:     info.Title,
+		Updated:   updated(entries).Format(time.RFC3339),
+		Author:    "lt-road-info",
+		Generator: "lt-road-info",
+	}
+	if info.Link != "" {
+		doc.Links = []atomLink{{Rel: "self", Href: info.Link}}
+	}
+	for _, e := range entries {
+		entry := atomEntry{
+			ID:        idPrefix + info.Name + ":" + e.ID,
+			Title:     e.heading(),
+			Updated:   e.Updated.Format(time.RFC3339),
+			Published: e.Listed.Format(time.RFC3339),
+			Category:  atomCategory{Term: e.Kind},
+			Summary:   e.Description(),
+			Line:      e.georssLine(),
+		}
+		if link := e.mapLink(); link != "" {
+			entry.Links = []atomLink{{Rel: "alternate", Href: link}}
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	GeoRSS  string     `xml:"xmlns:georss,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Generator     string    `xml:"generator"`
+	Self          *atomLink `xml:"atom:link,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category"`
+	Description string  `xml:"description"`
+	Line        string  `xml:"georss:line,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// WriteRSS renders the most recent entries as an RSS 2.0 document
+func (f *Feed) WriteRSS(w io.Writer, info Info) error {
+	entries := f.Entries()
+
+	link := info.Link
+	if link == "" {
+		link = "https://eismoinfo.lt"
+	}
+	doc := rssDocument{
+		Version: "2.0",
+		Atom:    atomNamespace,
+		GeoRSS:  georssNamespace,
+		Channel: rssChannel{
+			Title:         info.Title,
+			Link:          link,
+			Description:   info.Title + ": added and changed entries",
+			LastBuildDate: updated(entries).Format(time.RFC1123Z),
+			Generator:     "lt-road-info",
+		},
+	}
+	if info.Link != "" {
+		doc.Channel.Self = &atomLink{Rel: "self", Href: info.Link}
+	}
+	for _, e := range entries {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       e.heading(),
+			Link:        e.mapLink(),
+			GUID:        rssGUID{Value: e.ID},
+			PubDate:     e.Updated.Format(time.RFC1123Z),
+			Category:    e.Kind,
+			Description: e.Description(),
+			Line:        e.georssLine(),
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("failed to write feed: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// updated returns the time of the newest entry, or now for an empty feed
+func updated(entries []Entry) time.Time {
+	if len(entries) == 0 {
+		return time.Now().UTC().Truncate(time.Second)
+	}
+	return entries[0].Updated
+}
+
+func (e Entry) heading() string {
+	if e.Kind == Changed {
+		return "Changed: " + e.Title
+	}
+	return "New: " + e.Title
+}
+
+// Description returns a human readable summary of the entry with its
+// validity period
+func (e Entry) Description() string {
+	var b strings.Builder
+	switch e.Kind {
+	case Changed:
+		fmt.Fprintf(&b, "Restriction updated: %s.", e.Title)
+	default:
+		fmt.Fprintf(&b, "New restriction: %s.", e.Title)
+	}
+
+	const layout = "2006-01-02 15:04 UTC"
+	if e.Unlisted != nil {
+		fmt.Fprintf(&b, " Valid from %s to %s (no longer listed).", e.Listed.Format(layout), e.Unlisted.Format(layout))
+	} else {
+		fmt.Fprintf(&b, " Valid since %s, still in effect.", e.Listed.Format(layout))
+	}
+
+	if len(e.Line) > 0 {
+		start, end := e.Line[0], e.Line[len(e.Line)-1]
+		fmt.Fprintf(&b, " From %.5f, %.5f to %.5f, %.5f.", start[0], start[1], end[0], end[1])
+	}
+	return b.String()
+}
+
+// georssLine formats the line as GeoRSS Simple "lat lon lat lon ..."
+func (e Entry) georssLine() string {
+	if len(e.Line) < 2 {
+		return ""
+	}
+	parts := make([]string, 0, 2*len(e.Line))
+	for _, p := range e.Line {
+		parts = append(parts, strconv.FormatFloat(p[0], 'f', 6, 64), strconv.FormatFloat(p[1], 'f', 6, 64))
+	}
+	return strings.Join(parts, " ")
+}
+
+// mapLink points to the start of the restriction on OpenStreetMap
+func (e Entry) mapLink() string {
+	if len(e.Line) == 0 {
+		return ""
+	}
+	lat := strconv.FormatFloat(e.Line[0][0], 'f', 5, 64)
+	lon := strconv.FormatFloat(e.Line[0][1], 'f', 5, 64)
+	q := url.Values{"mlat": {lat}, "mlon": {lon}}
+	return "https://www.openstreetmap.org/?" + q.Encode() + "#map=15/" + lat + "/" + lon
+}
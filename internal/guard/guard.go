@@ -0,0 +1,189 @@
+// Package guard provides sanity checks that stop a collapsed upstream
+// dataset from replacing the last good output.
+package guard
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrRejected is returned when a dataset fails a guard
+var ErrRejected = errors.New("dataset rejected by sanity guard")
+
+// Thresholds configures the checks applied to a dataset before it is written.
+// They count the features read from upstream, before any filter, so that
+// changing the filters does not look like upstream growing or collapsing.
+type Thresholds struct {
+	// MinFeatures is the minimum number of upstream features; 0 disables
+	// the check
+	MinFeatures int
+
+	// MaxDropPercent is the largest allowed drop in the upstream feature
+	// count versus the last successful run, in percent; 0 disables the check
+	MaxDropPercent float64
+}
+
+// Check validates the upstream feature count of source against the
+// thresholds and the last successful run, if there was one
+func (t Thresholds) Check(source string, upstreamFeatures int, last *Run) error {
+	if t.MinFeatures > 0 && upstreamFeatures < t.MinFeatures {
+		return fmt.Errorf("%w: %s has %d upstream features, minimum is %d", ErrRejected, source, upstreamFeatures, t.MinFeatures)
+	}
+
+	if t.MaxDropPercent > 0 && last != nil && last.UpstreamFeatures > 0 {
+		drop := float64(last.UpstreamFeatures-upstreamFeatures) / float64(last.UpstreamFeatures) * 100
+		if drop > t.MaxDropPercent {
+			return fmt.Errorf("%w: %s dropped from %d to %d upstream features (%.1f%%) since %s, maximum drop is %.1f%%",
+				ErrRejected, source, last.UpstreamFeatures, upstreamFeatures, drop, last.Time.Format(time.RFC3339), t.MaxDropPercent)
+		}
+	}
+
+	return nil
+}
+
+// Run describes a successful run for one source
+type Run struct {
+	// UpstreamFeatures is the number of features read from upstream
+	UpstreamFeatures int       `json:"upstream_features"`
+	Time             time.Time `json:"time"`
+
+	// Config identifies the settings the outputs were generated with, see
+	// config.Source.Fingerprint
+	Config string `json:"config,omitempty"`
+}
+
+// State records the last successful run per source in a JSON file
+type State struct {
+	path string
+
+	mu   sync.Mutex
+	runs map[string]Run
+}
+
+// LoadState reads the state file at path. A missing file yields an empty state.
+func LoadState(path string) (*State, error) {
+	s := &State{path: path, runs: make(map[string]Run)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guard state: %w", err)
+	}
+
+	if err := json.Unmarshal(content, &s.runs); err != nil {
+		return nil, fmt.Errorf("failed to parse guard state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Last returns the last successful run of source
+func (s *State) Last(source string) (Run, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.runs[source]
+	return run, ok
+}
+
+// Record stores a successful run of source
+func (s *State) Record(source string, run Run) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs[source] = run
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	s.mu.Lock()
+	content, err := json.MarshalIndent(s.runs, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write guard state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write guard state: %w", err)
+	}
+	return nil
+}
+
+// Guard applies thresholds to several sources and remembers the upstream
+// counts of datasets it accepted until the caller confirms they were written
+type Guard struct {
+	thresholds Thresholds
+	state      *State
+
+	mu       sync.Mutex
+	accepted map[string]int
+}
+
+// New creates a guard. A nil state disables the drop check.
+func New(thresholds Thresholds, state *State) *Guard {
+	return &Guard{
+		thresholds: thresholds,
+		state:      state,
+		accepted:   make(map[string]int),
+	}
+}
+
+// Validator returns a function that checks the upstream feature count of
+// source, as returned by upstreamFeatures. It is meant to be called right
+// before the output is replaced, once the stream has been read; the number
+// of items written, which depends on the filters, is ignored.
+func (g *Guard) Validator(source string, upstreamFeatures func() int) func(items int) error {
+	return func(int) error {
+		features := upstreamFeatures()
+		var last *Run
+		if g.state != nil {
+			if run, ok := g.state.Last(source); ok {
+				last = &run
+			}
+		}
+
+		if err := g.thresholds.Check(source, features, last); err != nil {
+			return err
+		}
+
+		g.mu.Lock()
+		g.accepted[source] = features
+		g.mu.Unlock()
+		return nil
+	}
+}
+
+// Written records the dataset last accepted for source, generated with the
+// settings identified by config, as the new successful run. It is a no-op
+// if nothing was accepted.
+func (g *Guard) Written(source, config string, t time.Time) {
+	g.mu.Lock()
+	features, ok := g.accepted[source]
+	delete(g.accepted, source)
+	g.mu.Unlock()
+
+	if ok && g.state != nil {
+		g.state.Record(source, Run{UpstreamFeatures: features, Time: t, Config: config})
+	}
+}
+
+// Current reports whether the last successful run of source was generated
+// with the settings identified by config, so that its outputs can be kept
+// when upstream has not changed. Without a state nothing is current.
+func (g *Guard) Current(source, config string) bool {
+	if g.state == nil {
+		return false
+	}
+	run, ok := g.state.Last(source)
+	return ok && run.Config == config
+}
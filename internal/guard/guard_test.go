@@ -0,0 +1,147 @@
+package guard
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestThresholdsCheck(t *testing.T) {
+	lastRun := &Run{UpstreamFeatures: 200, Time: time.Date(2025, 6, 8, 6, 0, 0, 0, time.UTC)}
+
+	tests := []struct {
+		name       string
+		thresholds Thresholds
+		features   int
+		last       *Run
+		wantErr    bool
+	}{
+		{"disabled", Thresholds{}, 0, lastRun, false},
+		{"empty upstream", Thresholds{MinFeatures: 1}, 0, nil, true},
+		{"above minimum", Thresholds{MinFeatures: 10}, 10, nil, false},
+		{"drop within limit", Thresholds{MaxDropPercent: 50}, 100, lastRun, false},
+		{"drop above limit", Thresholds{MaxDropPercent: 50}, 99, lastRun, true},
+		{"growth", Thresholds{MaxDropPercent: 10}, 500, lastRun, false},
+		{"no previous run", Thresholds{MaxDropPercent: 10}, 1, nil, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.thresholds.Check("restrictions", tc.features, tc.last)
+			if tc.wantErr != (err != nil) {
+				t.Fatalf("Expected error: %v, got: %v", tc.wantErr, err)
+			}
+			if err != nil && !errors.Is(err, ErrRejected) {
+				t.Errorf("Expected ErrRejected, got: %v", err)
+			}
+		})
+	}
+}
+
+func upstream(n int) func() int {
+	return func() int { return n }
+}
+
+func TestGuardIgnoresFilteredCount(t *testing.T) {
+	state, err := LoadState(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to load missing state: %v", err)
+	}
+	state.Record("restrictions", Run{UpstreamFeatures: 100, Time: time.Now()})
+	g := New(Thresholds{MinFeatures: 1, MaxDropPercent: 50}, state)
+
+	// A narrow filter writing 2 of 100 upstream features is not a collapse
+	if err := g.Validator("restrictions", upstream(100))(2); err != nil {
+		t.Fatalf("Expected a filtered dataset to be accepted, got: %v", err)
+	}
+	g.Written("restrictions", "cfg", time.Now())
+	if run, _ := state.Last("restrictions"); run.UpstreamFeatures != 100 {
+		t.Errorf("Expected the upstream count to be recorded, got %d", run.UpstreamFeatures)
+	}
+
+	// Nor does a wide filter hide an upstream collapse
+	if err := g.Validator("restrictions", upstream(10))(10); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected rejection of collapsed upstream, got: %v", err)
+	}
+}
+
+func TestGuardCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to load missing state: %v", err)
+	}
+	g := New(Thresholds{}, state)
+	if g.Current("restrictions", "a") {
+		t.Error("A source that was never written should not be current")
+	}
+
+	if err := g.Validator("restrictions", upstream(10))(10); err != nil {
+		t.Fatalf("Expected dataset to be accepted, got: %v", err)
+	}
+	g.Written("restrictions", "a", time.Now())
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	reloaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to reload state: %v", err)
+	}
+	g = New(Thresholds{}, reloaded)
+	if !g.Current("restrictions", "a") {
+		t.Error("Expected outputs of the same settings to be current")
+	}
+	if g.Current("restrictions", "b") {
+		t.Error("Expected outputs of other settings to be stale")
+	}
+	if New(Thresholds{}, nil).Current("restrictions", "a") {
+		t.Error("Without a state nothing should be current")
+	}
+}
+
+func TestGuardRecordsOnlyWrittenRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to load missing state: %v", err)
+	}
+	state.Record("restrictions", Run{UpstreamFeatures: 100, Time: time.Now()})
+
+	g := New(Thresholds{MinFeatures: 1, MaxDropPercent: 50}, state)
+
+	// A collapsed dataset is rejected and the previous run is kept
+	if err := g.Validator("restrictions", upstream(3))(3); !errors.Is(err, ErrRejected) {
+		t.Fatalf("Expected rejection of collapsed dataset, got: %v", err)
+	}
+	g.Written("restrictions", "cfg", time.Now())
+	if run, _ := state.Last("restrictions"); run.UpstreamFeatures != 100 {
+		t.Errorf("Rejected dataset should not be recorded, got %d features", run.UpstreamFeatures)
+	}
+
+	// An accepted dataset becomes the new baseline once written
+	if err := g.Validator("restrictions", upstream(80))(80); err != nil {
+		t.Fatalf("Expected dataset to be accepted, got: %v", err)
+	}
+	g.Written("restrictions", "cfg", time.Now())
+	if err := g.Validator("speed-control", upstream(5))(5); err != nil {
+		t.Fatalf("Expected first run of a source to be accepted, got: %v", err)
+	}
+
+	if err := state.Save(); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+
+	reloaded, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to reload state: %v", err)
+	}
+	if run, ok := reloaded.Last("restrictions"); !ok || run.UpstreamFeatures != 80 {
+		t.Errorf("Expected 80 recorded features, got %+v (found: %v)", run, ok)
+	}
+	if _, ok := reloaded.Last("speed-control"); ok {
+		t.Error("A source that was validated but never written should not be recorded")
+	}
+}
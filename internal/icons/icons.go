@@ -0,0 +1,306 @@
+// Package icons classifies road restrictions by their eismoinfo.lt icon
+// code, using an embedded catalogue extended by the user's, or else, where
+// unambiguous, by the icon or name of their feature.
+package icons
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+//go:embed catalog.csv
+var catalogCSV []byte
+
+// Kinds of restriction
+const (
+	KindClosure    = "closure"
+	KindSpeedLimit = "speed-limit"
+	KindWeight     = "weight"
+	KindAxleLoad   = "axle-load"
+	KindHeight     = "height"
+	KindWidth      = "width"
+	KindLength     = "length"
+	KindRoadWorks  = "road-works"
+
+	// KindHeavyVehicles closes a road to heavy vehicles (trucks and buses)
+	KindHeavyVehicles = "heavy-vehicles"
+)
+
+// Kinds lists the valid kinds of the catalogue
+var Kinds = []string{KindClosure, KindSpeedLimit, KindWeight, KindAxleLoad, KindHeight, KindWidth, KindLength, KindRoadWorks, KindHeavyVehicles}
+
+// Units maps the limit kinds to the unit of their iconValue, which their
+// catalogue entries must state
+var Units = map[string]string{
+	KindWeight:   "t",
+	KindAxleLoad: "t",
+	KindHeight:   "m",
+	KindWidth:    "m",
+	KindLength:   "m",
+}
+
+// Sources of a classification
+const (
+	// SourceIcon is the catalogue entry of the restriction's own icon code
+	SourceIcon = "icon"
+
+	// SourceFeature is the icon or name of the restriction's feature, which
+	// describe the feature as a whole rather than each restriction
+	SourceFeature = "feature"
+)
+
+// Classification is the kind of a restriction and where it came from; both
+// are empty for an unclassified restriction
+type Classification struct {
+	Kind   string
+	Source string
+}
+
+// Categories group kinds into the layers a navigation app toggles as a
+// whole; CategoryOther holds everything unclassified
+const (
+	CategoryClosures   = "closures"
+	CategorySpeed      = "speed-limits"
+	CategoryWeight     = "weight"
+	CategoryDimensions = "dimensions"
+	CategoryRoadWorks  = "road-works"
+	CategoryOther      = "other"
+)
+
+// Categories lists every category
+var Categories = []string{CategoryClosures, CategorySpeed, CategoryWeight, CategoryDimensions, CategoryRoadWorks, CategoryOther}
+
+// Icon is an entry of the catalogue
+type Icon struct {
+	Code string
+	Kind string
+
+	// Unit is the unit of the restriction's iconValue, if it has one
+	Unit        string
+	Description string
+}
+
+// namePatterns classify a feature name in Lithuanian, most specific first:
+// "Kelio remontas" is road works, but "Masės ribojimas kelio remonto metu"
+// a weight limit. Go's \b only knows ASCII letters, so no pattern ends in
+// one after a Lithuanian letter.
+var namePatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{KindClosure, regexp.MustCompile(`(?i)uždar`)},
+	{KindHeavyVehicles, regexp.MustCompile(`(?i)sunkiasvor|krovinin`)},
+	{KindAxleLoad, regexp.MustCompile(`(?i)\baš(ies|inė)`)},
+	{KindWeight, regexp.MustCompile(`(?i)\bmasė|svor`)},
+	{KindHeight, regexp.MustCompile(`(?i)aukš(t|č)`)},
+	{KindWidth, regexp.MustCompile(`(?i)ploč|plotis`)},
+	{KindLength, regexp.MustCompile(`(?i)\bilgi(s|o)\b`)},
+	{KindSpeedLimit, regexp.MustCompile(`(?i)greit|greič`)},
+	{KindRoadWorks, regexp.MustCompile(`(?i)remont|darbai|darbų|rekonstr|statyb`)},
+}
+
+var (
+	catalogOnce sync.Once
+	embedded    map[string]Icon
+	catalogErr  error
+
+	// user holds the rows of the catalogue given to Load
+	userMu sync.RWMutex
+	user   map[string]Icon
+)
+
+// embeddedCatalog returns the embedded catalogue by icon code
+func embeddedCatalog() (map[string]Icon, error) {
+	catalogOnce.Do(func() {
+		var list []Icon
+		if list, catalogErr = ReadCatalog(bytes.NewReader(catalogCSV)); catalogErr == nil {
+			embedded = byCode(list)
+		}
+	})
+	return embedded, catalogErr
+}
+
+// Catalog returns the catalogue in effect by icon code: the embedded one
+// with the rows given to Load on top
+func Catalog() (map[string]Icon, error) {
+	c, err := embeddedCatalog()
+	if err != nil {
+		return nil, err
+	}
+	c = maps.Clone(c)
+	userMu.RLock()
+	maps.Copy(c, user)
+	userMu.RUnlock()
+	return c, nil
+}
+
+// Load reads a catalogue file in the format of the embedded one, whose rows
+// add to the embedded ones and replace those of the same code, e.g. to
+// catalogue the limit icons the vehicle filter needs before they are
+// embedded. It applies to the whole process and replaces the rows of an
+// earlier Load.
+func Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	list, err := ReadCatalog(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	userMu.Lock()
+	user = byCode(list)
+	userMu.Unlock()
+	return nil
+}
+
+// Digest identifies the catalogue in effect, so that outputs classified
+// with another one can be told apart
+func Digest() string {
+	c, _ := Catalog()
+	h := sha256.New()
+	for _, code := range slices.Sorted(maps.Keys(c)) {
+		fmt.Fprintf(h, "%s,%s,%s\n", code, c[code].Kind, c[code].Unit)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+func byCode(list []Icon) map[string]Icon {
+	m := make(map[string]Icon, len(list))
+	for _, icon := range list {
+		m[icon.Code] = icon
+	}
+	return m
+}
+
+// ReadCatalog reads a CSV table with the columns code, kind, unit and
+// description. Lines starting with # and a header row are skipped. Limit
+// kinds must state the unit of Units.
+func ReadCatalog(r io.Reader) ([]Icon, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 4
+
+	var icons []Icon
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return icons, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == "code" {
+			continue
+		}
+		if !slices.Contains(Kinds, record[1]) {
+			line, _ := cr.FieldPos(1)
+			return nil, fmt.Errorf("line %d: unknown kind %q", line, record[1])
+		}
+		if unit, ok := Units[record[1]]; ok && record[2] != unit {
+			line, _ := cr.FieldPos(2)
+			return nil, fmt.Errorf("line %d: %s needs unit %s, got %q", line, record[1], unit, record[2])
+		}
+		icons = append(icons, Icon{Code: record[0], Kind: record[1], Unit: record[2], Description: record[3]})
+	}
+}
+
+// Lookup returns the catalogue entry of an icon code
+func Lookup(code string) (Icon, bool) {
+	userMu.RLock()
+	icon, ok := user[code]
+	userMu.RUnlock()
+	if ok {
+		return icon, true
+	}
+	c, err := embeddedCatalog()
+	if err != nil {
+		return Icon{}, false
+	}
+	icon, ok = c[code]
+	return icon, ok
+}
+
+// Classify returns the kind of every restriction of a feature, given their
+// icon codes. A restriction whose code is in the catalogue has the kind of
+// its entry. The others take the kind of the feature's icon or, failing
+// that, its name, but only where that is unambiguous: the feature describes
+// the event as a whole, so its kind is given only when all uncatalogued
+// restrictions share one code and no catalogued one already has that kind.
+// Otherwise they are left unclassified.
+func Classify(featureIcon, featureName string, codes []string) []Classification {
+	result := make([]Classification, len(codes))
+	var (
+		unknown  []string
+		assigned []string
+	)
+	for i, code := range codes {
+		if icon, ok := Lookup(code); ok {
+			result[i] = Classification{Kind: icon.Kind, Source: SourceIcon}
+			assigned = append(assigned, icon.Kind)
+		} else if !slices.Contains(unknown, code) {
+			unknown = append(unknown, code)
+		}
+	}
+	if len(unknown) != 1 {
+		return result
+	}
+
+	kind := KindFromName(featureName)
+	if icon, ok := Lookup(featureIcon); ok {
+		kind = icon.Kind
+	}
+	if kind == "" || slices.Contains(assigned, kind) {
+		return result
+	}
+	for i := range result {
+		if result[i].Kind == "" {
+			result[i] = Classification{Kind: kind, Source: SourceFeature}
+		}
+	}
+	return result
+}
+
+// KindFromName returns the kind of restriction a feature name describes,
+// "" if it is not recognized
+func KindFromName(name string) string {
+	for _, p := range namePatterns {
+		if p.re.MatchString(name) {
+			return p.kind
+		}
+	}
+	return ""
+}
+
+// Category returns the category of a kind
+func Category(kind string) string {
+	switch kind {
+	case KindClosure:
+		return CategoryClosures
+	case KindSpeedLimit:
+		return CategorySpeed
+	case KindWeight, KindAxleLoad, KindHeavyVehicles:
+		return CategoryWeight
+	case KindHeight, KindWidth, KindLength:
+		return CategoryDimensions
+	case KindRoadWorks:
+		return CategoryRoadWorks
+	}
+	return CategoryOther
+}
+
+// IsCategory reports whether s names a category
+func IsCategory(s string) bool {
+	return slices.Contains(Categories, s)
+}
@@ -0,0 +1,136 @@
+package icons
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalog(t *testing.T) {
+	c, err := Catalog()
+	if err != nil {
+		t.Fatalf("embedded catalogue is invalid: %v", err)
+	}
+	if icon := c["76"]; icon.Kind != KindSpeedLimit || icon.Unit != "km/h" {
+		t.Errorf("unexpected icon 76: %+v", icon)
+	}
+	if icon := c["57"]; icon.Kind != KindRoadWorks {
+		t.Errorf("unexpected icon 57: %+v", icon)
+	}
+}
+
+func TestReadCatalog(t *testing.T) {
+	list, err := ReadCatalog(strings.NewReader("# comment\ncode,kind,unit,description\n1,weight,t,Maximum weight\n"))
+	if err != nil || len(list) != 1 || list[0] != (Icon{Code: "1", Kind: KindWeight, Unit: "t", Description: "Maximum weight"}) {
+		t.Errorf("ReadCatalog = %+v, %v", list, err)
+	}
+	if _, err := ReadCatalog(strings.NewReader("code,kind,unit,description\n1,heavy,t,x\n")); err == nil || err.Error() != `line 2: unknown kind "heavy"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := ReadCatalog(strings.NewReader("1,weight,kg,x\n")); err == nil || err.Error() != `line 1: weight needs unit t, got "kg"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Cleanup(func() { user = nil })
+	before := Digest()
+
+	path := filepath.Join(t.TempDir(), "icons.csv")
+	if err := os.WriteFile(path, []byte("code,kind,unit,description\n901,weight,t,Maximum weight\n76,closure,,Overridden\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if icon, ok := Lookup("901"); !ok || icon.Kind != KindWeight || icon.Unit != "t" {
+		t.Errorf("Lookup(901) = %+v, %v", icon, ok)
+	}
+	if icon, _ := Lookup("76"); icon.Kind != KindClosure {
+		t.Errorf("user row did not replace the embedded one: %+v", icon)
+	}
+	if icon, _ := Lookup("57"); icon.Kind != KindRoadWorks {
+		t.Errorf("embedded row lost: %+v", icon)
+	}
+	if c, _ := Catalog(); len(c) != 3 {
+		t.Errorf("Catalog has %d rows, want 3", len(c))
+	}
+	if Digest() == before {
+		t.Error("Digest did not change with the catalogue")
+	}
+
+	if err := os.WriteFile(path, []byte("901,weight,,x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Load(path); err == nil || !strings.Contains(err.Error(), "weight needs unit t") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestKindFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Kelio remontas", KindRoadWorks},
+		{"Eismas uždarytas", KindClosure},
+		{"UŽDARYTAS EISMAS", KindClosure},
+		{"Masės ribojimas kelio remonto metu", KindWeight},
+		{"Ašies apkrovos ribojimas", KindAxleLoad},
+		{"Aukščio ribojimas", KindHeight},
+		{"Pločio ribojimas", KindWidth},
+		{"Transporto priemonių ilgio ribojimas", KindLength},
+		{"Greičio ribojimas", KindSpeedLimit},
+		{"Kelio rekonstrukcija", KindRoadWorks},
+		{"Sunkiasvorių transporto priemonių eismas draudžiamas", KindHeavyVehicles},
+		{"Eismo ribojimas darbo dienomis", ""},
+		{"Eismo įvykis", ""},
+	}
+	for _, tt := range tests {
+		if got := KindFromName(tt.name); got != tt.want {
+			t.Errorf("KindFromName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	icon := func(kind string) Classification { return Classification{Kind: kind, Source: SourceIcon} }
+	feature := func(kind string) Classification { return Classification{Kind: kind, Source: SourceFeature} }
+	tests := []struct {
+		name        string
+		featureIcon string
+		featureName string
+		codes       []string
+		want        []Classification
+	}{
+		{"catalogue codes", "", "Eismo įvykis", []string{"76", "57"}, []Classification{icon(KindSpeedLimit), icon(KindRoadWorks)}},
+		{"single unknown code by name", "", "Masės ribojimas", []string{"76", "901", "901"},
+			[]Classification{icon(KindSpeedLimit), feature(KindWeight), feature(KindWeight)}},
+		{"feature icon before name", "57", "Masės ribojimas", []string{"901"}, []Classification{feature(KindRoadWorks)}},
+
+		// A weight and a height limit in one feature cannot both be weight
+		{"several unknown codes", "", "Masės ribojimas", []string{"901", "902", "76"}, []Classification{{}, {}, icon(KindSpeedLimit)}},
+
+		// The speed limit the name announces is already catalogued
+		{"kind already assigned", "", "Greičio ribojimas", []string{"76", "901"}, []Classification{icon(KindSpeedLimit), {}}},
+		{"name tells nothing", "", "Eismo įvykis", []string{"901"}, []Classification{{}}},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.featureIcon, tt.featureName, tt.codes); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Classify = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	for kind, want := range map[string]string{
+		KindClosure: CategoryClosures, KindSpeedLimit: CategorySpeed, KindAxleLoad: CategoryWeight,
+		KindWidth: CategoryDimensions, KindRoadWorks: CategoryRoadWorks, "": CategoryOther,
+	} {
+		if got := Category(kind); got != want {
+			t.Errorf("Category(%q) = %q, want %q", kind, got, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Package logging configures log/slog for the command line tool and
+// classifies errors for log-based alerting.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger writing records of at least level to w in the
+// given format
+func New(w io.Writer, format string, level slog.Leveler) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+	switch strings.ToLower(format) {
+	case "", FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q, use text or json", format)
+}
+
+// Err returns an "error" attribute grouping the error message and its
+// Class, e.g. error.message="..." error.class=network
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+	return slog.Group("error", slog.String("message", err.Error()), slog.String("class", Class(err)))
+}
+
+// Error classes
+const (
+	ClassCanceled   = "canceled"
+	ClassTimeout    = "timeout"
+	ClassNetwork    = "network"
+	ClassHTTPStatus = "http_status"
+	ClassUpstream   = "upstream"
+	ClassParse      = "parse"
+	ClassUnchanged  = "unchanged"
+	ClassGuard      = "guard"
+	ClassSuspicious = "suspicious_output"
+	ClassIO         = "io"
+	ClassOther      = "other"
+)
+
+// Class names the kind of failure behind err, so that alerts can tell an
+// upstream outage from a rejected dataset or a local problem
+func Class(err error) string {
+	var (
+		netErr    net.Error
+		statusErr *data.StatusError
+		arcgisErr *data.ArcGISError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		pathErr   *fs.PathError
+	)
+	switch {
+	case errors.Is(err, data.ErrUnchanged):
+		return ClassUnchanged
+	case errors.Is(err, guard.ErrRejected):
+		return ClassGuard
+	case errors.Is(err, converter.ErrSuspiciousOutput):
+		return ClassSuspicious
+	case errors.Is(err, context.Canceled):
+		return ClassCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ClassTimeout
+	case errors.As(err, &pathErr):
+		// Checked before net.Error, which syscall.Errno also satisfies
+		return ClassIO
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return ClassTimeout
+		}
+		return ClassNetwork
+	case errors.As(err, &statusErr):
+		return ClassHTTPStatus
+	case errors.As(err, &arcgisErr):
+		return ClassUpstream
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return ClassParse
+	}
+	return ClassOther
+}
@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+)
+
+func TestClass(t *testing.T) {
+	_, openErr := os.Open(t.TempDir() + "/missing.json")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"unchanged", data.ErrUnchanged, ClassUnchanged},
+		{"guard", fmt.Errorf("%w: too few features", guard.ErrRejected), ClassGuard},
+		{"suspicious output", fmt.Errorf("%w x.gpx", converter.ErrSuspiciousOutput), ClassSuspicious},
+		{"canceled", fmt.Errorf("failed to fetch: %w", context.Canceled), ClassCanceled},
+		{"deadline", context.DeadlineExceeded, ClassTimeout},
+		{"network", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, ClassNetwork},
+		{"status", fmt.Errorf("failed to fetch: %w", &data.StatusError{StatusCode: 503, Status: "503 Service Unavailable"}), ClassHTTPStatus},
+		{"arcgis", &data.ArcGISError{Code: 400, Message: "Invalid query"}, ClassUpstream},
+		{"syntax", &json.SyntaxError{}, ClassParse},
+		{"truncated", fmt.Errorf("failed to decode: %w", io.ErrUnexpectedEOF), ClassParse},
+		{"file", fmt.Errorf("failed to read: %w", openErr), ClassIO},
+		{"other", errors.New("boom"), ClassOther},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Class(tc.err); got != tc.want {
+				t.Errorf("Expected class %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestNewJSON(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelInfo)
+	if err != nil {
+		t.Fatalf("Failed to create logger: %v", err)
+	}
+
+	logger.Debug("Hidden")
+	logger.Error("Failed to download", "source", "restrictions", Err(&data.StatusError{StatusCode: 502, Status: "502 Bad Gateway"}))
+
+	var record struct {
+		Level  string `json:"level"`
+		Msg    string `json:"msg"`
+		Source string `json:"source"`
+		Error  struct {
+			Message string `json:"message"`
+			Class   string `json:"class"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a single JSON record, got %q: %v", buf.String(), err)
+	}
+	if record.Level != "ERROR" || record.Msg != "Failed to download" || record.Source != "restrictions" {
+		t.Errorf("Unexpected record: %+v", record)
+	}
+	if record.Error.Class != ClassHTTPStatus || record.Error.Message != "unexpected response status: 502 Bad Gateway" {
+		t.Errorf("Unexpected error attribute: %+v", record.Error)
+	}
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	if _, err := New(io.Discard, "xml", slog.LevelInfo); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
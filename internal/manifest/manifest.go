@@ -0,0 +1,210 @@
+// Package manifest writes a machine-readable report of the files generated
+// by a run, so that consumers can verify them without parsing GPX.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// Filename is the name of the manifest in the output directory
+const Filename = "manifest.json"
+
+// Source statuses
+const (
+	StatusWritten   = "written"
+	StatusUnchanged = "unchanged"
+	StatusFailed    = "failed"
+)
+
+// Warning codes
+const (
+	WarningSkippedCoordinates = "skipped_coordinates"
+	WarningMissingIcons       = "missing_icons"
+	WarningUnknownIcons       = "unknown_icons"
+)
+
+// Manifest describes the generated files of an output directory
+type Manifest struct {
+	Version   string    `json:"version"`
+	Commit    string    `json:"commit,omitempty"`
+	Generated time.Time `json:"generated"`
+	Sources   []Source  `json:"sources"`
+	Files     []File    `json:"files"`
+}
+
+// Source describes one dataset. For an unchanged or failed source the
+// counts and fetch time are those of the run that last wrote its files.
+type Source struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+
+	// URL is the upstream endpoint; Inputs lists the files of a conversion
+	URL    string   `json:"url,omitempty"`
+	Inputs []string `json:"inputs,omitempty"`
+
+	// Fetched is when the written data was downloaded or converted
+	Fetched *time.Time `json:"fetched,omitempty"`
+
+	// Features counts upstream features, Tracks and Points what was written
+	// after filters
+	Features int `json:"features"`
+	Tracks   int `json:"tracks"`
+	Points   int `json:"points"`
+
+	Warnings []Warning `json:"warnings,omitempty"`
+}
+
+// Warning reports data that was dropped or could not be fully converted
+type Warning struct {
+	Code    string `json:"code"`
+	Count   int    `json:"count"`
+	Message string `json:"message"`
+
+	// Codes lists the icon codes of an unknown_icons warning
+	Codes []string `json:"codes,omitempty"`
+}
+
+// File is a generated file
+type File struct {
+	// Path is relative to the manifest, with forward slashes
+	Path   string `json:"path"`
+	Source string `json:"source"`
+	Format string `json:"format"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	// Split marks a file of a split output, e.g. per road. Only files
+	// marked so are removed when their group disappears.
+	Split bool `json:"split,omitempty"`
+}
+
+// Warnings returns the warnings for the counts of a conversion
+func Warnings(stats converter.Stats) []Warning {
+	var warnings []Warning
+	if stats.SkippedCoordinates > 0 {
+		warnings = append(warnings, Warning{
+			Code:    WarningSkippedCoordinates,
+			Count:   stats.SkippedCoordinates,
+			Message: "coordinates with fewer than two values were skipped",
+		})
+	}
+	if stats.MissingIcons > 0 {
+		warnings = append(warnings, Warning{
+			Code:    WarningMissingIcons,
+			Count:   stats.MissingIcons,
+			Message: "restrictions without an icon code",
+		})
+	}
+	if stats.UnknownIcons > 0 {
+		warnings = append(warnings, Warning{
+			Code:    WarningUnknownIcons,
+			Count:   stats.UnknownIcons,
+			Message: "restrictions with an icon code missing from the icon catalogue, classified by name",
+			Codes:   stats.UnknownIconCodes,
+		})
+	}
+	return warnings
+}
+
+// Load reads the manifest at path. A missing file yields an empty manifest.
+func Load(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Manifest{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Source returns the entry of the named source
+func (m *Manifest) Source(name string) (Source, bool) {
+	for _, src := range m.Sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+// AddFile hashes the file at path and lists it under source. Paths are
+// stored relative to dir, the directory of the manifest.
+func (m *Manifest) AddFile(dir, path, source, format string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %w", path, err)
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		rel = path
+	}
+	m.Files = append(m.Files, File{
+		Path:   filepath.ToSlash(rel),
+		Source: source,
+		Format: format,
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	})
+	return nil
+}
+
+// AddSplitFile is AddFile for a file of a split output
+func (m *Manifest) AddSplitFile(dir, path, source, format string) error {
+	if err := m.AddFile(dir, path, source, format); err != nil {
+		return err
+	}
+	m.Files[len(m.Files)-1].Split = true
+	return nil
+}
+
+// SplitFiles returns the paths of the split files listed under source,
+// joined to dir, the directory of the manifest
+func (m *Manifest) SplitFiles(dir, source string) []string {
+	var paths []string
+	for _, f := range m.Files {
+		if f.Split && f.Source == source {
+			paths = append(paths, filepath.Join(dir, filepath.FromSlash(f.Path)))
+		}
+	}
+	return paths
+}
+
+// Save writes the manifest to path, replacing it atomically
+func (m *Manifest) Save(path string) error {
+	out, err := converter.CreateAtomic(path, converter.OutputOptions{Force: true})
+	if err != nil {
+		return fmt.Errorf("failed to create manifest: %w", err)
+	}
+	defer out.Abort()
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return err
+	}
+	return out.Commit(len(m.Files))
+}
@@ -0,0 +1,120 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func TestWarnings(t *testing.T) {
+	if warnings := Warnings(converter.Stats{Features: 10, Tracks: 10}); len(warnings) != 0 {
+		t.Errorf("Expected no warnings, got %+v", warnings)
+	}
+
+	warnings := Warnings(converter.Stats{SkippedCoordinates: 3, MissingIcons: 1, UnknownIcons: 4, UnknownIconCodes: []string{"57", "99"}})
+	if len(warnings) != 3 {
+		t.Fatalf("Expected 3 warnings, got %+v", warnings)
+	}
+	if warnings[0].Code != WarningSkippedCoordinates || warnings[0].Count != 3 {
+		t.Errorf("Unexpected warning: %+v", warnings[0])
+	}
+	if warnings[1].Code != WarningMissingIcons || warnings[1].Count != 1 {
+		t.Errorf("Unexpected warning: %+v", warnings[1])
+	}
+	if w := warnings[2]; w.Code != WarningUnknownIcons || w.Count != 4 || !reflect.DeepEqual(w.Codes, []string{"57", "99"}) {
+		t.Errorf("Unexpected warning: %+v", w)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	gpxPath := filepath.Join(dir, "gpx", "lt-road-restrictions.gpx")
+	if err := os.MkdirAll(filepath.Dir(gpxPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(gpxPath, []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Manifest{
+		Version: "v1.2.3",
+		Sources: []Source{{Name: "restrictions", Status: StatusWritten, Features: 2, Tracks: 3, Points: 40}},
+	}
+	if err := m.AddFile(dir, gpxPath, "restrictions", "gpx"); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	want := File{
+		Path:   "gpx/lt-road-restrictions.gpx",
+		Source: "restrictions",
+		Format: "gpx",
+		Size:   5,
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}
+	if !reflect.DeepEqual(m.Files, []File{want}) {
+		t.Errorf("Expected %+v, got %+v", want, m.Files)
+	}
+
+	path := filepath.Join(dir, Filename)
+	if err := m.Save(path); err != nil {
+		t.Fatalf("Failed to save: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, m) {
+		t.Errorf("Expected %+v, got %+v", m, loaded)
+	}
+	if src, ok := loaded.Source("restrictions"); !ok || src.Tracks != 3 {
+		t.Errorf("Expected the restrictions source, got %+v", src)
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	m, err := Load(filepath.Join(t.TempDir(), Filename))
+	if err != nil {
+		t.Fatalf("Expected no error for a missing manifest, got %v", err)
+	}
+	if len(m.Sources) != 0 || len(m.Files) != 0 {
+		t.Errorf("Expected an empty manifest, got %+v", m)
+	}
+}
+
+func TestSplitFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"lt-speed-control.gpx", "lt-speed-control-A1.gpx", "lt-road-restrictions-A1.gpx"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := &Manifest{}
+	if err := m.AddFile(dir, filepath.Join(dir, "lt-speed-control.gpx"), "speed-control", "gpx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddSplitFile(dir, filepath.Join(dir, "lt-speed-control-A1.gpx"), "speed-control", "gpx"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddSplitFile(dir, filepath.Join(dir, "lt-road-restrictions-A1.gpx"), "restrictions", "gpx"); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, Filename)
+	if err := m.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "lt-speed-control-A1.gpx")}
+	if got := loaded.SplitFiles(dir, "speed-control"); !reflect.DeepEqual(got, want) {
+		t.Errorf("SplitFiles = %v, want %v", got, want)
+	}
+	if got := loaded.SplitFiles(dir, "other"); got != nil {
+		t.Errorf("Expected no split files of another source, got %v", got)
+	}
+}
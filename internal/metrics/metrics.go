@@ -0,0 +1,186 @@
+// Package metrics exposes Prometheus metrics about upstream requests and
+// refreshes, so that upstream reliability can be graphed over time.
+package metrics
+
+import (
+	"errors"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Refresh results besides the error classes of the logging package
+const (
+	ResultSuccess   = "success"
+	ResultUnchanged = "unchanged"
+)
+
+var (
+	latencyBuckets  = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	durationBuckets = []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+	pageBuckets     = []float64{1, 2, 5, 10, 20, 50, 100}
+)
+
+// Metrics collects the metrics of the watch and serve commands. All methods
+// are no-ops on a nil *Metrics, so instrumentation can be left in place
+// when metrics are disabled.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	upstreamDuration   *prometheus.HistogramVec
+	featuresFetched    *prometheus.CounterVec
+	features           *prometheus.GaugeVec
+	arcgisPages        *prometheus.HistogramVec
+	conversionDuration *prometheus.HistogramVec
+	refreshes          *prometheus.CounterVec
+	lastSuccess        *prometheus.GaugeVec
+	guardRejections    *prometheus.CounterVec
+}
+
+// New creates and registers all metrics in a registry of their own
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		upstreamDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lt_road_info_upstream_request_duration_seconds",
+			Help:    "Time until the response headers of an upstream request arrived, by endpoint and status code (\"error\" for network errors).",
+			Buckets: latencyBuckets,
+		}, []string{"endpoint", "status"}),
+		featuresFetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lt_road_info_features_fetched_total",
+			Help: "Upstream features of completely fetched datasets, by source.",
+		}, []string{"source"}),
+		features: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lt_road_info_features",
+			Help: "Upstream features in the last completely fetched dataset, by source.",
+		}, []string{"source"}),
+		arcgisPages: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lt_road_info_arcgis_pages",
+			Help:    "ArcGIS feature pages fetched per run, by source.",
+			Buckets: pageBuckets,
+		}, []string{"source"}),
+		conversionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "lt_road_info_conversion_duration_seconds",
+			Help:    "Time to fetch and convert a complete dataset, by source.",
+			Buckets: durationBuckets,
+		}, []string{"source"}),
+		refreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lt_road_info_refreshes_total",
+			Help: "Refreshes by source and result: success, unchanged or the error class.",
+		}, []string{"source", "result"}),
+		lastSuccess: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "lt_road_info_last_success_timestamp_seconds",
+			Help: "Unix time of the last successful refresh, including unchanged ones, by source.",
+		}, []string{"source"}),
+		guardRejections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "lt_road_info_guard_rejections_total",
+			Help: "Datasets rejected by the sanity guards, by source.",
+		}, []string{"source"}),
+	}
+	m.registry.MustRegister(m.upstreamDuration, m.featuresFetched, m.features, m.arcgisPages,
+		m.conversionDuration, m.refreshes, m.lastSuccess, m.guardRejections)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition formats
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return promhttp.HandlerFor(prometheus.NewRegistry(), promhttp.HandlerOpts{})
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Transport wraps base, http.DefaultTransport if nil, so that every
+// upstream request is recorded. Placed below a data.RetryTransport, it
+// records every attempt.
+func (m *Metrics) Transport(base http.RoundTripper) http.RoundTripper {
+	if m == nil {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, metrics: m}
+}
+
+type transport struct {
+	base    http.RoundTripper
+	metrics *Metrics
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	// The query string is left out to keep the number of series bounded
+	t.metrics.upstreamDuration.WithLabelValues(req.URL.Host+req.URL.Path, status).Observe(time.Since(start).Seconds())
+	return resp, err
+}
+
+// Tracks instruments a stream of tracks fetched from source through
+// client, whose converter counts into stats. Once the stream is consumed
+// without error, it records the number of upstream features the stream
+// added to stats, which is not the number of tracks: a feature may give
+// several tracks or none. It also records the ArcGIS pages fetched by
+// client and the duration.
+func (m *Metrics) Tracks(source string, client *data.Client, stats *converter.Stats, tracks iter.Seq2[converter.Track, error]) iter.Seq2[converter.Track, error] {
+	if m == nil {
+		return tracks
+	}
+	return func(yield func(converter.Track, error) bool) {
+		start := time.Now()
+		before := stats.Features
+		for track, err := range tracks {
+			if err != nil {
+				yield(track, err)
+				return
+			}
+			if !yield(track, nil) {
+				return
+			}
+		}
+
+		features := stats.Features - before
+		m.conversionDuration.WithLabelValues(source).Observe(time.Since(start).Seconds())
+		m.featuresFetched.WithLabelValues(source).Add(float64(features))
+		m.features.WithLabelValues(source).Set(float64(features))
+		if pages := client.Pages(); pages > 0 {
+			m.arcgisPages.WithLabelValues(source).Observe(float64(pages))
+		}
+	}
+}
+
+// Refreshed records the outcome of a refresh of source
+func (m *Metrics) Refreshed(source string, unchanged bool, err error) {
+	if m == nil {
+		return
+	}
+	result := ResultSuccess
+	switch {
+	case err != nil:
+		result = logging.Class(err)
+	case unchanged:
+		result = ResultUnchanged
+	}
+	m.refreshes.WithLabelValues(source, result).Inc()
+
+	if err == nil {
+		m.lastSuccess.WithLabelValues(source).SetToCurrentTime()
+	}
+	if errors.Is(err, guard.ErrRejected) {
+		m.guardRejections.WithLabelValues(source).Inc()
+	}
+}
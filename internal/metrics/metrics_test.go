@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"fmt"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	return rec.Body.String()
+}
+
+func expectLines(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, body)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	m := New()
+	client := &http.Client{Transport: m.Transport(nil)}
+	for _, path := range []string{"/up?f=json", "/up?f=pjson", "/down"} {
+		resp, err := client.Get(upstream.URL + path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	host := strings.TrimPrefix(upstream.URL, "http://")
+	expectLines(t, scrape(t, m),
+		fmt.Sprintf(`lt_road_info_upstream_request_duration_seconds_count{endpoint="%s/up",status="200"} 2`, host),
+		fmt.Sprintf(`lt_road_info_upstream_request_duration_seconds_count{endpoint="%s/down",status="503"} 1`, host),
+	)
+}
+
+func TestTracksAndRefreshed(t *testing.T) {
+	m := New()
+	line := data.EALLines{Paths: [][][]float64{{{532186, 6190040}, {532218, 6190080}}}}
+	features := []data.EALFeature{
+		// One feature with three restrictions, and one without coordinates
+		{ID: "MJ:1", Restrictions: []data.EALRestriction{{ID: "TR:1", Lines: line}, {ID: "TR:2", Lines: line}, {ID: "TR:3", Lines: line}}},
+		{ID: "MJ:2", Restrictions: []data.EALRestriction{{ID: "TR:4"}}},
+	}
+	fetch := func(stats *converter.Stats, err error) iter.Seq2[converter.Track, error] {
+		return converter.EALTracksWithStats(func(yield func(data.EALFeature, error) bool) {
+			for _, feature := range features {
+				if !yield(feature, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(data.EALFeature{}, err)
+			}
+		}, stats)
+	}
+	client := data.NewClient(nil)
+
+	var stats converter.Stats
+	tracks := 0
+	for range m.Tracks("restrictions", client, &stats, fetch(&stats, nil)) {
+		tracks++
+	}
+	if tracks != 3 {
+		t.Fatalf("Expected 3 tracks, got %d", tracks)
+	}
+	m.Refreshed("restrictions", false, nil)
+	for range m.Tracks("restrictions", client, &stats, fetch(&stats, data.ErrUnchanged)) {
+	}
+	m.Refreshed("restrictions", true, nil)
+	m.Refreshed("speed-control", false, fmt.Errorf("%w: too few features", guard.ErrRejected))
+
+	body := scrape(t, m)
+	expectLines(t, body,
+		`lt_road_info_features_fetched_total{source="restrictions"} 2`,
+		`lt_road_info_features{source="restrictions"} 2`,
+		`lt_road_info_conversion_duration_seconds_count{source="restrictions"} 1`,
+		`lt_road_info_refreshes_total{result="success",source="restrictions"} 1`,
+		`lt_road_info_refreshes_total{result="unchanged",source="restrictions"} 1`,
+		`lt_road_info_refreshes_total{result="guard",source="speed-control"} 1`,
+		`lt_road_info_guard_rejections_total{source="speed-control"} 1`,
+	)
+	if strings.Contains(body, "lt_road_info_arcgis_pages_count") {
+		t.Error("Expected no ArcGIS pages for a client that fetched none")
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	base := http.DefaultTransport
+	if m.Transport(base) != base {
+		t.Error("Expected the base transport to be returned unchanged")
+	}
+	m.Refreshed("restrictions", false, nil)
+}
@@ -0,0 +1,269 @@
+// Package notify posts change events to webhooks using templated payloads.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/watch"
+	"github.com/dimchansky/lt-road-info/internal/webhook"
+)
+
+const (
+	// SignatureHeader carries the hex HMAC-SHA256 of the request body,
+	// prefixed with "sha256=", when a target has a secret
+	SignatureHeader = "X-Signature-256"
+
+	// EventHeader carries the event type
+	EventHeader = "X-LT-Road-Info-Event"
+
+	// DefaultMaxAttempts is the number of delivery attempts per event
+	DefaultMaxAttempts = 3
+
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = time.Minute
+)
+
+// Event is a single track that appeared in or disappeared from a source
+type Event struct {
+	// Type is "<source>.added" or "<source>.removed", e.g. "restrictions.added"
+	Type       string         `json:"type"`
+	Source     string         `json:"source"`
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Properties map[string]any `json:"properties,omitempty"`
+	Time       time.Time      `json:"time"`
+}
+
+// Events splits a change into one event per added or removed track
+func Events(change watch.Change) []Event {
+	events := make([]Event, 0, len(change.Added)+len(change.Removed))
+	for _, item := range change.Added {
+		events = append(events, newEvent(change, "added", item))
+	}
+	for _, item := range change.Removed {
+		events = append(events, newEvent(change, "removed", item))
+	}
+	return events
+}
+
+func newEvent(change watch.Change, kind string, item watch.Item) Event {
+	return Event{
+		Type:       change.Source + "." + kind,
+		Source:     change.Source,
+		ID:         item.ID,
+		Name:       item.Name,
+		Properties: item.Properties,
+		Time:       change.Time,
+	}
+}
+
+// Message returns a one-line human description of the event
+func (e Event) Message() string {
+	switch e.Type {
+	case "restrictions.added":
+		return "New road restriction: " + e.Name
+	case "restrictions.removed":
+		return "Road restriction lifted: " + e.Name
+	case "speed-control.added":
+		return "New average speed control section: " + e.Name
+	case "speed-control.removed":
+		return "Average speed control section removed: " + e.Name
+	}
+	return e.Type + ": " + e.Name
+}
+
+// Presets are the built-in payload templates. Templates are executed with
+// the Event as dot and may use .Message, .Params (the target's Params) and
+// the json function, which encodes a value as JSON.
+var Presets = map[string]string{
+	"json":     `{{json .Event}}`,
+	"slack":    `{"text": {{json .Message}}}`,
+	"discord":  `{"content": {{json .Message}}}`,
+	"telegram": `{"chat_id": {{json (index .Params "chat_id")}}, "text": {{json .Message}}}`,
+	"matrix":   `{"text": {{json .Message}}}`,
+}
+
+// Target is a webhook receiving events
+type Target struct {
+	URL string
+
+	// Format names one of the Presets; it is ignored when Template is set
+	// and defaults to "json"
+	Format string
+
+	// Template is a custom payload template, see Presets
+	Template string
+
+	// Params are available to templates as .Params, e.g. a Telegram chat_id
+	Params map[string]string
+
+	// Secret signs every request in the SignatureHeader when set
+	Secret string
+
+	// Events limits delivery to the given event types; empty means all
+	Events []string
+
+	// MaxAttempts defaults to DefaultMaxAttempts
+	MaxAttempts int
+}
+
+// target is a Target with its template parsed
+type target struct {
+	Target
+	tmpl *template.Template
+}
+
+// Notifier delivers events to its targets. It implements watch.Hook.
+type Notifier struct {
+	targets    []target
+	httpClient *http.Client
+	retryDelay time.Duration
+}
+
+// payload is the dot of a template
+type payload struct {
+	Event
+	Message string
+	Params  map[string]string
+}
+
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// NewNotifier parses the targets' templates. A nil httpClient uses
+// http.DefaultClient.
+func NewNotifier(targets []Target, httpClient *http.Client) (*Notifier, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	n := &Notifier{httpClient: httpClient, retryDelay: defaultRetryDelay}
+
+	for _, t := range targets {
+		text := t.Template
+		if text == "" {
+			format := t.Format
+			if format == "" {
+				format = "json"
+			}
+			var ok bool
+			if text, ok = Presets[format]; !ok {
+				return nil, fmt.Errorf("unknown notification format %q", format)
+			}
+		}
+		// The template name ends up in parse and execution errors
+		tmpl, err := template.New(webhook.Redact(t.URL)).Funcs(funcs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template for %s: %w", webhook.Redact(t.URL), err)
+		}
+		if t.MaxAttempts <= 0 {
+			t.MaxAttempts = DefaultMaxAttempts
+		}
+		n.targets = append(n.targets, target{Target: t, tmpl: tmpl})
+	}
+	return n, nil
+}
+
+// Run delivers the events of change, implementing watch.Hook
+func (n *Notifier) Run(ctx context.Context, change watch.Change) error {
+	return n.Notify(ctx, Events(change))
+}
+
+// Notify delivers every event to every target that accepts it. Delivery
+// to the remaining targets continues after a failure; all failures are
+// returned together.
+func (n *Notifier) Notify(ctx context.Context, events []Event) error {
+	var errs []error
+	for _, t := range n.targets {
+		for _, e := range events {
+			if len(t.Events) > 0 && !slices.Contains(t.Events, e.Type) {
+				continue
+			}
+			if err := n.deliver(ctx, t, e); err != nil {
+				errs = append(errs, fmt.Errorf("failed to notify %s about %s %s: %w", webhook.Redact(t.URL), e.Type, e.ID, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver renders the event and posts it, retrying network errors, 429 and
+// 5xx responses with exponential backoff
+func (n *Notifier) deliver(ctx context.Context, t target, e Event) error {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, payload{Event: e, Message: e.Message(), Params: t.Params}); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+	body := buf.Bytes()
+	if !json.Valid(body) {
+		// The body is left out, as it may hold secrets from Params
+		return errors.New("template produced invalid JSON")
+	}
+
+	delay := n.retryDelay
+	for attempt := 1; ; attempt++ {
+		retry, wait, err := n.post(ctx, t, e, body)
+		if err == nil || !retry || attempt >= t.MaxAttempts {
+			return err
+		}
+
+		if wait <= 0 {
+			wait = delay
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(min(wait, maxRetryDelay)):
+		}
+		delay *= 2
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying
+// and how long the server asked to wait
+func (n *Notifier) post(ctx context.Context, t target, e Event, body []byte) (retry bool, wait time.Duration, err error) {
+	header := http.Header{}
+	header.Set(EventHeader, e.Type)
+	if t.Secret != "" {
+		header.Set(SignatureHeader, Sign(t.Secret, body))
+	}
+
+	resp, err := webhook.Post(ctx, n.httpClient, t.URL, header, body)
+	if err != nil {
+		return ctx.Err() == nil, 0, err
+	}
+
+	switch {
+	case resp.OK():
+		return false, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+		return true, wait, fmt.Errorf("unexpected response status: %s", resp.Status)
+	default:
+		return false, 0, fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+}
+
+// Sign returns the SignatureHeader value for body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
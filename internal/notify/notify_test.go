@@ -0,0 +1,212 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/watch"
+)
+
+// receiver records the requests posted to it and answers with the queued
+// status codes, then 200
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bodies = append(r.bodies, string(body))
+	r.headers = append(r.headers, req.Header.Clone())
+	if len(r.statuses) > 0 {
+		w.WriteHeader(r.statuses[0])
+		r.statuses = r.statuses[1:]
+	}
+}
+
+var change = watch.Change{
+	Source: "restrictions",
+	Time:   time.Date(2025, 6, 1, 8, 0, 0, 0, time.UTC),
+	Added: []watch.Item{{
+		ID:         "EAL-123",
+		Name:       `Road Works - Restriction "50" (50)`,
+		Properties: map[string]any{"icon": "50"},
+	}},
+	Removed: []watch.Item{{ID: "EAL-7", Name: "Road Closed - Restriction no-entry"}},
+}
+
+func newTestNotifier(t *testing.T, targets ...Target) *Notifier {
+	t.Helper()
+	n, err := NewNotifier(targets, nil)
+	if err != nil {
+		t.Fatalf("NewNotifier failed: %v", err)
+	}
+	n.retryDelay = time.Millisecond
+	return n
+}
+
+func TestEvents(t *testing.T) {
+	events := Events(change)
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(events))
+	}
+	if events[0].Type != "restrictions.added" || events[0].ID != "EAL-123" {
+		t.Errorf("Unexpected first event: %+v", events[0])
+	}
+	if events[1].Type != "restrictions.removed" || !strings.HasPrefix(events[1].Message(), "Road restriction lifted") {
+		t.Errorf("Unexpected second event: %+v (%s)", events[1], events[1].Message())
+	}
+}
+
+func TestPresets(t *testing.T) {
+	tests := []struct {
+		format string
+		params map[string]string
+		field  string
+		want   any
+	}{
+		{"slack", nil, "text", `New road restriction: Road Works - Restriction "50" (50)`},
+		{"discord", nil, "content", `New road restriction: Road Works - Restriction "50" (50)`},
+		{"telegram", map[string]string{"chat_id": "-100123"}, "chat_id", "-100123"},
+		{"matrix", nil, "text", `New road restriction: Road Works - Restriction "50" (50)`},
+		{"json", nil, "id", "EAL-123"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			rcv := &receiver{}
+			srv := httptest.NewServer(rcv)
+			defer srv.Close()
+
+			n := newTestNotifier(t, Target{URL: srv.URL, Format: tt.format, Params: tt.params, Events: []string{"restrictions.added"}})
+			if err := n.Run(context.Background(), change); err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+			if len(rcv.bodies) != 1 {
+				t.Fatalf("Expected only the added event to be delivered, got %d requests", len(rcv.bodies))
+			}
+
+			var body map[string]any
+			if err := json.Unmarshal([]byte(rcv.bodies[0]), &body); err != nil {
+				t.Fatalf("Invalid JSON payload %s: %v", rcv.bodies[0], err)
+			}
+			if body[tt.field] != tt.want {
+				t.Errorf("Expected %s=%v, got %s", tt.field, tt.want, rcv.bodies[0])
+			}
+			if got := rcv.headers[0].Get(EventHeader); got != "restrictions.added" {
+				t.Errorf("Unexpected event header %q", got)
+			}
+		})
+	}
+}
+
+func TestCustomTemplateAndSignature(t *testing.T) {
+	rcv := &receiver{}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	n := newTestNotifier(t, Target{
+		URL:      srv.URL,
+		Template: `{"event": {{json .Type}}, "restriction": {{json .ID}}, "icon": {{json (index .Properties "icon")}}}`,
+		Secret:   "s3cret",
+	})
+	if err := n.Run(context.Background(), change); err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if len(rcv.bodies) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(rcv.bodies))
+	}
+
+	if want := `{"event": "restrictions.added", "restriction": "EAL-123", "icon": "50"}`; rcv.bodies[0] != want {
+		t.Errorf("Unexpected body %s", rcv.bodies[0])
+	}
+	if got, want := rcv.headers[0].Get(SignatureHeader), Sign("s3cret", []byte(rcv.bodies[0])); got != want {
+		t.Errorf("Expected signature %s, got %s", want, got)
+	}
+	// Known HMAC-SHA256 value to pin the header format
+	if got := Sign("key", []byte("The quick brown fox jumps over the lazy dog")); got != "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8" {
+		t.Errorf("Unexpected signature %s", got)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	rcv := &receiver{statuses: []int{http.StatusBadGateway, http.StatusTooManyRequests}}
+	srv := httptest.NewServer(rcv)
+	defer srv.Close()
+
+	n := newTestNotifier(t, Target{URL: srv.URL, Events: []string{"restrictions.added"}})
+	if err := n.Run(context.Background(), change); err != nil {
+		t.Fatalf("Expected delivery after retries, got %v", err)
+	}
+	if len(rcv.bodies) != 3 {
+		t.Errorf("Expected 3 attempts, got %d", len(rcv.bodies))
+	}
+
+	// Client errors are not retried and attempts are bounded
+	rcv = &receiver{statuses: []int{http.StatusBadRequest}}
+	srv2 := httptest.NewServer(rcv)
+	defer srv2.Close()
+	n = newTestNotifier(t, Target{URL: srv2.URL + "/secret-token", Events: []string{"restrictions.added"}})
+	err := n.Run(context.Background(), change)
+	if err == nil || len(rcv.bodies) != 1 {
+		t.Errorf("Expected a single failed attempt, got %d attempts, err %v", len(rcv.bodies), err)
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Error message leaks the webhook path: %v", err)
+	}
+
+	rcv = &receiver{statuses: []int{500, 500, 500, 500}}
+	srv3 := httptest.NewServer(rcv)
+	defer srv3.Close()
+	n = newTestNotifier(t, Target{URL: srv3.URL, MaxAttempts: 2, Events: []string{"restrictions.added"}})
+	if err := n.Run(context.Background(), change); err == nil || len(rcv.bodies) != 2 {
+		t.Errorf("Expected 2 failed attempts, got %d attempts, err %v", len(rcv.bodies), err)
+	}
+}
+
+func TestNewNotifierErrors(t *testing.T) {
+	if _, err := NewNotifier([]Target{{URL: "http://x", Format: "irc"}}, nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+	_, err := NewNotifier([]Target{{URL: "https://hooks.slack.com/services/T000/B000/secret-token", Template: "{{"}}, nil)
+	if err == nil {
+		t.Error("Expected an error for an invalid template")
+	} else if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Template error leaks the webhook path: %v", err)
+	}
+
+	n := newTestNotifier(t, Target{URL: "http://127.0.0.1:1", Template: `{"key": "{{index .Params "key"}}" oops}`, Params: map[string]string{"key": "param-secret"}})
+	if err := n.Notify(context.Background(), Events(change)); err == nil || !strings.Contains(err.Error(), "invalid JSON") {
+		t.Errorf("Expected an invalid JSON error, got %v", err)
+	} else if strings.Contains(err.Error(), "param-secret") {
+		t.Errorf("Invalid JSON error leaks the rendered body: %v", err)
+	}
+}
+
+func TestNotifyFailureHidesURL(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	n := newTestNotifier(t, Target{URL: srv.URL + "/bot123:secret-token/sendMessage?key=secret-query", Format: "telegram", MaxAttempts: 2})
+	err := n.Notify(context.Background(), Events(change))
+	if err == nil {
+		t.Fatal("Expected an error from a closed server")
+	}
+	for _, secret := range []string{"secret-token", "secret-query"} {
+		if strings.Contains(err.Error(), secret) {
+			t.Errorf("Delivery error leaks %s: %v", secret, err)
+		}
+	}
+}
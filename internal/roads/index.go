@@ -0,0 +1,105 @@
+package roads
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// Kinds of indexed tracks
+const (
+	KindRestriction = "restriction"
+	KindSection     = "section"
+)
+
+// Entry is a track on a road
+type Entry struct {
+	Kind     string  `json:"kind"`
+	Name     string  `json:"name"`
+	ID       string  `json:"id,omitempty"`
+	LengthKm float64 `json:"lengthKm"`
+}
+
+// Road lists the restrictions and speed control sections on one road
+type Road struct {
+	Number       string  `json:"number"`
+	Class        string  `json:"class"`
+	Restrictions int     `json:"restrictions"`
+	Sections     int     `json:"sections"`
+	LengthKm     float64 `json:"lengthKm"`
+	Entries      []Entry `json:"entries"`
+}
+
+// Index maps road numbers to the tracks on them
+type Index struct {
+	roads   map[string]*Road
+	unknown int
+}
+
+// NewIndex returns an empty index
+func NewIndex() *Index {
+	return &Index{roads: make(map[string]*Road)}
+}
+
+// Add indexes a track of the given kind under its road. Tracks on an
+// unknown road are only counted.
+func (x *Index) Add(kind string, track converter.Track) {
+	number := Number(track)
+	if number == "" {
+		x.unknown++
+		return
+	}
+	r, ok := x.roads[number]
+	if !ok {
+		r = &Road{Number: number, Class: Class(number)}
+		x.roads[number] = r
+	}
+
+	entry := Entry{Kind: kind, Name: track.Name, ID: trackID(track), LengthKm: track.Length() / 1000}
+	r.Entries = append(r.Entries, entry)
+	r.LengthKm += entry.LengthKm
+	switch kind {
+	case KindRestriction:
+		r.Restrictions++
+	case KindSection:
+		r.Sections++
+	}
+}
+
+// Roads returns the indexed roads sorted by Compare
+func (x *Index) Roads() []*Road {
+	numbers := slices.SortedFunc(maps.Keys(x.roads), Compare)
+	roads := make([]*Road, len(numbers))
+	for i, number := range numbers {
+		roads[i] = x.roads[number]
+	}
+	return roads
+}
+
+// Road returns the entries of one road, false if none was indexed
+func (x *Index) Road(number string) (*Road, bool) {
+	r, ok := x.roads[number]
+	return r, ok
+}
+
+// Unknown returns the number of tracks whose road could not be determined
+func (x *Index) Unknown() int {
+	return x.unknown
+}
+
+// trackID returns the restriction ID or ArcGIS object ID of a track, ""
+// when its properties were lost, as in GPX
+func trackID(track converter.Track) string {
+	if id, ok := track.Properties["id"].(string); ok && id != "" {
+		return id
+	}
+	for name, value := range track.Properties {
+		if strings.EqualFold(name, "objectid") && value != nil {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package roads
+
+import (
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func TestIndex(t *testing.T) {
+	segment := [][]converter.Point{{{Lat: 54.69, Lon: 25.05}, {Lat: 54.70, Lon: 25.05}}}
+	x := NewIndex()
+	x.Add(KindSection, converter.Track{Name: "Speed Control Section 1", Segments: segment, Properties: map[string]any{"objectid": float64(452), "kelionr": "101"}})
+	x.Add(KindRestriction, converter.Track{Name: "Kelio remontas A1", Segments: segment, Properties: map[string]any{"id": "R1"}})
+	x.Add(KindSection, converter.Track{Name: "Speed Control Section 2 - Road A1", Segments: segment})
+	x.Add(KindRestriction, converter.Track{Name: "Kelio remontas", Segments: segment})
+
+	roads := x.Roads()
+	if len(roads) != 2 || roads[0].Number != "A1" || roads[1].Number != "KK101" {
+		t.Fatalf("unexpected roads: %+v", roads)
+	}
+	a1 := roads[0]
+	if a1.Class != ClassMagistral || a1.Restrictions != 1 || a1.Sections != 1 || len(a1.Entries) != 2 {
+		t.Errorf("unexpected A1: %+v", a1)
+	}
+	if a1.Entries[0].ID != "R1" || a1.Entries[1].ID != "" {
+		t.Errorf("unexpected A1 entries: %+v", a1.Entries)
+	}
+	if a1.LengthKm < 2.2 || a1.LengthKm > 2.3 {
+		t.Errorf("A1 length = %.3f km, want about 2.22", a1.LengthKm)
+	}
+	if kk, ok := x.Road("KK101"); !ok || kk.Entries[0].ID != "452" {
+		t.Errorf("unexpected KK101: %+v", kk)
+	}
+	if x.Unknown() != 1 {
+		t.Errorf("Unknown() = %d, want 1", x.Unknown())
+	}
+}
@@ -0,0 +1,138 @@
+// Package roads identifies the road a track lies on and indexes tracks by
+// road number.
+//
+// Lithuanian roads are numbered by class: magistral roads A1 to A19,
+// national roads (krašto keliai, KK) with three digits and regional roads
+// with four. Numbers are normalized to A1, KK101 and 1001 respectively, so
+// "a-1", "A 01" and "1" all name A1.
+package roads
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// Road classes, in the order roads are sorted
+const (
+	ClassMagistral = "magistral"
+	ClassNational  = "national"
+	ClassRegional  = "regional"
+)
+
+// attributes are the upstream attributes holding the road number, compared
+// case-insensitively: kelionr in the live ArcGIS layer, road_number in
+// older exports
+var attributes = []string{"kelionr", "road_number"}
+
+// namePatterns find a road number in a track name: "A1", "KK 101" or
+// "kelio Nr. 2501". Bare numbers are not matched, as names also hold
+// kilometres, speed limits and icon values.
+var namePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)\b(A|KK)[ -]?(\d{1,4})\b`),
+	regexp.MustCompile(`(?i)\bkel(?:io|yje|\.)?\s*Nr\.?\s*((?:A|KK)?[ -]?\d{1,4})\b`),
+}
+
+// Normalize returns the canonical form of a road number, false if s is not
+// one
+func Normalize(s string) (string, bool) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(s)))
+	prefix := strings.TrimRight(s, "0123456789")
+	digits := strings.TrimLeft(s[len(prefix):], "0")
+	n, err := strconv.Atoi(digits)
+	if err != nil || n == 0 {
+		return "", false
+	}
+
+	switch {
+	case (prefix == "A" || prefix == "") && n < 100:
+		return "A" + digits, true
+	case (prefix == "KK" || prefix == "") && n >= 100 && n < 1000:
+		return "KK" + digits, true
+	case prefix == "" && n >= 1000 && n < 10000:
+		return digits, true
+	}
+	return "", false
+}
+
+// Parse splits a comma-separated list of road numbers such as "A1,A6" and
+// normalizes each
+func Parse(list string) ([]string, error) {
+	var numbers []string
+	for _, s := range strings.Split(list, ",") {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		number, ok := Normalize(s)
+		if !ok {
+			return nil, fmt.Errorf("invalid road number %q", strings.TrimSpace(s))
+		}
+		numbers = append(numbers, number)
+	}
+	return numbers, nil
+}
+
+// Class returns the class of a normalized road number
+func Class(number string) string {
+	switch {
+	case strings.HasPrefix(number, "A"):
+		return ClassMagistral
+	case strings.HasPrefix(number, "KK"):
+		return ClassNational
+	}
+	return ClassRegional
+}
+
+// Compare orders normalized road numbers by class and then numerically,
+// so A2 sorts before A10 and all magistral roads before KK101
+func Compare(a, b string) int {
+	return cmp.Or(
+		cmp.Compare(classRank(a), classRank(b)),
+		cmp.Compare(len(a), len(b)),
+		cmp.Compare(a, b),
+	)
+}
+
+func classRank(number string) int {
+	switch Class(number) {
+	case ClassMagistral:
+		return 0
+	case ClassNational:
+		return 1
+	}
+	return 2
+}
+
+// FromName returns the road number mentioned in a feature or track name,
+// "" if there is none
+func FromName(name string) string {
+	for _, re := range namePatterns {
+		for _, m := range re.FindAllStringSubmatch(name, -1) {
+			if number, ok := Normalize(strings.Join(m[1:], "")); ok {
+				return number
+			}
+		}
+	}
+	return ""
+}
+
+// Number returns the road a track lies on: from the road number attribute
+// of speed control sections, else from the track name. It returns "" when
+// the road is unknown.
+func Number(track converter.Track) string {
+	for key, value := range track.Properties {
+		for _, attr := range attributes {
+			if !strings.EqualFold(key, attr) {
+				continue
+			}
+			if number, ok := Normalize(fmt.Sprint(value)); ok {
+				return number
+			}
+		}
+	}
+	return FromName(track.Name)
+}
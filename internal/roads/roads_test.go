@@ -0,0 +1,96 @@
+package roads
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"A1", "A1", true},
+		{"a-1", "A1", true},
+		{"A 01", "A1", true},
+		{"1", "A1", true},
+		{"A19", "A19", true},
+		{"101", "KK101", true},
+		{"kk 101", "KK101", true},
+		{"2501", "2501", true},
+		{"0", "", false},
+		{"A101", "", false},
+		{"KK1", "", false},
+		{"12345", "", false},
+		{"Kelio numeris", "", false},
+		{"1.5", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := Normalize(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Normalize(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	got, err := Parse("A1, a6,,101")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A1", "A6", "KK101"}; !slices.Equal(got, want) {
+		t.Errorf("Parse = %v, want %v", got, want)
+	}
+	if _, err := Parse("A1,Vilnius"); err == nil || err.Error() != `invalid road number "Vilnius"` {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	numbers := []string{"2501", "KK101", "A10", "A2", "A1"}
+	slices.SortFunc(numbers, Compare)
+	if want := []string{"A1", "A2", "A10", "KK101", "2501"}; !slices.Equal(numbers, want) {
+		t.Errorf("sorted = %v, want %v", numbers, want)
+	}
+}
+
+func TestFromName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Speed Control Section 1 - Test Highway A1 (A1) - Speed limit: 90 km/h", "A1"},
+		{"Kelio remontas A-6 ruože 12-15 km - Restriction eismas_1 (50)", "A6"},
+		{"Eismo ribojimas kelyje Nr. 144", "KK144"},
+		{"Kelio Nr. 2501 remontas", "2501"},
+		{"Kelio remontas - Restriction greicio_ribojimas (50)", ""},
+		{"Darbai 2025 m.", ""},
+	}
+	for _, tt := range tests {
+		if got := FromName(tt.name); got != tt.want {
+			t.Errorf("FromName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	tests := []struct {
+		track converter.Track
+		want  string
+	}{
+		{converter.Track{Name: "Speed Control Section 1", Properties: map[string]any{"kelionr": "101"}}, "KK101"},
+		{converter.Track{Name: "Speed Control Section 2", Properties: map[string]any{"road_number": "A1"}}, "A1"},
+		{converter.Track{Name: "Speed Control Section 3", Properties: map[string]any{"KELIONR": float64(5)}}, "A5"},
+		{converter.Track{Name: "Kelio remontas A2"}, "A2"},
+		{converter.Track{Name: "Speed Control Section 4", Properties: map[string]any{"kelionr": "Kelio numeris"}}, ""},
+	}
+	for _, tt := range tests {
+		if got := Number(tt.track); got != tt.want {
+			t.Errorf("Number(%q) = %q, want %q", tt.track.Name, got, tt.want)
+		}
+	}
+}
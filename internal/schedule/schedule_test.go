@@ -0,0 +1,54 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseEvery(t *testing.T) {
+	for _, spec := range []string{"15m", "@every 15m", " @every 900s "} {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", spec, err)
+		}
+		start := time.Date(2025, 6, 1, 10, 0, 0, 0, time.UTC)
+		if next := s.Next(start); !next.Equal(start.Add(15 * time.Minute)) {
+			t.Errorf("Parse(%q).Next = %s", spec, next)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 6, 1, 10, 7, 30, 0, time.UTC), time.Date(2025, 6, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 6 * * *", time.Date(2025, 6, 1, 6, 0, 0, 0, time.UTC), time.Date(2025, 6, 2, 6, 0, 0, 0, time.UTC)},
+		{"30 7,19 * * 1-5", time.Date(2025, 6, 6, 20, 0, 0, 0, time.UTC), time.Date(2025, 6, 9, 7, 30, 0, 0, time.UTC)}, // Friday evening -> Monday
+		{"0 0 * * 7", time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 8, 0, 0, 0, 0, time.UTC)},         // 7 is Sunday
+		{"@monthly", time.Date(2025, 12, 15, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either may match
+		{"0 12 13 * 5", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), time.Date(2025, 6, 6, 12, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("Parse(%q) failed: %v", tt.spec, err)
+		}
+		if got := s.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q after %s: expected %s, got %s", tt.spec, tt.from, tt.want, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 100ms", "@every soon"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Expected an error for %q", spec)
+		}
+	}
+}
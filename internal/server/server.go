@@ -0,0 +1,555 @@
+// Package server serves continuously refreshed road information over HTTP.
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"iter"
+	"log/slog"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/metrics"
+	"github.com/dimchansky/lt-road-info/internal/tiles"
+)
+
+// Source produces the tracks of one dataset
+type Source struct {
+	// Name is used in URLs, e.g. "restrictions" is served as /restrictions.gpx
+	Name string
+
+	// Title is the document name written into the served files
+	Title string
+
+	// Fetch returns a fresh stream of tracks from upstream, counting the
+	// upstream features read in stats
+	Fetch func(stats *converter.Stats) iter.Seq2[converter.Track, error]
+
+	// Feed, if set, is updated with every changed snapshot and served as
+	// /<name>.atom and /<name>.rss
+	Feed *feed.Feed
+}
+
+// Server keeps the latest snapshot of every source in memory and serves it
+// as GPX, GeoJSON and vector tiles
+type Server struct {
+	sources    []Source
+	thresholds guard.Thresholds
+	metrics    *metrics.Metrics
+
+	mu       sync.RWMutex
+	statuses map[string]*status
+	tiles    *tileSet
+}
+
+// tileSet is the vector tile index over the current snapshots, with one
+// layer per source
+type tileSet struct {
+	index   *tiles.Index
+	hash    string
+	changed time.Time
+}
+
+// snapshot is an immutable, pre-rendered version of a dataset
+type snapshot struct {
+	tracks []converter.Track
+
+	// upstream is the number of upstream features the tracks came from
+	upstream int
+	hash     string
+	changed  time.Time
+	gpx      []byte
+	geojson  []byte
+}
+
+// status tracks the refresh history of one source
+type status struct {
+	snapshot    *snapshot
+	lastRefresh time.Time
+	lastAttempt time.Time
+	lastError   string
+}
+
+// New creates a server for the given sources. A refresh whose upstream
+// feature count fails thresholds, compared with the snapshot being served, is rejected and
+// the previous snapshot stays in place. m, if not nil, records the outcome
+// of every refresh and is served as /metrics.
+func New(sources []Source, thresholds guard.Thresholds, m *metrics.Metrics) *Server {
+	statuses := make(map[string]*status, len(sources))
+	for _, src := range sources {
+		statuses[src.Name] = &status{}
+	}
+	return &Server{
+		sources:    sources,
+		thresholds: thresholds,
+		metrics:    m,
+		statuses:   statuses,
+	}
+}
+
+// Run refreshes all sources immediately and then every interval until ctx
+// is done
+func (s *Server) Run(ctx context.Context, interval time.Duration) {
+	s.Refresh()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Refresh()
+		}
+	}
+}
+
+// Refresh fetches all sources concurrently and replaces the snapshots of
+// those that succeeded
+func (s *Server) Refresh() {
+	var wg sync.WaitGroup
+	for _, src := range s.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.refresh(src)
+			if err != nil {
+				slog.Error("Failed to refresh", "source", src.Name, logging.Err(err))
+			}
+			s.metrics.Refreshed(src.Name, false, err)
+		}()
+	}
+	wg.Wait()
+
+	s.updateTiles()
+}
+
+// updateTiles rebuilds the tile index when any snapshot changed
+func (s *Server) updateTiles() {
+	s.mu.RLock()
+	var (
+		layers  []tiles.Layer
+		hashes  []string
+		changed time.Time
+	)
+	for _, src := range s.sources {
+		snap := s.statuses[src.Name].snapshot
+		if snap == nil {
+			hashes = append(hashes, "")
+			continue
+		}
+		layers = append(layers, tiles.Layer{Name: src.Name, Tracks: snap.tracks})
+		hashes = append(hashes, snap.hash)
+		if snap.changed.After(changed) {
+			changed = snap.changed
+		}
+	}
+	current := s.tiles
+	s.mu.RUnlock()
+
+	sum := sha256.Sum256([]byte(strings.Join(hashes, ",")))
+	hash := hex.EncodeToString(sum[:8])
+	if len(layers) == 0 || (current != nil && current.hash == hash) {
+		return
+	}
+
+	next := &tileSet{index: tiles.NewIndex(layers, tiles.MaxZoom), hash: hash, changed: changed}
+
+	s.mu.Lock()
+	s.tiles = next
+	s.mu.Unlock()
+}
+
+func (s *Server) refresh(src Source) (err error) {
+	attempt := time.Now()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		st := s.statuses[src.Name]
+		st.lastAttempt = attempt
+		st.lastError = ""
+		if err != nil {
+			st.lastError = err.Error()
+		}
+	}()
+
+	var (
+		tracks []converter.Track
+		stats  converter.Stats
+	)
+	for track, err := range src.Fetch(&stats) {
+		if err != nil {
+			return err
+		}
+		tracks = append(tracks, track)
+	}
+
+	s.mu.RLock()
+	previous := s.statuses[src.Name].snapshot
+	s.mu.RUnlock()
+
+	var last *guard.Run
+	if previous != nil {
+		last = &guard.Run{UpstreamFeatures: previous.upstream, Time: previous.changed}
+	}
+	if err := s.thresholds.Check(src.Name, stats.Features, last); err != nil {
+		return err
+	}
+
+	next, err := render(src.Title, tracks, attempt)
+	if err != nil {
+		return err
+	}
+	next.upstream = stats.Features
+
+	// The feed has its own lock; updating and saving it here keeps requests
+	// for the other files from waiting on the disk
+	changed := previous == nil || previous.hash != next.hash
+	if changed && src.Feed != nil {
+		src.Feed.Update(tracks, attempt)
+		if err := src.Feed.Save(); err != nil {
+			slog.Error("Failed to save feed", "source", src.Name, logging.Err(err))
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.statuses[src.Name]
+	if changed {
+		st.snapshot = next
+	}
+	st.lastRefresh = attempt
+	return nil
+}
+
+// render pre-renders a snapshot. The hash is taken over the GeoJSON, which
+// unlike the GPX carries no timestamp, so it only changes with the content.
+func render(title string, tracks []converter.Track, changed time.Time) (*snapshot, error) {
+	snap := &snapshot{tracks: tracks, changed: changed}
+
+	var buf bytes.Buffer
+	if err := writeGeoJSON(&buf, title, tracks, nil); err != nil {
+		return nil, err
+	}
+	snap.geojson = buf.Bytes()
+
+	sum := sha256.Sum256(snap.geojson)
+	snap.hash = hex.EncodeToString(sum[:8])
+
+	buf = bytes.Buffer{}
+	if err := writeGPX(&buf, title, tracks, changed, nil); err != nil {
+		return nil, err
+	}
+	snap.gpx = buf.Bytes()
+
+	return snap, nil
+}
+
+// Handler returns the HTTP handler serving /healthz, /<source>.gpx and
+// /<source>.geojson for every source, feeds of sources that have one, and
+// vector tiles of all sources at /tiles/{z}/{x}/{y}.pbf
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.serveHealth)
+	if s.metrics != nil {
+		mux.Handle("GET /metrics", s.metrics.Handler())
+	}
+	mux.HandleFunc("GET /tiles/{z}/{x}/{y}", s.serveTile)
+	mux.HandleFunc("GET /{file}", s.serveDataset)
+	return mux
+}
+
+// sourceHealth is the /healthz report for one source
+type sourceHealth struct {
+	Tracks      int        `json:"tracks"`
+	LastRefresh *time.Time `json:"lastRefresh,omitempty"`
+	LastChange  *time.Time `json:"lastChange,omitempty"`
+	LastAttempt *time.Time `json:"lastAttempt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+func (s *Server) serveHealth(w http.ResponseWriter, r *http.Request) {
+	report := struct {
+		Status  string                  `json:"status"`
+		Sources map[string]sourceHealth `json:"sources"`
+	}{Status: "ok", Sources: make(map[string]sourceHealth)}
+
+	s.mu.RLock()
+	for name, st := range s.statuses {
+		var h sourceHealth
+		if st.snapshot != nil {
+			h.Tracks = len(st.snapshot.tracks)
+			h.LastChange = &st.snapshot.changed
+		} else {
+			report.Status = "unavailable"
+		}
+		if !st.lastRefresh.IsZero() {
+			h.LastRefresh = &st.lastRefresh
+		}
+		if !st.lastAttempt.IsZero() {
+			h.LastAttempt = &st.lastAttempt
+		}
+		h.LastError = st.lastError
+		report.Sources[name] = h
+	}
+	s.mu.RUnlock()
+
+	code := http.StatusOK
+	if report.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
+
+func (s *Server) serveDataset(w http.ResponseWriter, r *http.Request) {
+	file := r.PathValue("file")
+	ext := path.Ext(file)
+	name := strings.TrimSuffix(file, ext)
+
+	var contentType string
+	switch ext {
+	case ".gpx":
+		contentType = "application/gpx+xml"
+	case ".geojson":
+		contentType = "application/geo+json"
+	case ".atom":
+		contentType = "application/atom+xml"
+	case ".rss":
+		contentType = "application/rss+xml"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	src, ok := s.source(name)
+	if !ok || (src.Feed == nil && (ext == ".atom" || ext == ".rss")) {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	snap := s.statuses[name].snapshot
+	s.mu.RUnlock()
+	if snap == nil {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, name+" has not been loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	var bbox *converter.BBox
+	tag := snap.hash + "-" + strings.TrimPrefix(ext, ".")
+	if q := r.URL.Query().Get("bbox"); q != "" {
+		b, err := converter.ParseBBox(q)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		bbox = &b
+		sum := sha256.Sum256([]byte(q))
+		tag += "-" + hex.EncodeToString(sum[:4])
+	}
+
+	gzipped := acceptsGzip(r)
+	if gzipped {
+		tag += "-gzip"
+	}
+	etag := `"` + tag + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", snap.changed.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", "no-cache")
+	h.Add("Vary", "Accept-Encoding")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	h.Set("Content-Type", contentType)
+	var body io.Writer = w
+	if gzipped {
+		h.Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		body = gz
+	}
+
+	var err error
+	switch {
+	case ext == ".atom":
+		err = src.Feed.WriteAtom(body, feedInfo(src, r))
+	case ext == ".rss":
+		err = src.Feed.WriteRSS(body, feedInfo(src, r))
+	case bbox == nil && ext == ".gpx":
+		_, err = body.Write(snap.gpx)
+	case bbox == nil:
+		_, err = body.Write(snap.geojson)
+	case ext == ".gpx":
+		err = writeGPX(body, src.Title, snap.tracks, snap.changed, bbox)
+	default:
+		err = writeGeoJSON(body, src.Title, snap.tracks, bbox)
+	}
+	if err != nil {
+		slog.Warn("Failed to write response", "file", file, logging.Err(err))
+	}
+}
+
+func (s *Server) serveTile(w http.ResponseWriter, r *http.Request) {
+	y := r.PathValue("y")
+	if !strings.HasSuffix(y, ".pbf") && !strings.HasSuffix(y, ".mvt") {
+		http.NotFound(w, r)
+		return
+	}
+	t, err := tiles.ParseTileID(r.PathValue("z"), r.PathValue("x"), y)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	set := s.tiles
+	s.mu.RUnlock()
+	if set == nil {
+		w.Header().Set("Retry-After", "60")
+		http.Error(w, "tiles have not been loaded yet", http.StatusServiceUnavailable)
+		return
+	}
+	if t.Z > set.index.MaxZoom() {
+		http.NotFound(w, r)
+		return
+	}
+
+	gzipped := acceptsGzip(r)
+	tag := set.hash + "-" + strings.ReplaceAll(t.String(), "/", "-")
+	if gzipped {
+		tag += "-gzip"
+	}
+	etag := `"` + tag + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", set.changed.UTC().Format(http.TimeFormat))
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Add("Vary", "Accept-Encoding")
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	tile := set.index.Tile(t)
+	if tile == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	h.Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	var body io.Writer = w
+	if gzipped {
+		h.Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		defer gz.Close()
+		body = gz
+	}
+	if _, err := body.Write(tile); err != nil {
+		slog.Warn("Failed to write tile", "tile", t, logging.Err(err))
+	}
+}
+
+// feedInfo describes the feed of src as requested by r
+func feedInfo(src Source, r *http.Request) feed.Info {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	return feed.Info{Name: src.Name, Title: src.Title, Link: scheme + "://" + r.Host + r.URL.Path}
+}
+
+func (s *Server) source(name string) (Source, bool) {
+	for _, src := range s.sources {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+func writeGPX(w io.Writer, title string, tracks []converter.Track, t time.Time, bbox *converter.BBox) error {
+	gw, err := converter.NewGPXWriter(w, title, t)
+	if err != nil {
+		return err
+	}
+	for _, track := range tracks {
+		if bbox != nil && !bbox.Intersects(track.Bounds()) {
+			continue
+		}
+		if err := gw.WriteTrack(track); err != nil {
+			return err
+		}
+	}
+	return gw.Close()
+}
+
+func writeGeoJSON(w io.Writer, title string, tracks []converter.Track, bbox *converter.BBox) error {
+	gw, err := converter.NewGeoJSONWriter(w, title)
+	if err != nil {
+		return err
+	}
+	for _, track := range tracks {
+		if bbox != nil && !bbox.Intersects(track.Bounds()) {
+			continue
+		}
+		if err := gw.WriteTrack(track); err != nil {
+			return err
+		}
+	}
+	return gw.Close()
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(enc), ";")
+		if strings.EqualFold(strings.TrimSpace(enc), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// etagMatches implements the weak comparison of If-None-Match
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
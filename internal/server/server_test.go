@@ -0,0 +1,294 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/feed"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/metrics"
+	"github.com/tkrajina/gpxgo/gpx"
+)
+
+// fakeSource serves whatever tracks or error it currently holds
+type fakeSource struct {
+	tracks []converter.Track
+	err    error
+}
+
+// fetch yields the tracks, each read from one upstream feature
+func (f *fakeSource) fetch(stats *converter.Stats) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		if f.err != nil {
+			yield(converter.Track{}, f.err)
+			return
+		}
+		for _, track := range f.tracks {
+			stats.Features++
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
+
+var (
+	vilniusTrack  = converter.Track{Name: "Vilnius", Segments: [][]converter.Point{{{Lat: 54.68, Lon: 25.27}, {Lat: 54.69, Lon: 25.28}}}}
+	klaipedaTrack = converter.Track{Name: "Klaipeda", Segments: [][]converter.Point{{{Lat: 55.70, Lon: 21.13}, {Lat: 55.71, Lon: 21.14}}}}
+)
+
+func newTestServer(restrictions *fakeSource) *Server {
+	return New([]Source{
+		{Name: "restrictions", Title: "Restrictions", Fetch: restrictions.fetch},
+		{Name: "speed-control", Title: "Speed", Fetch: (&fakeSource{err: errors.New("upstream down")}).fetch},
+	}, guard.Thresholds{MinFeatures: 1}, nil)
+}
+
+func get(t *testing.T, h http.Handler, url string, header map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeDataset(t *testing.T) {
+	restrictions := &fakeSource{tracks: []converter.Track{vilniusTrack, klaipedaTrack}}
+	srv := newTestServer(restrictions)
+	h := srv.Handler()
+
+	if rec := get(t, h, "/restrictions.gpx", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first refresh, got %d", rec.Code)
+	}
+
+	srv.Refresh()
+
+	rec := get(t, h, "/restrictions.gpx", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	parsed, err := gpx.ParseBytes(rec.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Failed to parse served GPX: %v", err)
+	}
+	if len(parsed.Tracks) != 2 {
+		t.Errorf("Expected 2 tracks, got %d", len(parsed.Tracks))
+	}
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag header")
+	}
+	if rec := get(t, h, "/restrictions.gpx", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", rec.Code)
+	}
+
+	// Refreshing identical content keeps the ETag
+	srv.Refresh()
+	if rec := get(t, h, "/restrictions.gpx", nil); rec.Header().Get("ETag") != etag {
+		t.Errorf("ETag changed without a content change: %s -> %s", etag, rec.Header().Get("ETag"))
+	}
+
+	// bbox around Vilnius only
+	rec = get(t, h, "/restrictions.geojson?bbox=25,54.5,25.5,55", nil)
+	var collection struct {
+		Features []struct {
+			Properties map[string]string `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &collection); err != nil {
+		t.Fatalf("Failed to parse served GeoJSON: %v", err)
+	}
+	if len(collection.Features) != 1 || collection.Features[0].Properties["name"] != "Vilnius" {
+		t.Errorf("Expected only the Vilnius track inside the bbox, got %+v", collection.Features)
+	}
+	if rec.Header().Get("ETag") == etag {
+		t.Error("A bbox request should have its own ETag")
+	}
+
+	if rec := get(t, h, "/restrictions.geojson?bbox=1,2,3", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid bbox, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/unknown.gpx", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown source, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/restrictions.txt", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown format, got %d", rec.Code)
+	}
+}
+
+func TestServeDatasetGzip(t *testing.T) {
+	srv := newTestServer(&fakeSource{tracks: []converter.Track{vilniusTrack}})
+	srv.Refresh()
+
+	rec := get(t, srv.Handler(), "/restrictions.geojson", map[string]string{"Accept-Encoding": "gzip, deflate"})
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("Expected gzip encoding, got %q", rec.Header().Get("Content-Encoding"))
+	}
+
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("Failed to open gzip body: %v", err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("Failed to read gzip body: %v", err)
+	}
+	if !strings.Contains(string(body), `"Vilnius"`) {
+		t.Errorf("Expected decompressed body to contain the track, got %s", body)
+	}
+}
+
+func TestHealthAndFailedRefresh(t *testing.T) {
+	restrictions := &fakeSource{tracks: []converter.Track{vilniusTrack}}
+	srv := newTestServer(restrictions)
+	srv.Refresh()
+
+	// A collapsed refresh is rejected and the previous snapshot stays
+	restrictions.tracks = nil
+	srv.Refresh()
+	if rec := get(t, srv.Handler(), "/restrictions.geojson", nil); !strings.Contains(rec.Body.String(), "Vilnius") {
+		t.Errorf("Previous snapshot should still be served, got %s", rec.Body.String())
+	}
+
+	rec := get(t, srv.Handler(), "/healthz", nil)
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 while a source was never loaded, got %d", rec.Code)
+	}
+
+	var report struct {
+		Status  string                  `json:"status"`
+		Sources map[string]sourceHealth `json:"sources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to parse health report: %v", err)
+	}
+
+	r := report.Sources["restrictions"]
+	if r.Tracks != 1 || r.LastRefresh == nil || !strings.Contains(r.LastError, "minimum") {
+		t.Errorf("Unexpected restrictions health: %+v", r)
+	}
+	s := report.Sources["speed-control"]
+	if s.LastRefresh != nil || s.LastError != "upstream down" {
+		t.Errorf("Unexpected speed-control health: %+v", s)
+	}
+}
+
+func TestServeTile(t *testing.T) {
+	srv := newTestServer(&fakeSource{tracks: []converter.Track{vilniusTrack, klaipedaTrack}})
+	h := srv.Handler()
+
+	if rec := get(t, h, "/tiles/0/0/0.pbf", nil); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 before the first refresh, got %d", rec.Code)
+	}
+
+	srv.Refresh()
+
+	// z10 tile containing Vilnius
+	rec := get(t, h, "/tiles/10/583/325.pbf", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.mapbox-vector-tile" {
+		t.Errorf("Unexpected content type %q", ct)
+	}
+	if !strings.Contains(rec.Body.String(), "restrictions") || !strings.Contains(rec.Body.String(), "Vilnius") {
+		t.Error("Expected the tile to contain the restrictions layer and the Vilnius track")
+	}
+	if strings.Contains(rec.Body.String(), "Klaipeda") {
+		t.Error("Klaipeda lies outside the tile")
+	}
+
+	etag := rec.Header().Get("ETag")
+	if rec := get(t, h, "/tiles/10/583/325.pbf", map[string]string{"If-None-Match": etag}); rec.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", rec.Code)
+	}
+	srv.Refresh()
+	if rec := get(t, h, "/tiles/10/583/325.pbf", nil); rec.Header().Get("ETag") != etag {
+		t.Error("Tile ETag changed without a content change")
+	}
+
+	if rec := get(t, h, "/tiles/10/0/0.pbf", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204 for an empty tile, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/tiles/2/5/0.pbf", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a tile outside the grid, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/tiles/20/0/0.pbf", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 beyond the maximum zoom, got %d", rec.Code)
+	}
+	if rec := get(t, h, "/tiles/1/0/0.png", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown tile format, got %d", rec.Code)
+	}
+}
+
+func TestServeFeed(t *testing.T) {
+	restrictions := &fakeSource{tracks: []converter.Track{vilniusTrack}}
+	f, err := feed.Load("")
+	if err != nil {
+		t.Fatalf("Failed to create feed: %v", err)
+	}
+	srv := New([]Source{
+		{Name: "restrictions", Title: "Restrictions", Fetch: restrictions.fetch, Feed: f},
+		{Name: "speed-control", Title: "Speed", Fetch: (&fakeSource{tracks: []converter.Track{klaipedaTrack}}).fetch},
+	}, guard.Thresholds{MinFeatures: 1}, nil)
+	srv.Refresh()
+
+	restrictions.tracks = []converter.Track{vilniusTrack, klaipedaTrack}
+	srv.Refresh()
+
+	rec := get(t, srv.Handler(), "/restrictions.atom", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/atom+xml" {
+		t.Fatalf("Unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	body := rec.Body.String()
+	if strings.Count(body, "<entry>") != 2 || !strings.Contains(body, `href="http://example.com/restrictions.atom"`) {
+		t.Errorf("Expected 2 entries and a self link, got %s", body)
+	}
+
+	if rec := get(t, srv.Handler(), "/restrictions.rss", nil); !strings.Contains(rec.Body.String(), "<rss") {
+		t.Errorf("Expected an RSS document, got %s", rec.Body.String())
+	}
+	if rec := get(t, srv.Handler(), "/speed-control.atom", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a source without a feed, got %d", rec.Code)
+	}
+}
+
+func TestServeMetrics(t *testing.T) {
+	m := metrics.New()
+	srv := New([]Source{
+		{Name: "restrictions", Title: "Restrictions", Fetch: (&fakeSource{tracks: []converter.Track{vilniusTrack}}).fetch},
+		{Name: "speed-control", Title: "Speed", Fetch: (&fakeSource{}).fetch},
+	}, guard.Thresholds{MinFeatures: 1}, m)
+	srv.Refresh()
+
+	rec := get(t, srv.Handler(), "/metrics", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	for _, want := range []string{
+		`lt_road_info_refreshes_total{result="success",source="restrictions"} 1`,
+		`lt_road_info_refreshes_total{result="guard",source="speed-control"} 1`,
+		`lt_road_info_guard_rejections_total{source="speed-control"} 1`,
+		`lt_road_info_last_success_timestamp_seconds{source="restrictions"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `lt_road_info_last_success_timestamp_seconds{source="speed-control"}`) {
+		t.Error("Expected no last success for the rejected source")
+	}
+}
@@ -0,0 +1,109 @@
+// Package split writes the tracks of a source once more per group, such as
+// per road or per category, next to its outputs.
+package split
+
+import (
+	"errors"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/roads"
+)
+
+// Kinds of split
+const (
+	KindRoad     = "road"
+	KindCategory = "category"
+)
+
+// Kinds lists the valid kinds of split
+var Kinds = []string{KindRoad, KindCategory}
+
+// keys return the group of a track per kind, "" to leave it out
+var keys = map[string]func(track converter.Track) string{
+	KindRoad: roads.Number,
+	KindCategory: func(track converter.Track) string {
+		category, _ := track.Properties["category"].(string)
+		return category
+	},
+}
+
+// Write writes tracks once more for every kind of split, one file per group
+// and format next to outputs, e.g. lt-road-restrictions-A1.gpx or
+// lt-road-restrictions-weight.gpx. Groups shrink and vanish as road works
+// end, so the files are always replaced; the guard checks already applied
+// to the complete outputs.
+//
+// previous lists the split files of the last run, as recorded in the
+// manifest. Those that belong to no group any more are removed; no other
+// file is ever removed, so files a user keeps next to the outputs are safe
+// whatever their name. The split files present afterwards are returned,
+// including any that failed to be replaced, so that they are recorded for
+// the next run.
+func Write(kinds []string, outputs []converter.Output, title string, tracks []converter.Track, previous []string, opts converter.OutputOptions) ([]converter.Output, error) {
+	opts.Force = true
+	opts.Validate = nil
+
+	var (
+		present []converter.Output
+		errs    []error
+		current = make(map[string]bool)
+	)
+	for _, kind := range kinds {
+		key := keys[kind]
+		groups := make(map[string][]converter.Track)
+		for _, track := range tracks {
+			if k := key(track); k != "" {
+				groups[k] = append(groups[k], track)
+			}
+		}
+
+		for _, out := range outputs {
+			prefix := strings.TrimSuffix(out.Path, "."+out.Format) + "-"
+			for k, group := range groups {
+				split := converter.Output{Path: prefix + k + "." + out.Format, Format: out.Format}
+				if current[filepath.Clean(split.Path)] {
+					continue
+				}
+				current[filepath.Clean(split.Path)] = true
+				if err := converter.SaveTracks(tracksOf(group), title+" - "+k, []converter.Output{split}, opts); err != nil {
+					errs = append(errs, err)
+					if _, err := os.Stat(split.Path); err != nil {
+						continue
+					}
+				}
+				present = append(present, split)
+			}
+		}
+	}
+
+	for _, path := range previous {
+		if current[filepath.Clean(path)] {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Debug("Removed split output of a group no longer present", "path", path)
+	}
+
+	slices.SortFunc(present, func(a, b converter.Output) int { return strings.Compare(a.Path, b.Path) })
+	return present, errors.Join(errs...)
+}
+
+// tracksOf streams a slice of tracks
+func tracksOf(tracks []converter.Track) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		for _, track := range tracks {
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
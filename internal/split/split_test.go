@@ -0,0 +1,124 @@
+package split
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func restriction(name, category string) converter.Track {
+	return converter.Track{
+		Name:       name,
+		Segments:   [][]converter.Point{{{Lat: 54.68, Lon: 25.27}, {Lat: 54.69, Lon: 25.28}}},
+		Properties: map[string]any{"category": category},
+	}
+}
+
+func names(outputs []converter.Output) []string {
+	var list []string
+	for _, out := range outputs {
+		list = append(list, filepath.Base(out.Path))
+	}
+	return list
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	outputs := []converter.Output{
+		{Path: filepath.Join(dir, "lt-road-restrictions.gpx"), Format: converter.FormatGPX},
+		{Path: filepath.Join(dir, "lt-road-restrictions.geojson"), Format: converter.FormatGeoJSON},
+	}
+	tracks := []converter.Track{
+		restriction("A1 Vilnius–Kaunas", "closures"),
+		restriction("Kelio Nr. 101 remontas", "weight"),
+		restriction("Vietinis kelias", "other"),
+	}
+
+	files, err := Write([]string{KindRoad, KindCategory}, outputs, "Restrictions", tracks, nil, converter.OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"lt-road-restrictions-A1.geojson", "lt-road-restrictions-A1.gpx",
+		"lt-road-restrictions-KK101.geojson", "lt-road-restrictions-KK101.gpx",
+		"lt-road-restrictions-closures.geojson", "lt-road-restrictions-closures.gpx",
+		"lt-road-restrictions-other.geojson", "lt-road-restrictions-other.gpx",
+		"lt-road-restrictions-weight.geojson", "lt-road-restrictions-weight.gpx",
+	}
+	if got := names(files); !reflect.DeepEqual(got, want) {
+		t.Errorf("Write = %v, want %v", got, want)
+	}
+
+	a1, err := converter.ReadFile(filepath.Join(dir, "lt-road-restrictions-A1.gpx"))
+	if err != nil || len(a1) != 1 || a1[0].Name != "A1 Vilnius–Kaunas" {
+		t.Errorf("Unexpected A1 split: %+v, %v", a1, err)
+	}
+}
+
+func TestWriteRemovesOnlyPreviousSplitFiles(t *testing.T) {
+	dir := t.TempDir()
+	outputs := []converter.Output{{Path: filepath.Join(dir, "lt-road-restrictions.gpx"), Format: converter.FormatGPX}}
+	a1 := filepath.Join(dir, "lt-road-restrictions-A1.gpx")
+	a6 := filepath.Join(dir, "lt-road-restrictions-A6.gpx")
+
+	previous, err := Write([]string{KindRoad}, outputs, "Restrictions", []converter.Track{
+		restriction("A1 Vilnius–Kaunas", "closures"),
+		restriction("A6 Kaunas–Zarasai", "closures"),
+	}, nil, converter.OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Files a user keeps next to the outputs look like regional roads
+	kept := []string{
+		filepath.Join(dir, "lt-road-restrictions-2025.gpx"),
+		filepath.Join(dir, "lt-road-restrictions-A12.gpx"),
+	}
+	for _, path := range kept {
+		if err := os.WriteFile(path, []byte("mine"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The A6 works ended
+	var paths []string
+	for _, out := range previous {
+		paths = append(paths, out.Path)
+	}
+	files, err := Write([]string{KindRoad}, outputs, "Restrictions", []converter.Track{
+		restriction("A1 Vilnius–Kaunas", "closures"),
+	}, paths, converter.OutputOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := names(files); !reflect.DeepEqual(got, []string{"lt-road-restrictions-A1.gpx"}) {
+		t.Errorf("Write = %v, want only the A1 split", got)
+	}
+	if !exists(a1) {
+		t.Error("The split of a remaining road was removed")
+	}
+	if exists(a6) {
+		t.Error("The split of a road without tracks was kept")
+	}
+	for _, path := range kept {
+		if !exists(path) {
+			t.Errorf("%s was removed although no run wrote it", filepath.Base(path))
+		}
+	}
+
+	// Without splits, every previous split file is stale
+	if files, err := Write(nil, outputs, "Restrictions", nil, []string{a1}, converter.OutputOptions{}); err != nil || len(files) != 0 {
+		t.Errorf("Write = %v, %v, want nothing", files, err)
+	}
+	if exists(a1) {
+		t.Error("The split files were kept after splitting was turned off")
+	}
+}
@@ -0,0 +1,124 @@
+package tiles
+
+// rect is an axis-aligned box in normalized Web Mercator
+type rect struct {
+	minX, minY, maxX, maxY float64
+}
+
+// bounds returns the area covered by a tile including the buffer
+func (t TileID) bounds() rect {
+	size := 1 / float64(int(1)<<t.Z)
+	pad := size * Buffer / Extent
+	return rect{
+		minX: float64(t.X)*size - pad,
+		minY: float64(t.Y)*size - pad,
+		maxX: float64(t.X+1)*size + pad,
+		maxY: float64(t.Y+1)*size + pad,
+	}
+}
+
+func (r rect) intersects(o rect) bool {
+	return r.minX <= o.maxX && o.minX <= r.maxX && r.minY <= o.maxY && o.minY <= r.maxY
+}
+
+func (r rect) contains(o rect) bool {
+	return r.minX <= o.minX && o.maxX <= r.maxX && r.minY <= o.minY && o.maxY <= r.maxY
+}
+
+// lineBounds returns the bounding box of the given lines
+func lineBounds(lines [][]point) rect {
+	r := rect{minX: 2, minY: 2, maxX: -1, maxY: -1}
+	for _, line := range lines {
+		for _, p := range line {
+			r.minX = min(r.minX, p.x)
+			r.minY = min(r.minY, p.y)
+			r.maxX = max(r.maxX, p.x)
+			r.maxY = max(r.maxY, p.y)
+		}
+	}
+	return r
+}
+
+// clipLines cuts lines to r. A line leaving and re-entering r becomes
+// several lines; pieces shorter than two points are dropped.
+func clipLines(lines [][]point, r rect) [][]point {
+	var out [][]point
+	for _, line := range lines {
+		out = append(out, clipLine(line, r)...)
+	}
+	return out
+}
+
+func clipLine(line []point, r rect) [][]point {
+	var (
+		out     [][]point
+		current []point
+	)
+	for i := 0; i+1 < len(line); i++ {
+		a, b, entered, exited, ok := clipSegment(line[i], line[i+1], r)
+		if !ok {
+			continue
+		}
+		if entered || current == nil {
+			if len(current) > 1 {
+				out = append(out, current)
+			}
+			current = []point{a}
+		}
+		current = append(current, b)
+		if exited {
+			out = append(out, current)
+			current = nil
+		}
+	}
+	if len(current) > 1 {
+		out = append(out, current)
+	}
+	return out
+}
+
+// clipSegment clips the segment a-b to r using the Liang-Barsky algorithm.
+// entered and exited report whether a or b, respectively, was moved onto
+// the edge of r.
+func clipSegment(a, b point, r rect) (ca, cb point, entered, exited, ok bool) {
+	dx, dy := b.x-a.x, b.y-a.y
+	t0, t1 := 0.0, 1.0
+
+	for _, edge := range [4][2]float64{
+		{-dx, a.x - r.minX},
+		{dx, r.maxX - a.x},
+		{-dy, a.y - r.minY},
+		{dy, r.maxY - a.y},
+	} {
+		p, q := edge[0], edge[1]
+		if p == 0 {
+			if q < 0 {
+				return point{}, point{}, false, false, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			if t > t1 {
+				return point{}, point{}, false, false, false
+			}
+			t0 = max(t0, t)
+		} else {
+			if t < t0 {
+				return point{}, point{}, false, false, false
+			}
+			t1 = min(t1, t)
+		}
+	}
+
+	ca, cb = a, b
+	if t0 > 0 {
+		ca = point{a.x + t0*dx, a.y + t0*dy}
+		entered = true
+	}
+	if t1 < 1 {
+		cb = point{a.x + t1*dx, a.y + t1*dy}
+		exited = true
+	}
+	return ca, cb, entered, exited, true
+}
@@ -0,0 +1,210 @@
+package tiles
+
+import (
+	"sync"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// maxCachedNodes bounds the number of clipped tiles kept by an Index
+const maxCachedNodes = 4096
+
+// Layer is a named set of tracks rendered as one MVT layer
+type Layer struct {
+	Name   string
+	Tracks []converter.Track
+}
+
+// Index cuts layers into vector tiles. Tiles are clipped top-down along the
+// quadtree: a tile is cut from its parent's already clipped geometry, so
+// each level only has to deal with the features that reach it. Clipped
+// tiles are cached, making it suitable for serving tiles on demand.
+type Index struct {
+	maxZoom int
+	names   []string
+	root    node
+	bounds  rect
+
+	mu    sync.Mutex
+	nodes map[TileID]node
+}
+
+// node holds the features of every layer clipped to one tile
+type node [][]feature
+
+func (n node) empty() bool {
+	for _, features := range n {
+		if len(features) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewIndex projects the layers' tracks and prepares them for tiling up to
+// maxZoom. Track properties become feature attributes, along with the
+// track name.
+func NewIndex(layers []Layer, maxZoom int) *Index {
+	ix := &Index{
+		maxZoom: maxZoom,
+		root:    make(node, len(layers)),
+		bounds:  rect{minX: 2, minY: 2, maxX: -1, maxY: -1},
+		nodes:   make(map[TileID]node),
+	}
+	for i, l := range layers {
+		ix.names = append(ix.names, l.Name)
+		for j, track := range l.Tracks {
+			f := projectTrack(track)
+			if len(f.lines) == 0 {
+				continue
+			}
+			f.id = uint64(j + 1)
+			ix.root[i] = append(ix.root[i], f)
+
+			ix.bounds.minX = min(ix.bounds.minX, f.bounds.minX)
+			ix.bounds.minY = min(ix.bounds.minY, f.bounds.minY)
+			ix.bounds.maxX = max(ix.bounds.maxX, f.bounds.maxX)
+			ix.bounds.maxY = max(ix.bounds.maxY, f.bounds.maxY)
+		}
+	}
+	return ix
+}
+
+func projectTrack(track converter.Track) feature {
+	f := feature{properties: make(map[string]any, len(track.Properties)+1)}
+	for k, v := range track.Properties {
+		f.properties[k] = v
+	}
+	f.properties["name"] = track.Name
+
+	for _, segment := range track.Segments {
+		if len(segment) < 2 {
+			continue
+		}
+		line := make([]point, len(segment))
+		for i, p := range segment {
+			line[i] = project(p)
+		}
+		f.lines = append(f.lines, line)
+	}
+	f.bounds = lineBounds(f.lines)
+	return f
+}
+
+// MaxZoom returns the deepest zoom level the index produces
+func (ix *Index) MaxZoom() int {
+	return ix.maxZoom
+}
+
+// Bounds returns the WGS84 bounding box of all features. It is zero when
+// the index is empty.
+func (ix *Index) Bounds() converter.BBox {
+	if ix.bounds.minX > ix.bounds.maxX {
+		return converter.BBox{}
+	}
+	sw := unproject(point{ix.bounds.minX, ix.bounds.maxY})
+	ne := unproject(point{ix.bounds.maxX, ix.bounds.minY})
+	return converter.BBox{MinLon: sw.Lon, MinLat: sw.Lat, MaxLon: ne.Lon, MaxLat: ne.Lat}
+}
+
+// Tile returns the encoded vector tile t, or nil when t is empty or deeper
+// than MaxZoom
+func (ix *Index) Tile(t TileID) []byte {
+	if !t.Valid() || t.Z > ix.maxZoom {
+		return nil
+	}
+	n := ix.node(t)
+	if n.empty() {
+		return nil
+	}
+	return encodeTile(t, ix.layers(n))
+}
+
+// node returns the clipped features of t, cutting and caching them from
+// its ancestors as needed
+func (ix *Index) node(t TileID) node {
+	if t.Z == 0 {
+		return ix.root
+	}
+
+	ix.mu.Lock()
+	n, ok := ix.nodes[t]
+	ix.mu.Unlock()
+	if ok {
+		return n
+	}
+
+	parent := ix.node(t.parent())
+	if parent.empty() {
+		n = parent
+	} else {
+		n = clipNode(parent, t)
+	}
+
+	ix.mu.Lock()
+	if len(ix.nodes) >= maxCachedNodes {
+		clear(ix.nodes)
+	}
+	ix.nodes[t] = n
+	ix.mu.Unlock()
+	return n
+}
+
+// clipNode cuts the features of a parent tile to the child tile t
+func clipNode(parent node, t TileID) node {
+	r := t.bounds()
+	n := make(node, len(parent))
+	for i, features := range parent {
+		for _, f := range features {
+			if !r.intersects(f.bounds) {
+				continue
+			}
+			if !r.contains(f.bounds) {
+				lines := clipLines(f.lines, r)
+				if len(lines) == 0 {
+					continue
+				}
+				f.lines = lines
+				f.bounds = lineBounds(lines)
+			}
+			n[i] = append(n[i], f)
+		}
+	}
+	return n
+}
+
+func (ix *Index) layers(n node) []layer {
+	layers := make([]layer, len(n))
+	for i, features := range n {
+		layers[i] = layer{name: ix.names[i], features: features}
+	}
+	return layers
+}
+
+// Walk calls fn with every non-empty tile between minZoom and the index's
+// MaxZoom in depth-first order. Tiles are clipped without using the cache.
+// Walking stops at the first error returned by fn.
+func (ix *Index) Walk(minZoom int, fn func(TileID, []byte) error) error {
+	return ix.walk(TileID{}, ix.root, minZoom, fn)
+}
+
+func (ix *Index) walk(t TileID, n node, minZoom int, fn func(TileID, []byte) error) error {
+	if t.Z >= minZoom {
+		if tile := encodeTile(t, ix.layers(n)); tile != nil {
+			if err := fn(t, tile); err != nil {
+				return err
+			}
+		}
+	}
+	if t.Z >= ix.maxZoom {
+		return nil
+	}
+	for _, child := range t.children() {
+		if c := clipNode(n, child); !c.empty() {
+			if err := ix.walk(child, c, minZoom, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
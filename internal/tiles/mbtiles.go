@@ -0,0 +1,174 @@
+package tiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	_ "modernc.org/sqlite"
+)
+
+// Metadata describes an MBTiles tileset
+type Metadata struct {
+	Name        string
+	Description string
+	Attribution string
+}
+
+// WriteMBTiles writes every non-empty tile from minZoom to the index's
+// MaxZoom into an MBTiles 1.3 file at path. Tiles are stored gzipped with
+// rows in the TMS scheme the format requires. The file is built under a
+// unique temporary name next to path and renamed into place once complete,
+// so concurrent exports do not clobber each other.
+func WriteMBTiles(path string, ix *Index, meta Metadata, minZoom int) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create MBTiles file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	if err := writeMBTiles(tmpPath, ix, meta, minZoom); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func writeMBTiles(path string, ix *Index, meta Metadata, minZoom int) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("failed to create MBTiles file: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"CREATE TABLE metadata (name text, value text)",
+		"CREATE TABLE tiles (zoom_level integer, tile_column integer, tile_row integer, tile_data blob)",
+		"CREATE UNIQUE INDEX tile_index ON tiles (zoom_level, tile_column, tile_row)",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to create MBTiles schema: %w", err)
+		}
+	}
+
+	rows, err := ix.metadata(meta, minZoom)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedRows(rows) {
+		if _, err := tx.Exec("INSERT INTO metadata (name, value) VALUES (?, ?)", name, rows[name]); err != nil {
+			return fmt.Errorf("failed to write MBTiles metadata: %w", err)
+		}
+	}
+
+	insert, err := tx.Prepare("INSERT INTO tiles (zoom_level, tile_column, tile_row, tile_data) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	var buf bytes.Buffer
+	err = ix.Walk(minZoom, func(t TileID, tile []byte) error {
+		buf.Reset()
+		gz := gzip.NewWriter(&buf)
+		gz.Write(tile)
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		row := (1 << t.Z) - 1 - t.Y
+		if _, err := insert.Exec(t.Z, t.X, row, buf.Bytes()); err != nil {
+			return fmt.Errorf("failed to write tile %s: %w", t, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to write MBTiles file: %w", err)
+	}
+	return nil
+}
+
+// metadata returns the rows of the MBTiles metadata table
+func (ix *Index) metadata(meta Metadata, minZoom int) (map[string]string, error) {
+	type vectorLayer struct {
+		ID      string            `json:"id"`
+		Fields  map[string]string `json:"fields"`
+		MinZoom int               `json:"minzoom"`
+		MaxZoom int               `json:"maxzoom"`
+	}
+	var layers []vectorLayer
+	for i, name := range ix.names {
+		fields := make(map[string]string)
+		for _, f := range ix.root[i] {
+			for k, v := range f.properties {
+				if val, ok := newValue(v); ok {
+					fields[k] = fieldType(val)
+				}
+			}
+		}
+		layers = append(layers, vectorLayer{ID: name, Fields: fields, MinZoom: minZoom, MaxZoom: ix.maxZoom})
+	}
+	vectorLayers, err := json.Marshal(map[string]any{"vector_layers": layers})
+	if err != nil {
+		return nil, err
+	}
+
+	b := ix.Bounds()
+	rows := map[string]string{
+		"name":    meta.Name,
+		"format":  "pbf",
+		"type":    "overlay",
+		"version": "1",
+		"minzoom": strconv.Itoa(minZoom),
+		"maxzoom": strconv.Itoa(ix.maxZoom),
+		"bounds":  fmt.Sprintf("%f,%f,%f,%f", b.MinLon, b.MinLat, b.MaxLon, b.MaxLat),
+		"center":  fmt.Sprintf("%f,%f,%d", (b.MinLon+b.MaxLon)/2, (b.MinLat+b.MaxLat)/2, max(minZoom, min(ix.maxZoom, 7))),
+		"json":    string(vectorLayers),
+	}
+	if meta.Description != "" {
+		rows["description"] = meta.Description
+	}
+	if meta.Attribution != "" {
+		rows["attribution"] = meta.Attribution
+	}
+	return rows, nil
+}
+
+func fieldType(v value) string {
+	switch v.kind {
+	case valueString:
+		return "String"
+	case valueBool:
+		return "Boolean"
+	default:
+		return "Number"
+	}
+}
+
+func sortedRows(rows map[string]string) []string {
+	keys := make([]string, 0, len(rows))
+	for k := range rows {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package tiles
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func TestWriteMBTiles(t *testing.T) {
+	ix := NewIndex([]Layer{{Name: "restrictions", Tracks: []converter.Track{vilniusTrack}}}, 10)
+	path := filepath.Join(t.TempDir(), "roads.mbtiles")
+
+	if err := WriteMBTiles(path, ix, Metadata{Name: "Lithuanian roads"}, 5); err != nil {
+		t.Fatalf("WriteMBTiles failed: %v", err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open MBTiles: %v", err)
+	}
+	defer db.Close()
+
+	meta := make(map[string]string)
+	rows, err := db.Query("SELECT name, value FROM metadata")
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	for rows.Next() {
+		var name, value string
+		rows.Scan(&name, &value)
+		meta[name] = value
+	}
+	rows.Close()
+
+	if meta["format"] != "pbf" || meta["minzoom"] != "5" || meta["maxzoom"] != "10" || meta["name"] != "Lithuanian roads" {
+		t.Errorf("Unexpected metadata: %v", meta)
+	}
+	if !strings.Contains(meta["json"], `"id":"restrictions"`) || !strings.Contains(meta["json"], `"iconValue":"Number"`) {
+		t.Errorf("Expected vector_layers describing the layer fields, got %s", meta["json"])
+	}
+
+	var count int
+	db.QueryRow("SELECT COUNT(*) FROM tiles").Scan(&count)
+	walked := 0
+	ix.Walk(5, func(TileID, []byte) error { walked++; return nil })
+	if count != walked {
+		t.Errorf("Expected %d tiles, got %d", walked, count)
+	}
+
+	// Rows use the TMS scheme, flipped relative to XYZ
+	start := project(vilniusTrack.Segments[0][0])
+	x, y := int(start.x*1024), int(start.y*1024)
+	var blob []byte
+	if err := db.QueryRow("SELECT tile_data FROM tiles WHERE zoom_level = 10 AND tile_column = ? AND tile_row = ?", x, 1023-y).Scan(&blob); err != nil {
+		t.Fatalf("Expected tile 10/%d/%d: %v", x, y, err)
+	}
+	zr, err := gzip.NewReader(strings.NewReader(string(blob)))
+	if err != nil {
+		t.Fatalf("Tile data is not gzipped: %v", err)
+	}
+	tile, _ := io.ReadAll(zr)
+	if layers := decodeTile(t, tile); len(layers) != 1 || len(layers[0].features) != 1 {
+		t.Errorf("Unexpected tile content: %+v", layers)
+	}
+}
+
+func TestWriteMBTilesConcurrently(t *testing.T) {
+	ix := NewIndex([]Layer{{Name: "restrictions", Tracks: []converter.Track{vilniusTrack}}}, 8)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "roads.mbtiles")
+
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = WriteMBTiles(path, ix, Metadata{Name: "Lithuanian roads"}, 5)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatalf("Concurrent WriteMBTiles failed: %v", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("Failed to open MBTiles: %v", err)
+	}
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tiles").Scan(&count); err != nil || count == 0 {
+		t.Errorf("Expected a complete MBTiles file, got %d tiles: %v", count, err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the MBTiles file to remain, got %d files", len(entries))
+	}
+}
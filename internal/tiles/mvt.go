@@ -0,0 +1,277 @@
+package tiles
+
+import (
+	"math"
+	"slices"
+)
+
+// Protocol buffer field numbers and constants of the Mapbox Vector Tile
+// specification version 2
+const (
+	mvtVersion = 2
+
+	tileLayers = 3
+
+	layerVersion  = 15
+	layerName     = 1
+	layerFeatures = 2
+	layerKeys     = 3
+	layerValues   = 4
+	layerExtent   = 5
+
+	featureID       = 1
+	featureTags     = 2
+	featureType     = 3
+	featureGeometry = 4
+
+	valueString = 1
+	valueDouble = 3
+	valueSint   = 6
+	valueBool   = 7
+
+	geomLineString = 2
+
+	cmdMoveTo = 1
+	cmdLineTo = 2
+
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+// feature is a track projected to normalized Web Mercator
+type feature struct {
+	id         uint64
+	properties map[string]any
+	lines      [][]point
+	bounds     rect
+}
+
+// layer is a named set of features as stored in a tile
+type layer struct {
+	name     string
+	features []feature
+}
+
+// encodeTile encodes the layers as tile t. Geometry must already be clipped
+// to the tile's bounds. Layers without any drawable feature are omitted and
+// nil is returned when nothing is left.
+func encodeTile(t TileID, layers []layer) []byte {
+	var tile pbf
+	for _, l := range layers {
+		if msg := encodeLayer(t, l); msg != nil {
+			tile.bytes(tileLayers, msg)
+		}
+	}
+	return tile
+}
+
+func encodeLayer(t TileID, l layer) []byte {
+	var (
+		msg    pbf
+		keys   []string
+		values []value
+	)
+	keyIndex := make(map[string]uint32)
+	valueIndex := make(map[value]uint32)
+
+	msg.uint(layerVersion, mvtVersion)
+	msg.string(layerName, l.name)
+
+	drawn := 0
+	for _, f := range l.features {
+		geometry := encodeGeometry(t, f.lines)
+		if geometry == nil {
+			continue
+		}
+
+		var tags []uint32
+		for _, k := range sortedKeys(f.properties) {
+			v, ok := newValue(f.properties[k])
+			if !ok {
+				continue
+			}
+			ki, ok := keyIndex[k]
+			if !ok {
+				ki = uint32(len(keys))
+				keyIndex[k] = ki
+				keys = append(keys, k)
+			}
+			vi, ok := valueIndex[v]
+			if !ok {
+				vi = uint32(len(values))
+				valueIndex[v] = vi
+				values = append(values, v)
+			}
+			tags = append(tags, ki, vi)
+		}
+
+		var fm pbf
+		fm.uint(featureID, f.id)
+		if len(tags) > 0 {
+			fm.packed(featureTags, tags)
+		}
+		fm.uint(featureType, geomLineString)
+		fm.packed(featureGeometry, geometry)
+		msg.bytes(layerFeatures, fm)
+		drawn++
+	}
+	if drawn == 0 {
+		return nil
+	}
+
+	for _, k := range keys {
+		msg.string(layerKeys, k)
+	}
+	for _, v := range values {
+		msg.bytes(layerValues, v.encode())
+	}
+	msg.uint(layerExtent, Extent)
+	return msg
+}
+
+// encodeGeometry converts lines to tile coordinates and encodes them as
+// MoveTo/LineTo commands. Vertices that round to the previous position are
+// dropped, which simplifies lines at low zoom levels.
+func encodeGeometry(t TileID, lines [][]point) []uint32 {
+	var (
+		geometry []uint32
+		cx, cy   int64
+	)
+	scale := float64(int(1) << t.Z)
+	for _, line := range lines {
+		coords := make([][2]int64, 0, len(line))
+		for _, p := range line {
+			c := [2]int64{
+				int64(math.Round((p.x*scale - float64(t.X)) * Extent)),
+				int64(math.Round((p.y*scale - float64(t.Y)) * Extent)),
+			}
+			if len(coords) > 0 && coords[len(coords)-1] == c {
+				continue
+			}
+			coords = append(coords, c)
+		}
+		if len(coords) < 2 {
+			continue
+		}
+
+		for i, c := range coords {
+			switch i {
+			case 0:
+				geometry = append(geometry, command(cmdMoveTo, 1))
+			case 1:
+				geometry = append(geometry, command(cmdLineTo, len(coords)-1))
+			}
+			geometry = append(geometry, zigzag(c[0]-cx), zigzag(c[1]-cy))
+			cx, cy = c[0], c[1]
+		}
+	}
+	return geometry
+}
+
+func command(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func zigzag(n int64) uint32 {
+	return uint32((n << 1) ^ (n >> 63))
+}
+
+// value is a layer attribute value. It is comparable so that equal values
+// share one entry in the layer's value table.
+type value struct {
+	kind    int
+	str     string
+	num     float64
+	integer int64
+	boolean bool
+}
+
+func newValue(v any) (value, bool) {
+	switch v := v.(type) {
+	case string:
+		return value{kind: valueString, str: v}, true
+	case bool:
+		return value{kind: valueBool, boolean: v}, true
+	case int:
+		return value{kind: valueSint, integer: int64(v)}, true
+	case int64:
+		return value{kind: valueSint, integer: v}, true
+	case float64:
+		// JSON numbers arrive as float64; keep whole numbers as integers
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return value{kind: valueSint, integer: int64(v)}, true
+		}
+		return value{kind: valueDouble, num: v}, true
+	}
+	return value{}, false
+}
+
+func (v value) encode() []byte {
+	var msg pbf
+	switch v.kind {
+	case valueString:
+		msg.string(valueString, v.str)
+	case valueDouble:
+		msg.key(valueDouble, 1)
+		bits := math.Float64bits(v.num)
+		for i := 0; i < 8; i++ {
+			msg = append(msg, byte(bits>>(8*i)))
+		}
+	case valueSint:
+		msg.uint(valueSint, uint64((v.integer<<1)^(v.integer>>63)))
+	case valueBool:
+		var b uint64
+		if v.boolean {
+			b = 1
+		}
+		msg.uint(valueBool, b)
+	}
+	return msg
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// pbf is a minimal protocol buffer message writer
+type pbf []byte
+
+func (b *pbf) varint(v uint64) {
+	for v >= 0x80 {
+		*b = append(*b, byte(v)|0x80)
+		v >>= 7
+	}
+	*b = append(*b, byte(v))
+}
+
+func (b *pbf) key(field, wireType int) {
+	b.varint(uint64(field<<3 | wireType))
+}
+
+func (b *pbf) uint(field int, v uint64) {
+	b.key(field, wireVarint)
+	b.varint(v)
+}
+
+func (b *pbf) bytes(field int, v []byte) {
+	b.key(field, wireBytes)
+	b.varint(uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *pbf) string(field int, v string) {
+	b.bytes(field, []byte(v))
+}
+
+func (b *pbf) packed(field int, v []uint32) {
+	var body pbf
+	for _, n := range v {
+		body.varint(uint64(n))
+	}
+	b.bytes(field, body)
+}
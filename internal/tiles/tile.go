@@ -0,0 +1,102 @@
+// Package tiles renders tracks into Mapbox Vector Tiles and MBTiles files.
+package tiles
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+const (
+	// Extent is the size of a tile in MVT coordinate units
+	Extent = 4096
+
+	// Buffer is how far, in MVT units, geometry is kept beyond the tile
+	// edge so that line joins render without seams
+	Buffer = 64
+
+	// MaxZoom is the deepest zoom level an Index produces by default
+	MaxZoom = 16
+
+	// maxLatitude is the limit of the Web Mercator projection
+	maxLatitude = 85.05112878
+)
+
+// TileID addresses a tile in the XYZ scheme used by web maps, with y
+// growing southwards
+type TileID struct {
+	Z, X, Y int
+}
+
+func (t TileID) String() string {
+	return fmt.Sprintf("%d/%d/%d", t.Z, t.X, t.Y)
+}
+
+// Valid reports whether the tile exists at its zoom level
+func (t TileID) Valid() bool {
+	if t.Z < 0 || t.Z > 30 {
+		return false
+	}
+	n := 1 << t.Z
+	return t.X >= 0 && t.X < n && t.Y >= 0 && t.Y < n
+}
+
+// parent returns the tile one zoom level up that contains t
+func (t TileID) parent() TileID {
+	return TileID{Z: t.Z - 1, X: t.X / 2, Y: t.Y / 2}
+}
+
+// children returns the four tiles one zoom level down
+func (t TileID) children() [4]TileID {
+	z, x, y := t.Z+1, t.X*2, t.Y*2
+	return [4]TileID{{z, x, y}, {z, x + 1, y}, {z, x, y + 1}, {z, x + 1, y + 1}}
+}
+
+// ParseTileID parses a "z/x/y" path. A trailing ".pbf" or ".mvt" on y is
+// accepted.
+func ParseTileID(z, x, y string) (TileID, error) {
+	y = strings.TrimSuffix(strings.TrimSuffix(y, ".pbf"), ".mvt")
+
+	var t TileID
+	var err error
+	if t.Z, err = strconv.Atoi(z); err != nil {
+		return TileID{}, fmt.Errorf("invalid zoom %q", z)
+	}
+	if t.X, err = strconv.Atoi(x); err != nil {
+		return TileID{}, fmt.Errorf("invalid tile column %q", x)
+	}
+	if t.Y, err = strconv.Atoi(y); err != nil {
+		return TileID{}, fmt.Errorf("invalid tile row %q", y)
+	}
+	if !t.Valid() {
+		return TileID{}, fmt.Errorf("tile %s does not exist", t)
+	}
+	return t, nil
+}
+
+// point is a position in Web Mercator normalized to [0, 1] on both axes,
+// with the origin in the north-west corner
+type point struct {
+	x, y float64
+}
+
+// project converts a WGS84 point to normalized Web Mercator
+func project(p converter.Point) point {
+	lat := math.Max(-maxLatitude, math.Min(maxLatitude, p.Lat))
+	sin := math.Sin(lat * math.Pi / 180)
+	return point{
+		x: p.Lon/360 + 0.5,
+		y: 0.5 - 0.25*math.Log((1+sin)/(1-sin))/math.Pi,
+	}
+}
+
+// unproject converts normalized Web Mercator back to WGS84
+func unproject(p point) converter.Point {
+	return converter.Point{
+		Lat: 360*math.Atan(math.Exp((1-2*p.y)*math.Pi))/math.Pi - 90,
+		Lon: (p.x - 0.5) * 360,
+	}
+}
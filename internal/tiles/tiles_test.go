@@ -0,0 +1,317 @@
+package tiles
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// vilniusTrack crosses the boundary between several z12 tiles
+var vilniusTrack = converter.Track{
+	Name: "A1 - Restriction 101",
+	Segments: [][]converter.Point{{
+		{Lat: 54.6872, Lon: 25.2797},
+		{Lat: 54.6900, Lon: 25.3500},
+		{Lat: 54.7300, Lon: 25.4000},
+	}},
+	Properties: map[string]any{"id": "r1", "iconValue": 50.0, "weight": 3.5, "active": true},
+}
+
+func TestProjectRoundTrip(t *testing.T) {
+	for _, p := range []converter.Point{{Lat: 54.6872, Lon: 25.2797}, {Lat: 0, Lon: 0}, {Lat: -33.9, Lon: -70.6}} {
+		got := unproject(project(p))
+		if math.Abs(got.Lat-p.Lat) > 1e-9 || math.Abs(got.Lon-p.Lon) > 1e-9 {
+			t.Errorf("Round trip of %+v gave %+v", p, got)
+		}
+	}
+
+	if p := project(converter.Point{Lat: 0, Lon: 0}); p.x != 0.5 || p.y != 0.5 {
+		t.Errorf("Expected (0,0) at the centre, got %+v", p)
+	}
+}
+
+func TestParseTileID(t *testing.T) {
+	id, err := ParseTileID("12", "2335", "1282.pbf")
+	if err != nil || id != (TileID{12, 2335, 1282}) {
+		t.Errorf("Unexpected result %v, %v", id, err)
+	}
+	for _, bad := range [][3]string{{"a", "0", "0"}, {"1", "2", "0"}, {"-1", "0", "0"}, {"1", "0", "x.pbf"}} {
+		if _, err := ParseTileID(bad[0], bad[1], bad[2]); err == nil {
+			t.Errorf("Expected an error for %v", bad)
+		}
+	}
+}
+
+func TestClipLine(t *testing.T) {
+	r := rect{minX: 0, minY: 0, maxX: 1, maxY: 1}
+
+	// Leaves the box through the right edge and comes back in
+	line := []point{{0.5, 0.5}, {1.5, 0.5}, {1.5, 0.8}, {0.5, 0.8}}
+	got := clipLine(line, r)
+	want := [][]point{
+		{{0.5, 0.5}, {1, 0.5}},
+		{{1, 0.8}, {0.5, 0.8}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d pieces, got %v", len(want), got)
+	}
+	for i := range want {
+		if len(got[i]) != len(want[i]) {
+			t.Fatalf("Piece %d: expected %v, got %v", i, want[i], got[i])
+		}
+		for j := range want[i] {
+			if got[i][j] != want[i][j] {
+				t.Errorf("Piece %d point %d: expected %v, got %v", i, j, want[i][j], got[i][j])
+			}
+		}
+	}
+
+	// Crosses the box without a vertex inside
+	got = clipLine([]point{{-1, 0.5}, {2, 0.5}}, r)
+	if len(got) != 1 || got[0][0] != (point{0, 0.5}) || got[0][1] != (point{1, 0.5}) {
+		t.Errorf("Expected the crossing to be cut to the box, got %v", got)
+	}
+
+	if got := clipLine([]point{{2, 2}, {3, 3}}, r); len(got) != 0 {
+		t.Errorf("Expected nothing for a line outside the box, got %v", got)
+	}
+}
+
+func TestIndexTile(t *testing.T) {
+	ix := NewIndex([]Layer{
+		{Name: "restrictions", Tracks: []converter.Track{vilniusTrack}},
+		{Name: "speed-control"},
+	}, 14)
+
+	start := project(vilniusTrack.Segments[0][0])
+	id := TileID{Z: 12, X: int(start.x * 4096), Y: int(start.y * 4096)}
+
+	data := ix.Tile(id)
+	if data == nil {
+		t.Fatalf("Expected tile %s to contain the track", id)
+	}
+	layers := decodeTile(t, data)
+	if len(layers) != 1 {
+		t.Fatalf("Expected only the non-empty layer, got %d layers", len(layers))
+	}
+	l := layers[0]
+	if l.name != "restrictions" || l.version != 2 || l.extent != Extent {
+		t.Errorf("Unexpected layer header: %+v", l)
+	}
+	if len(l.features) != 1 {
+		t.Fatalf("Expected 1 feature, got %d", len(l.features))
+	}
+
+	f := l.features[0]
+	if f.geomType != geomLineString {
+		t.Errorf("Expected a LineString, got type %d", f.geomType)
+	}
+	want := map[string]any{"name": vilniusTrack.Name, "id": "r1", "iconValue": int64(50), "weight": 3.5, "active": true}
+	for k, v := range want {
+		if f.properties[k] != v {
+			t.Errorf("Property %s: expected %v (%T), got %v (%T)", k, v, v, f.properties[k], f.properties[k])
+		}
+	}
+
+	// The first vertex lies inside the tile; everything stays within the buffer
+	first := f.points[0]
+	if first[0] < 0 || first[0] > Extent || first[1] < 0 || first[1] > Extent {
+		t.Errorf("First vertex %v should be inside the tile", first)
+	}
+	for _, p := range f.points {
+		if p[0] < -Buffer-1 || p[0] > Extent+Buffer+1 || p[1] < -Buffer-1 || p[1] > Extent+Buffer+1 {
+			t.Errorf("Vertex %v lies outside the buffered tile", p)
+		}
+	}
+
+	if ix.Tile(TileID{Z: 12, X: 0, Y: 0}) != nil {
+		t.Error("Expected a tile far away from the track to be empty")
+	}
+	if ix.Tile(TileID{Z: 15, X: id.X * 8, Y: id.Y * 8}) != nil {
+		t.Error("Expected no tiles beyond the index's MaxZoom")
+	}
+}
+
+func TestIndexWalkMatchesTile(t *testing.T) {
+	ix := NewIndex([]Layer{{Name: "restrictions", Tracks: []converter.Track{vilniusTrack}}}, 12)
+
+	perZoom := make(map[int]int)
+	err := ix.Walk(4, func(id TileID, data []byte) error {
+		perZoom[id.Z]++
+		if id.Z < 4 {
+			t.Errorf("Walk returned tile %s below the minimum zoom", id)
+		}
+		if !bytes.Equal(data, ix.Tile(id)) {
+			t.Errorf("Walk and Tile disagree on tile %s", id)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+
+	for z := 4; z <= 12; z++ {
+		if perZoom[z] == 0 {
+			t.Errorf("Expected at least one tile at zoom %d", z)
+		}
+	}
+	if perZoom[12] < 2 {
+		t.Errorf("Expected the track to span several z12 tiles, got %d", perZoom[12])
+	}
+
+	b := ix.Bounds()
+	if math.Abs(b.MinLon-25.2797) > 1e-6 || math.Abs(b.MaxLat-54.73) > 1e-6 {
+		t.Errorf("Unexpected bounds %+v", b)
+	}
+}
+
+// decodedLayer and decodedFeature are the parts of a tile checked by tests
+type decodedLayer struct {
+	version  uint64
+	name     string
+	extent   uint64
+	features []decodedFeature
+}
+
+type decodedFeature struct {
+	geomType   uint64
+	properties map[string]any
+	points     [][2]int64
+}
+
+// decodeTile is a minimal MVT reader for the subset written by encodeTile
+func decodeTile(t *testing.T, data []byte) []decodedLayer {
+	t.Helper()
+	var layers []decodedLayer
+	readFields(t, data, func(field int, v uint64, b []byte) {
+		if field == tileLayers {
+			layers = append(layers, decodeLayer(t, b))
+		}
+	})
+	return layers
+}
+
+func decodeLayer(t *testing.T, data []byte) decodedLayer {
+	var (
+		l      decodedLayer
+		keys   []string
+		values []any
+		raw    [][]byte
+	)
+	readFields(t, data, func(field int, v uint64, b []byte) {
+		switch field {
+		case layerVersion:
+			l.version = v
+		case layerName:
+			l.name = string(b)
+		case layerExtent:
+			l.extent = v
+		case layerKeys:
+			keys = append(keys, string(b))
+		case layerValues:
+			readFields(t, b, func(field int, v uint64, b []byte) {
+				switch field {
+				case valueString:
+					values = append(values, string(b))
+				case valueDouble:
+					values = append(values, math.Float64frombits(v))
+				case valueSint:
+					values = append(values, int64(v>>1)^-int64(v&1))
+				case valueBool:
+					values = append(values, v == 1)
+				}
+			})
+		case layerFeatures:
+			raw = append(raw, b)
+		}
+	})
+
+	for _, b := range raw {
+		f := decodedFeature{properties: make(map[string]any)}
+		readFields(t, b, func(field int, v uint64, b []byte) {
+			switch field {
+			case featureType:
+				f.geomType = v
+			case featureTags:
+				tags := readPacked(t, b)
+				for i := 0; i+1 < len(tags); i += 2 {
+					f.properties[keys[tags[i]]] = values[tags[i+1]]
+				}
+			case featureGeometry:
+				f.points = decodeGeometry(readPacked(t, b))
+			}
+		})
+		l.features = append(l.features, f)
+	}
+	return l
+}
+
+func decodeGeometry(cmds []uint64) [][2]int64 {
+	var (
+		points [][2]int64
+		x, y   int64
+	)
+	for i := 0; i < len(cmds); {
+		count := int(cmds[i] >> 3)
+		i++
+		for j := 0; j < count && i+1 < len(cmds); j++ {
+			x += int64(cmds[i]>>1) ^ -int64(cmds[i]&1)
+			y += int64(cmds[i+1]>>1) ^ -int64(cmds[i+1]&1)
+			points = append(points, [2]int64{x, y})
+			i += 2
+		}
+	}
+	return points
+}
+
+func readFields(t *testing.T, data []byte, fn func(field int, v uint64, b []byte)) {
+	t.Helper()
+	for len(data) > 0 {
+		key, n := readVarint(t, data)
+		data = data[n:]
+		switch key & 7 {
+		case wireVarint:
+			v, n := readVarint(t, data)
+			data = data[n:]
+			fn(int(key>>3), v, nil)
+		case 1:
+			var v uint64
+			for i := 0; i < 8; i++ {
+				v |= uint64(data[i]) << (8 * i)
+			}
+			data = data[8:]
+			fn(int(key>>3), v, nil)
+		case wireBytes:
+			size, n := readVarint(t, data)
+			data = data[n:]
+			fn(int(key>>3), 0, data[:size])
+			data = data[size:]
+		default:
+			t.Fatalf("Unexpected wire type %d", key&7)
+		}
+	}
+}
+
+func readPacked(t *testing.T, data []byte) []uint64 {
+	var out []uint64
+	for len(data) > 0 {
+		v, n := readVarint(t, data)
+		out = append(out, v)
+		data = data[n:]
+	}
+	return out
+}
+
+func readVarint(t *testing.T, data []byte) (uint64, int) {
+	var v uint64
+	for i, b := range data {
+		v |= uint64(b&0x7f) << (7 * i)
+		if b < 0x80 {
+			return v, i + 1
+		}
+	}
+	t.Fatal("Truncated varint")
+	return 0, 0
+}
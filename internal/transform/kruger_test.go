@@ -0,0 +1,131 @@
+package transform
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+// krugerGridFile holds the grid computed with krugerInverse
+const krugerGridFile = "../../testdata/lks94_kruger_grid.csv"
+
+var updateReference = flag.Bool("update-reference", false, "Regenerate "+krugerGridFile+" from the Krüger series")
+
+// krugerGrid reads the computed grid. It checks the implementation against
+// an independent computation of the same projection, but not the projection
+// parameters, which only published control points can.
+func krugerGrid(tb testing.TB) []ReferencePoint {
+	tb.Helper()
+	f, err := os.Open(krugerGridFile)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	points, err := ReadReferencePoints(f)
+	if err != nil {
+		tb.Fatalf("Failed to read %s: %v", krugerGridFile, err)
+	}
+	return points
+}
+
+// krugerInverse converts LKS-94 to ETRS89 latitude and longitude in degrees
+// with Karney's 6th order Krüger series and an exact conformal latitude
+// inverse (C. F. F. Karney, "Transverse Mercator with an accuracy of a few
+// nanometers", J. Geodesy 85, 2011). It shares no code with the wgs84
+// package, which uses a 4th order series, and serves as an independent
+// reference.
+func krugerInverse(easting, northing float64) (lat, lon float64) {
+	const (
+		a          = 6378137
+		f          = 1 / 298.257222101
+		k0         = 0.9998
+		lon0       = 24
+		falseEast  = 500000
+		falseNorth = 0
+	)
+	n := f / (2 - f)
+	e2 := f * (2 - f)
+	e := math.Sqrt(e2)
+	n2, n3, n4, n5, n6 := n*n, n*n*n, math.Pow(n, 4), math.Pow(n, 5), math.Pow(n, 6)
+	A := a / (1 + n) * (1 + n2/4 + n4/64 + n6/256)
+	beta := [6]float64{
+		n/2 - 2.0/3*n2 + 37.0/96*n3 - 1.0/360*n4 - 81.0/512*n5 + 96199.0/604800*n6,
+		1.0/48*n2 + 1.0/15*n3 - 437.0/1440*n4 + 46.0/105*n5 - 1118711.0/3870720*n6,
+		17.0/480*n3 - 37.0/840*n4 - 209.0/4480*n5 + 5569.0/90720*n6,
+		4397.0/161280*n4 - 11.0/504*n5 - 830251.0/7257600*n6,
+		4583.0/161280*n5 - 108847.0/3991680*n6,
+		20648693.0 / 638668800 * n6,
+	}
+
+	xi := (northing - falseNorth) / (k0 * A)
+	eta := (easting - falseEast) / (k0 * A)
+	xiP, etaP := xi, eta
+	for j := 1; j <= 6; j++ {
+		b, k := beta[j-1], float64(2*j)
+		xiP -= b * math.Sin(k*xi) * math.Cosh(k*eta)
+		etaP -= b * math.Cos(k*xi) * math.Sinh(k*eta)
+	}
+
+	tauP := math.Sin(xiP) / math.Hypot(math.Sinh(etaP), math.Cos(xiP))
+	lambda := math.Atan2(math.Sinh(etaP), math.Cos(xiP))
+
+	// Newton's method for the latitude whose conformal latitude is tauP
+	tau := tauP
+	for range 10 {
+		sigma := math.Sinh(e * math.Atanh(e*tau/math.Sqrt(1+tau*tau)))
+		tauI := tau*math.Sqrt(1+sigma*sigma) - sigma*math.Sqrt(1+tau*tau)
+		d := (tauP - tauI) / ((1 - e2) * math.Sqrt(1+tauI*tauI) * math.Sqrt(1+tau*tau) / (1 + (1-e2)*tau*tau))
+		tau += d
+		if math.Abs(d) < 1e-15 {
+			break
+		}
+	}
+	return math.Atan(tau) * 180 / math.Pi, lon0 + lambda*180/math.Pi
+}
+
+func TestKrugerFalseOrigin(t *testing.T) {
+	lat, lon := krugerInverse(500000, 0)
+	if math.Abs(lat) > 1e-12 || math.Abs(lon-24) > 1e-12 {
+		t.Errorf("Expected the false origin at [0, 24], got [%v, %v]", lat, lon)
+	}
+}
+
+// TestKrugerGrid keeps the grid in line with the series, and regenerates it
+// with -update-reference
+func TestKrugerGrid(t *testing.T) {
+	if *updateReference {
+		writeKrugerGrid(t)
+	}
+	for _, p := range krugerGrid(t) {
+		if p.Source != SourceKruger {
+			continue
+		}
+		lat, lon := krugerInverse(p.Easting, p.Northing)
+		if d := errorMM(lat, lon, p.Lat, p.Lon); d > 0.01 {
+			t.Errorf("%s: table differs from the series by %.3f mm", p.Name, d)
+		}
+	}
+}
+
+// writeKrugerGrid writes the definitional points and a grid covering
+// Lithuania every 20 km
+func writeKrugerGrid(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("# Computed points for the self-consistency test of the LKS-94 to WGS84\n")
+	b.WriteString("# transformation; not control points, see docs/transform.md.\n")
+	b.WriteString("# Generated by go test ./internal/transform -run TestKrugerGrid -update-reference\n")
+	b.WriteString("name,source,easting,northing,lat,lon\n")
+	fmt.Fprintf(&b, "false origin,%s,500000,0,0,24\n", SourceDefinition)
+	for northing := 5975000.0; northing <= 6255000; northing += 20000 {
+		for easting := 300000.0; easting <= 680000; easting += 20000 {
+			lat, lon := krugerInverse(easting, northing)
+			fmt.Fprintf(&b, "grid %.0f %.0f,%s,%.0f,%.0f,%.10f,%.10f\n", easting, northing, SourceKruger, easting, northing, lat, lon)
+		}
+	}
+	if err := os.WriteFile(krugerGridFile, []byte(b.String()), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", krugerGridFile, err)
+	}
+}
@@ -0,0 +1,124 @@
+package transform
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Reference point sources
+const (
+	// SourceDefinition marks points fixed by the definition of LKS-94,
+	// such as the false origin
+	SourceDefinition = "definition"
+
+	// SourceKruger marks points computed with an independent 6th order
+	// Krüger series, such as the grid of kruger_test.go
+	SourceKruger = "kruger6"
+)
+
+// Computed reports whether a point was computed rather than surveyed.
+// Computed points check the implementation against the definition of the
+// projection; only published control points can catch a wrong parameter.
+func (p ReferencePoint) Computed() bool {
+	return p.Source == SourceDefinition || p.Source == SourceKruger
+}
+
+// ToleranceMM is the largest error accepted at a reference point. The
+// inverse projection of the wgs84 package is about 3 mm off its own forward
+// projection; see docs/transform.md.
+const ToleranceMM = 5
+
+// ReferencePoint is an LKS-94 coordinate with its known WGS84 position
+type ReferencePoint struct {
+	Name     string
+	Source   string
+	Easting  float64
+	Northing float64
+	Lat      float64
+	Lon      float64
+}
+
+// Accuracy summarizes the errors of LKS94ToWGS84 over reference points
+type Accuracy struct {
+	Points int
+	MaxMM  float64
+	RMSMM  float64
+
+	// Worst is the point with the largest error
+	Worst ReferencePoint
+}
+
+// ReadReferencePoints reads a CSV table with the columns name, source,
+// easting, northing, lat and lon. Lines starting with # and a header row
+// are skipped. Every point must name its source, e.g. the publication of a
+// control point.
+func ReadReferencePoints(r io.Reader) ([]ReferencePoint, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = 6
+
+	var points []ReferencePoint
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return points, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == "name" {
+			continue
+		}
+
+		p := ReferencePoint{Name: record[0], Source: strings.TrimSpace(record[1])}
+		if p.Source == "" {
+			line, _ := cr.FieldPos(1)
+			return nil, fmt.Errorf("line %d: %q has no source", line, p.Name)
+		}
+		for i, v := range []*float64{&p.Easting, &p.Northing, &p.Lat, &p.Lon} {
+			if *v, err = strconv.ParseFloat(record[i+2], 64); err != nil {
+				line, _ := cr.FieldPos(i + 2)
+				return nil, fmt.Errorf("line %d: invalid number %q", line, record[i+2])
+			}
+		}
+		points = append(points, p)
+	}
+}
+
+// MeasureAccuracy transforms every point and measures the distance to its
+// known position
+func MeasureAccuracy(points []ReferencePoint) Accuracy {
+	acc := Accuracy{Points: len(points)}
+	var sum float64
+	for _, p := range points {
+		lat, lon := LKS94ToWGS84(p.Easting, p.Northing)
+		d := errorMM(lat, lon, p.Lat, p.Lon)
+		sum += d * d
+		if d > acc.MaxMM || acc.Worst.Name == "" {
+			acc.MaxMM, acc.Worst = d, p
+		}
+	}
+	if len(points) > 0 {
+		acc.RMSMM = math.Sqrt(sum / float64(len(points)))
+	}
+	return acc
+}
+
+// errorMM returns the distance between two nearby positions in millimetres,
+// using the radii of curvature of the GRS80 ellipsoid at the second one
+func errorMM(lat1, lon1, lat2, lon2 float64) float64 {
+	const a, f = 6378137, 1 / 298.257222101
+	e2 := f * (2 - f)
+	phi := lat2 * math.Pi / 180
+	w := 1 - e2*math.Sin(phi)*math.Sin(phi)
+	m := a * (1 - e2) / math.Pow(w, 1.5)
+	n := a / math.Sqrt(w)
+
+	dy := (lat1 - lat2) * math.Pi / 180 * m
+	dx := (lon1 - lon2) * math.Pi / 180 * n * math.Cos(phi)
+	return math.Hypot(dx, dy) * 1000
+}
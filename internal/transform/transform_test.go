@@ -1,6 +1,7 @@
 package transform
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -126,6 +127,54 @@ func TestReturnValueOrder(t *testing.T) {
 	t.Logf("✅ Return value order correct: latitude=%.6f, longitude=%.6f", lat, lon)
 }
 
+// TestKrugerGridError checks the implementation against the computed grid;
+// it is a self-consistency test, not an accuracy suite
+func TestKrugerGridError(t *testing.T) {
+	points := krugerGrid(t)
+	if len(points) < 300 {
+		t.Fatalf("Expected at least 300 reference points, got %d", len(points))
+	}
+
+	acc := MeasureAccuracy(points)
+	t.Logf("%d points: max %.3f mm (%s), RMS %.3f mm", acc.Points, acc.MaxMM, acc.Worst.Name, acc.RMSMM)
+	if acc.MaxMM > ToleranceMM {
+		t.Errorf("❌ Max error %.3f mm at %s exceeds %d mm", acc.MaxMM, acc.Worst.Name, ToleranceMM)
+	}
+}
+
+func TestReadReferencePoints(t *testing.T) {
+	points, err := ReadReferencePoints(strings.NewReader("# comment\nname,source,easting,northing,lat,lon\norigin,definition,500000,0,0,24\n"))
+	if err != nil {
+		t.Fatalf("Failed to read points: %v", err)
+	}
+	if len(points) != 1 || points[0] != (ReferencePoint{Name: "origin", Source: SourceDefinition, Easting: 500000, Lon: 24}) {
+		t.Errorf("Unexpected points: %+v", points)
+	}
+
+	if _, err := ReadReferencePoints(strings.NewReader("origin,definition,x,0,0,24\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected an error naming line 1, got %v", err)
+	}
+	if _, err := ReadReferencePoints(strings.NewReader("# uncited\npillar, ,500000,6100000,55,24\n")); err == nil || !strings.Contains(err.Error(), `line 2: "pillar" has no source`) {
+		t.Errorf("Expected an error for a point without source, got %v", err)
+	}
+	if !points[0].Computed() || (ReferencePoint{Source: "published survey"}).Computed() {
+		t.Error("Expected only definition and kruger6 points to be computed")
+	}
+}
+
+// BenchmarkLKS94ToWGS84 reports the speed of the transformation and its
+// max and RMS error over the computed grid
+func BenchmarkLKS94ToWGS84(b *testing.B) {
+	points := krugerGrid(b)
+	for i := 0; i < b.N; i++ {
+		p := points[i%len(points)]
+		LKS94ToWGS84(p.Easting, p.Northing)
+	}
+	acc := MeasureAccuracy(points)
+	b.ReportMetric(acc.MaxMM, "max-mm")
+	b.ReportMetric(acc.RMSMM, "rms-mm")
+}
+
 // Helper functions
 
 func isInLithuania(lat, lon float64) bool {
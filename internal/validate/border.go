@@ -0,0 +1,80 @@
+package validate
+
+import (
+	"math"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// border is the outline of Lithuania as [lon, lat] vertices, clockwise from
+// the Latvian border on the Baltic coast. It is simplified to within a few
+// kilometres of the real border, so checks allow a buffer around it. The
+// Curonian Spit and the Lithuanian part of the lagoon are included.
+var border = [][2]float64{
+	// Latvia
+	{21.06, 56.07}, {21.23, 56.17}, {21.59, 56.32}, {22.00, 56.42},
+	{22.60, 56.39}, {23.25, 56.38}, {23.75, 56.37}, {24.00, 56.35},
+	{24.45, 56.26}, {24.90, 56.45}, {25.10, 56.20}, {25.60, 56.15},
+	{26.05, 55.95}, {26.63, 55.68},
+	// Belarus
+	{26.84, 55.67}, {26.72, 55.50}, {26.58, 55.16}, {26.20, 55.00},
+	{25.85, 54.92}, {25.78, 54.80}, {25.78, 54.58}, {25.67, 54.45},
+	{25.55, 54.30}, {25.20, 54.25}, {24.80, 54.10}, {24.45, 53.91},
+	{24.05, 53.92}, {23.49, 53.94},
+	// Poland
+	{23.50, 54.05}, {23.42, 54.19}, {23.10, 54.31}, {22.79, 54.36},
+	// Russia (Kaliningrad), along the Neman and across the lagoon
+	{22.65, 54.58}, {22.74, 54.75}, {22.76, 54.86}, {22.30, 55.02},
+	{21.88, 55.09}, {21.40, 55.20}, {21.26, 55.25}, {20.95, 55.28},
+	// Curonian Spit and the coast
+	{21.00, 55.45}, {21.09, 55.70}, {21.09, 55.75}, {21.05, 55.92},
+}
+
+// InLithuania reports whether p lies within the simplified border
+func InLithuania(p converter.Point) bool {
+	inside := false
+	for i, j := 0, len(border)-1; i < len(border); j, i = i, i+1 {
+		a, b := border[i], border[j]
+		if (a[1] > p.Lat) != (b[1] > p.Lat) &&
+			p.Lon < (b[0]-a[0])*(p.Lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// BorderDistance returns how far p lies from the simplified border in
+// metres, 0 inside it
+func BorderDistance(p converter.Point) float64 {
+	if InLithuania(p) {
+		return 0
+	}
+	nearest := math.Inf(1)
+	for i, j := 0, len(border)-1; i < len(border); j, i = i, i+1 {
+		a := converter.Point{Lat: border[j][1], Lon: border[j][0]}
+		b := converter.Point{Lat: border[i][1], Lon: border[i][0]}
+		nearest = math.Min(nearest, edgeDistance(p, a, b))
+	}
+	return nearest
+}
+
+// edgeDistance returns the distance from p to the edge a-b in metres, on a
+// local equirectangular plane around p
+func edgeDistance(p, a, b converter.Point) float64 {
+	ax, ay := project(a, p)
+	bx, by := project(b, p)
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+	}
+	return math.Hypot(ax+t*dx, ay+t*dy)
+}
+
+// project returns the position of p in metres relative to origin
+func project(p, origin converter.Point) (x, y float64) {
+	const metresPerDegree = earthRadius * math.Pi / 180
+	x = (p.Lon - origin.Lon) * metresPerDegree * math.Cos(origin.Lat*math.Pi/180)
+	y = (p.Lat - origin.Lat) * metresPerDegree
+	return x, y
+}
@@ -0,0 +1,46 @@
+package validate
+
+import (
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+func TestInLithuania(t *testing.T) {
+	tests := []struct {
+		name   string
+		point  converter.Point
+		inside bool
+	}{
+		{"Vilnius", converter.Point{Lat: 54.687, Lon: 25.280}, true},
+		{"Klaipėda", converter.Point{Lat: 55.703, Lon: 21.144}, true},
+		{"Nida", converter.Point{Lat: 55.303, Lon: 21.005}, true},
+		{"Druskininkai", converter.Point{Lat: 54.017, Lon: 23.971}, true},
+		{"Zarasai", converter.Point{Lat: 55.730, Lon: 26.250}, true},
+		{"Biržai", converter.Point{Lat: 56.200, Lon: 24.750}, true},
+		{"Riga", converter.Point{Lat: 56.950, Lon: 24.110}, false},
+		{"Daugavpils", converter.Point{Lat: 55.870, Lon: 26.530}, false},
+		{"Grodno", converter.Point{Lat: 53.680, Lon: 23.830}, false},
+		{"Sejny", converter.Point{Lat: 54.110, Lon: 23.350}, false},
+		{"Kaliningrad", converter.Point{Lat: 54.710, Lon: 20.510}, false},
+		{"Abu Dhabi", converter.Point{Lat: 24.450, Lon: 54.380}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InLithuania(tt.point); got != tt.inside {
+				t.Errorf("Expected InLithuania = %v, got %v", tt.inside, got)
+			}
+		})
+	}
+}
+
+func TestBorderDistance(t *testing.T) {
+	if d := BorderDistance(converter.Point{Lat: 54.687, Lon: 25.280}); d != 0 {
+		t.Errorf("Expected 0 for Vilnius, got %.0f m", d)
+	}
+	// Riga lies about 60-70 km north of the border
+	if d := BorderDistance(converter.Point{Lat: 56.950, Lon: 24.110}); d < 50000 || d > 80000 {
+		t.Errorf("Expected Riga 50-80 km from the border, got %.0f m", d)
+	}
+}
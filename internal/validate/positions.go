@@ -0,0 +1,75 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// CheckPosition compares vertices against expected positions
+const CheckPosition = "position"
+
+// Position is the expected WGS84 position of a vertex of a feature, as in
+// testdata/known_positions.json. Easting and Northing record the LKS-94
+// input for reference.
+type Position struct {
+	Feature  string  `json:"feature"`
+	Segment  int     `json:"segment"`
+	Vertex   int     `json:"vertex"`
+	Easting  float64 `json:"easting,omitempty"`
+	Northing float64 `json:"northing,omitempty"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+}
+
+// LoadPositions reads a JSON array of positions
+func LoadPositions(path string) ([]Position, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var positions []Position
+	if err := json.Unmarshal(content, &positions); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return positions, nil
+}
+
+// CheckPositions reports every position whose vertex is missing from
+// tracks or lies further than tolerance metres from where it is expected.
+// Tracks are matched by FeatureID, so GeoJSON keeps the IDs needed.
+func CheckPositions(name string, positions []Position, tracks []converter.Track, tolerance float64) *Report {
+	byFeature := make(map[string]converter.Track, len(tracks))
+	for _, track := range tracks {
+		id := FeatureID(track)
+		if _, ok := byFeature[id]; !ok {
+			byFeature[id] = track
+		}
+	}
+
+	r := &Report{Name: name, Checks: []string{CheckPosition}, Tracks: len(tracks), Points: len(positions), Issues: []Issue{}}
+	for _, pos := range positions {
+		want := converter.Point{Lat: pos.Lat, Lon: pos.Lon}
+		issue := newIssue(CheckPosition, pos.Feature, pos.Segment, pos.Vertex, "")
+
+		track, ok := byFeature[pos.Feature]
+		switch {
+		case !ok:
+			issue.Message = "feature not found"
+		case pos.Segment >= len(track.Segments) || pos.Vertex >= len(track.Segments[pos.Segment]):
+			issue.Message = "vertex not found"
+		default:
+			got := track.Segments[pos.Segment][pos.Vertex]
+			d := converter.Distance(got, want)
+			if d <= tolerance {
+				r.Inside++
+				continue
+			}
+			issue.Message = fmt.Sprintf("[%.7f, %.7f] is %.3f m from the expected [%.7f, %.7f]", got.Lat, got.Lon, d, want.Lat, want.Lon)
+		}
+		r.Issues = append(r.Issues, issue)
+	}
+	return r
+}
@@ -0,0 +1,62 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+)
+
+func TestKnownPositions(t *testing.T) {
+	positions, err := LoadPositions("../../testdata/known_positions.json")
+	if err != nil {
+		t.Fatalf("Failed to load positions: %v", err)
+	}
+
+	var tracks []converter.Track
+	for track, err := range converter.EALTracks(data.EALFeaturesFromFiles([]string{"../../testdata/eal_known_coords.json"})) {
+		if err != nil {
+			t.Fatalf("Failed to convert EAL fixture: %v", err)
+		}
+		tracks = append(tracks, track)
+	}
+	for track, err := range converter.ArcGISTracks(data.ArcGISFeaturesFromFiles([]string{"../../testdata/arcgis_known_coords.json"})) {
+		if err != nil {
+			t.Fatalf("Failed to convert ArcGIS fixture: %v", err)
+		}
+		tracks = append(tracks, track)
+	}
+
+	r := CheckPositions("positions", positions, tracks, 0.01)
+	if len(r.Issues) != 0 {
+		t.Errorf("Expected every position within 1 cm, got %v", r.Issues)
+	}
+	if r.Inside != len(positions) || r.Points != len(positions) {
+		t.Errorf("Expected %d positions matched, got %d of %d", len(positions), r.Inside, r.Points)
+	}
+}
+
+func TestCheckPositionsMismatch(t *testing.T) {
+	tracks := []converter.Track{track("TR:1", road)}
+	positions := []Position{
+		{Feature: "TR:1", Vertex: 1, Lat: road[1].Lat, Lon: road[1].Lon},
+		{Feature: "TR:1", Vertex: 2, Lat: road[2].Lat + 0.0001, Lon: road[2].Lon},
+		{Feature: "TR:1", Vertex: 9, Lat: road[0].Lat, Lon: road[0].Lon},
+		{Feature: "TR:2", Lat: road[0].Lat, Lon: road[0].Lon},
+	}
+
+	r := CheckPositions("positions", positions, tracks, 1)
+	if r.Inside != 1 || r.Passed(false) {
+		t.Fatalf("Expected one matching position and a failure, got %+v", r)
+	}
+	want := []string{"is 11.1", "vertex not found", "feature not found"}
+	if len(r.Issues) != len(want) {
+		t.Fatalf("Expected %d issues, got %v", len(want), r.Issues)
+	}
+	for i, issue := range r.Issues {
+		if issue.Check != CheckPosition || issue.Severity != SeverityError || !strings.Contains(issue.Message, want[i]) {
+			t.Errorf("Unexpected issue %d: %+v", i, issue)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteJSON writes reports as an indented JSON array
+func WriteJSON(w io.Writer, reports []*Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes reports as JUnit XML for CI systems: a test suite per
+// report and a test case per check, failing with the issues of the check.
+// Warnings fail only if strict; otherwise they are listed as output.
+func WriteJUnit(w io.Writer, reports []*Report, strict bool) error {
+	var doc junitSuites
+	for _, r := range reports {
+		suite := junitSuite{Name: r.Name}
+		for _, check := range r.Checks {
+			c := junitCase{Name: check, ClassName: r.Name}
+
+			var failures, output []string
+			for _, issue := range r.Issues {
+				if issue.Check != check {
+					continue
+				}
+				if issue.Severity == SeverityError || strict {
+					failures = append(failures, issue.String())
+				} else {
+					output = append(output, issue.String())
+				}
+			}
+			if len(failures) > 0 {
+				c.Failure = &junitFailure{
+					Message: fmt.Sprintf("%d issues", len(failures)),
+					Text:    strings.Join(failures, "\n"),
+				}
+				suite.Failures++
+			}
+			c.SystemOut = strings.Join(output, "\n")
+
+			suite.Cases = append(suite.Cases, c)
+			suite.Tests++
+		}
+		doc.Suites = append(doc.Suites, suite)
+		doc.Tests += suite.Tests
+		doc.Failures += suite.Failures
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,97 @@
+package validate
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func testReports() []*Report {
+	v := New("restrictions", DefaultOptions())
+	v.Track(track("TR:1", road))
+	v.Track(track("TR:2", road))
+	return []*Report{v.Report()}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		var buf strings.Builder
+		if err := WriteJUnit(&buf, testReports(), strict); err != nil {
+			t.Fatalf("Failed to write JUnit: %v", err)
+		}
+
+		var doc junitSuites
+		if err := xml.Unmarshal([]byte(buf.String()), &doc); err != nil {
+			t.Fatalf("Failed to parse JUnit: %v\n%s", err, buf.String())
+		}
+		if len(doc.Suites) != 1 || doc.Tests != len(trackChecks) {
+			t.Fatalf("Expected one suite with %d tests, got %+v", len(trackChecks), doc)
+		}
+
+		var duplicate junitCase
+		for _, c := range doc.Suites[0].Cases {
+			if c.Name == CheckDuplicateSegment {
+				duplicate = c
+			}
+		}
+		// The duplicate segment is a warning: output normally, a failure if strict
+		if strict {
+			if doc.Failures != 1 || duplicate.Failure == nil || !strings.Contains(duplicate.Failure.Text, "TR:2") {
+				t.Errorf("Expected the duplicate segment to fail in strict mode, got %+v", duplicate)
+			}
+		} else if doc.Failures != 0 || !strings.Contains(duplicate.SystemOut, "TR:2") {
+			t.Errorf("Expected the duplicate segment as output, got %+v", duplicate)
+		}
+	}
+}
+
+func TestWriteJUnitFailedStep(t *testing.T) {
+	v := New("restrictions", DefaultOptions())
+	v.Fail(CheckConversion, errors.New("upstream closed the connection"))
+
+	var buf strings.Builder
+	if err := WriteJUnit(&buf, []*Report{v.Report()}, false); err != nil {
+		t.Fatalf("Failed to write JUnit: %v", err)
+	}
+	var doc junitSuites
+	if err := xml.Unmarshal([]byte(buf.String()), &doc); err != nil {
+		t.Fatalf("Failed to parse JUnit: %v\n%s", err, buf.String())
+	}
+
+	if r := v.Report(); len(r.Issues) != 1 {
+		t.Errorf("Expected only the conversion issue, got %+v", r.Issues)
+	}
+
+	var conversion *junitCase
+	for i, c := range doc.Suites[0].Cases {
+		if c.Name == CheckConversion {
+			conversion = &doc.Suites[0].Cases[i]
+		}
+	}
+	if conversion == nil || conversion.Failure == nil || !strings.Contains(conversion.Failure.Text, "upstream closed the connection") {
+		t.Errorf("Expected a failed conversion case, got %+v", doc.Suites[0].Cases)
+	}
+	if slices.Contains(trackChecks, CheckConversion) {
+		t.Error("Fail must not change the checks of other reports")
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf strings.Builder
+	if err := WriteJSON(&buf, testReports()); err != nil {
+		t.Fatalf("Failed to write JSON: %v", err)
+	}
+	var reports []Report
+	if err := json.Unmarshal([]byte(buf.String()), &reports); err != nil {
+		t.Fatalf("Failed to parse JSON: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Name != "restrictions" || reports[0].Tracks != 2 || len(reports[0].Issues) != 1 {
+		t.Errorf("Unexpected reports: %+v", reports)
+	}
+	if issue := reports[0].Issues[0]; issue.Check != CheckDuplicateSegment || issue.Feature != "TR:2" {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+}
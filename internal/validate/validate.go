@@ -0,0 +1,411 @@
+// Package validate checks the geometry of converted features: that it lies
+// in Lithuania and has no jumps, degenerate or duplicate segments,
+// self-intersections, invalid numbers or swapped axes. Problems are
+// collected into a report naming the affected features.
+package validate
+
+import (
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+)
+
+// earthRadius is the mean Earth radius in metres
+const earthRadius = 6371008.8
+
+// Checks
+const (
+	CheckEmpty            = "empty"
+	CheckBorderShare      = "border_share"
+	CheckOutsideBorder    = "outside_border"
+	CheckSwappedAxes      = "swapped_axes"
+	CheckNotFinite        = "not_finite"
+	CheckShortCoordinate  = "short_coordinate"
+	CheckVertexJump       = "vertex_jump"
+	CheckZeroLength       = "zero_length"
+	CheckDuplicateSegment = "duplicate_segment"
+	CheckSelfIntersection = "self_intersection"
+	CheckConversion       = "conversion"
+	CheckRoundTrip        = "round_trip"
+)
+
+// Severity tells whether an issue fails validation
+type Severity string
+
+// Severities
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// severities maps each check to its severity. Errors indicate a broken
+// conversion; warnings are upstream data worth a look.
+var severities = map[string]Severity{
+	CheckEmpty:            SeverityError,
+	CheckBorderShare:      SeverityError,
+	CheckSwappedAxes:      SeverityError,
+	CheckNotFinite:        SeverityError,
+	CheckVertexJump:       SeverityError,
+	CheckPosition:         SeverityError,
+	CheckConversion:       SeverityError,
+	CheckRoundTrip:        SeverityError,
+	CheckOutsideBorder:    SeverityWarning,
+	CheckShortCoordinate:  SeverityWarning,
+	CheckZeroLength:       SeverityWarning,
+	CheckDuplicateSegment: SeverityWarning,
+	CheckSelfIntersection: SeverityWarning,
+}
+
+// LKS-94 ranges covering Lithuania with a margin, used to recognize raw
+// coordinates given as [northing, easting]
+const (
+	minEasting, maxEasting   = 250000, 750000
+	minNorthing, maxNorthing = 5900000, 6300000
+)
+
+// Options configures the checks
+type Options struct {
+	// MaxJump is the longest distance between consecutive vertices in
+	// metres; 0 disables the check
+	MaxJump float64
+
+	// BorderBuffer is how far outside the simplified border in metres a
+	// vertex still counts as in Lithuania
+	BorderBuffer float64
+
+	// MinInside is the percentage of vertices that must lie in Lithuania
+	MinInside float64
+}
+
+// DefaultOptions returns the options used by the verify command
+func DefaultOptions() Options {
+	return Options{MaxJump: 10000, BorderBuffer: 5000, MinInside: 90}
+}
+
+// Issue is a problem found in a feature. Consecutive occurrences of the
+// same check in the same feature are merged into one issue with a count;
+// Segment and Vertex locate the first of them.
+type Issue struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Feature  string   `json:"feature,omitempty"`
+	Segment  int      `json:"segment"`
+	Vertex   int      `json:"vertex"`
+	Count    int      `json:"count"`
+	Message  string   `json:"message"`
+}
+
+// String formats the issue for a terminal
+func (i Issue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[%s] %s", i.Severity, i.Check)
+	if i.Feature != "" {
+		fmt.Fprintf(&b, " %s segment %d vertex %d", i.Feature, i.Segment, i.Vertex)
+	}
+	fmt.Fprintf(&b, ": %s", i.Message)
+	if i.Count > 1 {
+		fmt.Fprintf(&b, " (%d times)", i.Count)
+	}
+	return b.String()
+}
+
+// trackChecks are the checks run by a Validator
+var trackChecks = []string{
+	CheckEmpty, CheckBorderShare, CheckSwappedAxes, CheckNotFinite, CheckVertexJump,
+	CheckOutsideBorder, CheckShortCoordinate, CheckZeroLength, CheckDuplicateSegment, CheckSelfIntersection,
+}
+
+// Report is the result of a validation. In a report of CheckPositions,
+// Points counts the positions and Inside those found within tolerance.
+type Report struct {
+	Name   string   `json:"name"`
+	Checks []string `json:"checks"`
+	Tracks int      `json:"tracks"`
+	Points int      `json:"points"`
+	Inside int      `json:"inside"`
+	Issues []Issue  `json:"issues"`
+}
+
+// InsidePercent returns the share of vertices in Lithuania
+func (r *Report) InsidePercent() float64 {
+	if r.Points == 0 {
+		return 0
+	}
+	return float64(r.Inside) / float64(r.Points) * 100
+}
+
+// Count returns the number of issues with the given severity
+func (r *Report) Count(severity Severity) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed reports whether the report has no errors and, if strict, no
+// warnings
+func (r *Report) Passed(strict bool) bool {
+	return r.Count(SeverityError) == 0 && (!strict || r.Count(SeverityWarning) == 0)
+}
+
+// Validator accumulates issues over raw features and converted tracks
+type Validator struct {
+	opts     Options
+	report   Report
+	segments map[uint64]string
+	failed   bool
+}
+
+// New returns a validator of the named dataset
+func New(name string, opts Options) *Validator {
+	return &Validator{
+		opts:     opts,
+		report:   Report{Name: name, Checks: trackChecks},
+		segments: make(map[uint64]string),
+	}
+}
+
+// Report returns the issues found so far, adding those about the dataset
+// as a whole
+func (v *Validator) Report() *Report {
+	r := v.report
+	r.Issues = append([]Issue{}, r.Issues...)
+	switch {
+	case v.failed:
+	case r.Tracks == 0:
+		r.Issues = append(r.Issues, newIssue(CheckEmpty, "", 0, 0, "no tracks found"))
+	case r.Points > 0 && r.InsidePercent() < v.opts.MinInside:
+		r.Issues = append(r.Issues, newIssue(CheckBorderShare, "", 0, 0,
+			fmt.Sprintf("only %.1f%% of coordinates are in Lithuania, expected at least %.0f%%", r.InsidePercent(), v.opts.MinInside)))
+	}
+	return &r
+}
+
+// Fail records err as a failure of check, a step of the pipeline such as
+// CheckConversion, and adds check to the checks of the report. The checks
+// of the dataset as a whole are then skipped, as it may be incomplete.
+func (v *Validator) Fail(check string, err error) {
+	v.failed = true
+	if !slices.Contains(v.report.Checks, check) {
+		v.report.Checks = append(slices.Clip(v.report.Checks), check)
+	}
+	v.report.Issues = append(v.report.Issues, newIssue(check, "", 0, 0, err.Error()))
+}
+
+// EALFeatures checks the raw coordinates of each restriction as the
+// features pass through
+func (v *Validator) EALFeatures(features iter.Seq2[data.EALFeature, error]) iter.Seq2[data.EALFeature, error] {
+	return func(yield func(data.EALFeature, error) bool) {
+		for feature, err := range features {
+			if err == nil {
+				for _, restriction := range feature.Restrictions {
+					v.Coordinates(restriction.ID, restriction.Lines.Paths)
+				}
+			}
+			if !yield(feature, err) {
+				return
+			}
+		}
+	}
+}
+
+// ArcGISFeatures checks the raw coordinates of each feature as the
+// features pass through
+func (v *Validator) ArcGISFeatures(features iter.Seq2[data.ArcGISFeature, error]) iter.Seq2[data.ArcGISFeature, error] {
+	return func(yield func(data.ArcGISFeature, error) bool) {
+		for feature, err := range features {
+			if err == nil {
+				v.Coordinates(FeatureID(converter.Track{Properties: feature.Attributes}), feature.Geometry.Paths)
+			}
+			if !yield(feature, err) {
+				return
+			}
+		}
+	}
+}
+
+// Coordinates checks raw LKS-94 paths of a feature for coordinates the
+// converter would skip or misplace
+func (v *Validator) Coordinates(feature string, paths [][][]float64) {
+	for s, path := range paths {
+		for i, coord := range path {
+			switch {
+			case len(coord) < 2:
+				v.add(CheckShortCoordinate, feature, s, i, fmt.Sprintf("coordinate %v has fewer than two values", coord))
+			case !finite(coord[0]) || !finite(coord[1]):
+				v.add(CheckNotFinite, feature, s, i, fmt.Sprintf("coordinate %v is not a finite number", coord))
+			case inRange(coord[0], minNorthing, maxNorthing) && inRange(coord[1], minEasting, maxEasting):
+				v.add(CheckSwappedAxes, feature, s, i, fmt.Sprintf("coordinate [%.2f, %.2f] is [northing, easting]", coord[0], coord[1]))
+			}
+		}
+	}
+}
+
+// Track checks the WGS84 geometry of a converted track
+func (v *Validator) Track(track converter.Track) {
+	v.report.Tracks++
+	feature := FeatureID(track)
+
+	for s, segment := range track.Segments {
+		for i, p := range segment {
+			v.report.Points++
+			v.checkPoint(feature, s, i, p)
+			if i > 0 {
+				v.checkStep(feature, s, i, segment[i-1], p)
+			}
+		}
+		if len(segment) > 1 {
+			v.checkDuplicate(feature, s, segment)
+			v.checkSelfIntersection(feature, s, segment)
+		}
+	}
+}
+
+func (v *Validator) checkPoint(feature string, s, i int, p converter.Point) {
+	if !finite(p.Lat) || !finite(p.Lon) {
+		v.add(CheckNotFinite, feature, s, i, fmt.Sprintf("coordinate [%v, %v] is not a finite number", p.Lat, p.Lon))
+		return
+	}
+	distance := BorderDistance(p)
+	if distance <= v.opts.BorderBuffer {
+		v.report.Inside++
+		return
+	}
+	// Swapped axes put Lithuanian coordinates near Abu Dhabi
+	if swapped := (converter.Point{Lat: p.Lon, Lon: p.Lat}); InLithuania(swapped) {
+		v.add(CheckSwappedAxes, feature, s, i, fmt.Sprintf("coordinate [%.6f, %.6f] lies in Lithuania only with latitude and longitude swapped", p.Lat, p.Lon))
+		return
+	}
+	v.add(CheckOutsideBorder, feature, s, i, fmt.Sprintf("coordinate [%.6f, %.6f] is %.1f km outside Lithuania", p.Lat, p.Lon, distance/1000))
+}
+
+func (v *Validator) checkStep(feature string, s, i int, prev, p converter.Point) {
+	if !finite(prev.Lat) || !finite(prev.Lon) || !finite(p.Lat) || !finite(p.Lon) {
+		return
+	}
+	if prev == p {
+		v.add(CheckZeroLength, feature, s, i, "vertex repeats the previous one")
+		return
+	}
+	if d := converter.Distance(prev, p); v.opts.MaxJump > 0 && d > v.opts.MaxJump {
+		v.add(CheckVertexJump, feature, s, i, fmt.Sprintf("%.1f km from the previous vertex", d/1000))
+	}
+}
+
+// checkDuplicate reports a segment with the same vertices, in the same
+// order, as one seen before
+func (v *Validator) checkDuplicate(feature string, s int, segment []converter.Point) {
+	h := fnv.New64a()
+	for _, p := range segment {
+		fmt.Fprintf(h, "%.7f,%.7f;", p.Lat, p.Lon)
+	}
+	key := h.Sum64()
+	if first, ok := v.segments[key]; ok {
+		v.add(CheckDuplicateSegment, feature, s, 0, fmt.Sprintf("segment duplicates one of %s", first))
+		return
+	}
+	v.segments[key] = fmt.Sprintf("%s segment %d", feature, s)
+}
+
+// checkSelfIntersection reports the first pair of non-adjacent edges of a
+// segment that cross or touch. Repeated vertices are reported by checkStep
+// and dropped here.
+func (v *Validator) checkSelfIntersection(feature string, s int, segment []converter.Point) {
+	// Vertex indexes refer to the segment without repeated vertices
+	var xy [][2]float64
+	var points []converter.Point
+	for i, p := range segment {
+		if i > 0 && p == segment[i-1] {
+			continue
+		}
+		x, y := project(p, segment[0])
+		xy = append(xy, [2]float64{x, y})
+		points = append(points, p)
+	}
+	if len(xy) < 4 {
+		return
+	}
+	closed := points[0] == points[len(points)-1]
+
+	for i := 0; i+1 < len(xy); i++ {
+		for j := i + 2; j+1 < len(xy); j++ {
+			if closed && i == 0 && j == len(xy)-2 {
+				continue
+			}
+			if intersects(xy[i], xy[i+1], xy[j], xy[j+1]) {
+				p := points[j]
+				v.add(CheckSelfIntersection, feature, s, i, fmt.Sprintf("edge %d crosses edge %d near [%.6f, %.6f]", i, j, p.Lat, p.Lon))
+				return
+			}
+		}
+	}
+}
+
+// add records an issue, merging it into the previous one of the same check
+// and feature
+func (v *Validator) add(check, feature string, s, i int, message string) {
+	if n := len(v.report.Issues); n > 0 {
+		last := &v.report.Issues[n-1]
+		if last.Check == check && last.Feature == feature {
+			last.Count++
+			return
+		}
+	}
+	v.report.Issues = append(v.report.Issues, newIssue(check, feature, s, i, message))
+}
+
+func newIssue(check, feature string, s, i int, message string) Issue {
+	return Issue{Check: check, Severity: severities[check], Feature: feature, Segment: s, Vertex: i, Count: 1, Message: message}
+}
+
+// FeatureID identifies the upstream feature of a track: the restriction ID,
+// the ArcGIS object ID or, for tracks read from GPX, the track name
+func FeatureID(track converter.Track) string {
+	if id, ok := track.Properties["id"].(string); ok && id != "" {
+		return id
+	}
+	for name, value := range track.Properties {
+		if strings.EqualFold(name, "objectid") && value != nil {
+			return fmt.Sprintf("objectid %v", value)
+		}
+	}
+	return track.Name
+}
+
+// intersects reports whether the edges a-b and c-d touch or cross
+func intersects(a, b, c, d [2]float64) bool {
+	d1, d2 := orientation(c, d, a), orientation(c, d, b)
+	d3, d4 := orientation(a, b, c), orientation(a, b, d)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onEdge(c, d, a)) || (d2 == 0 && onEdge(c, d, b)) ||
+		(d3 == 0 && onEdge(a, b, c)) || (d4 == 0 && onEdge(a, b, d))
+}
+
+func orientation(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// onEdge reports whether p, collinear with a-b, lies within the edge
+func onEdge(a, b, p [2]float64) bool {
+	return math.Min(a[0], b[0]) <= p[0] && p[0] <= math.Max(a[0], b[0]) &&
+		math.Min(a[1], b[1]) <= p[1] && p[1] <= math.Max(a[1], b[1])
+}
+
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+func inRange(f, min, max float64) bool {
+	return f >= min && f <= max
+}
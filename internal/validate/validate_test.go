@@ -0,0 +1,196 @@
+package validate
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+)
+
+// road is a short polyline east of Vilnius
+var road = []converter.Point{
+	{Lat: 54.6800, Lon: 25.3000},
+	{Lat: 54.6810, Lon: 25.3010},
+	{Lat: 54.6820, Lon: 25.3030},
+	{Lat: 54.6830, Lon: 25.3060},
+}
+
+func track(id string, segments ...[]converter.Point) converter.Track {
+	return converter.Track{Name: "Track " + id, Segments: segments, Properties: map[string]any{"id": id}}
+}
+
+// issues validates tracks and returns the issues by check
+func issues(t *testing.T, tracks ...converter.Track) map[string]Issue {
+	t.Helper()
+	v := New("test", DefaultOptions())
+	for _, track := range tracks {
+		v.Track(track)
+	}
+	found := make(map[string]Issue)
+	for _, issue := range v.Report().Issues {
+		if _, ok := found[issue.Check]; ok {
+			t.Errorf("Unexpected second %s issue: %s", issue.Check, issue)
+		}
+		found[issue.Check] = issue
+	}
+	return found
+}
+
+func TestValidTrack(t *testing.T) {
+	if found := issues(t, track("TR:1", road)); len(found) != 0 {
+		t.Errorf("Expected no issues, got %v", found)
+	}
+}
+
+func TestTrackChecks(t *testing.T) {
+	tests := []struct {
+		name     string
+		segment  []converter.Point
+		check    string
+		severity Severity
+		vertex   int
+	}{
+		{
+			name:     "swapped axes",
+			segment:  append([]converter.Point{{Lat: 25.3000, Lon: 54.6800}}, road...),
+			check:    CheckSwappedAxes,
+			severity: SeverityError,
+		},
+		{
+			name:     "outside border",
+			segment:  append(append([]converter.Point(nil), road...), converter.Point{Lat: 54.7100, Lon: 20.5100}),
+			check:    CheckOutsideBorder,
+			severity: SeverityWarning,
+			vertex:   4,
+		},
+		{
+			name:     "vertex jump",
+			segment:  append(append([]converter.Point(nil), road...), converter.Point{Lat: 55.7000, Lon: 21.1400}),
+			check:    CheckVertexJump,
+			severity: SeverityError,
+			vertex:   4,
+		},
+		{
+			name:     "not finite",
+			segment:  []converter.Point{road[0], {Lat: math.NaN(), Lon: 25.3}, road[1]},
+			check:    CheckNotFinite,
+			severity: SeverityError,
+			vertex:   1,
+		},
+		{
+			name:     "zero length",
+			segment:  []converter.Point{road[0], road[1], road[1], road[2]},
+			check:    CheckZeroLength,
+			severity: SeverityWarning,
+			vertex:   2,
+		},
+		{
+			name: "self intersection",
+			segment: []converter.Point{
+				{Lat: 54.680, Lon: 25.300},
+				{Lat: 54.690, Lon: 25.310},
+				{Lat: 54.690, Lon: 25.300},
+				{Lat: 54.680, Lon: 25.310},
+			},
+			check:    CheckSelfIntersection,
+			severity: SeverityWarning,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := issues(t, track("TR:1", tt.segment))
+			issue, ok := found[tt.check]
+			if !ok {
+				t.Fatalf("Expected a %s issue, got %v", tt.check, found)
+			}
+			if issue.Severity != tt.severity || issue.Feature != "TR:1" || issue.Vertex != tt.vertex {
+				t.Errorf("Unexpected issue: %+v", issue)
+			}
+		})
+	}
+}
+
+func TestTouchingRepeatedVertexIsNotSelfIntersection(t *testing.T) {
+	found := issues(t, track("TR:1", []converter.Point{road[0], road[1], road[1], road[2], road[3]}))
+	if _, ok := found[CheckSelfIntersection]; ok {
+		t.Errorf("Expected a repeated vertex not to count as a self-intersection: %v", found)
+	}
+}
+
+func TestDuplicateSegment(t *testing.T) {
+	found := issues(t, track("TR:1", road), track("TR:2", road))
+	issue, ok := found[CheckDuplicateSegment]
+	if !ok {
+		t.Fatalf("Expected a duplicate segment, got %v", found)
+	}
+	if issue.Feature != "TR:2" || issue.Message != "segment duplicates one of TR:1 segment 0" {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+}
+
+func TestMergedIssues(t *testing.T) {
+	kaliningrad := []converter.Point{{Lat: 54.710, Lon: 20.510}, {Lat: 54.711, Lon: 20.511}, {Lat: 54.712, Lon: 20.512}}
+	found := issues(t, track("TR:1", road), track("TR:1", road[:2]), track("TR:2", kaliningrad))
+	if issue := found[CheckOutsideBorder]; issue.Count != 3 || issue.Feature != "TR:2" {
+		t.Errorf("Expected one outside_border issue counted 3 times, got %+v", issue)
+	}
+	if _, ok := found[CheckBorderShare]; !ok {
+		t.Errorf("Expected a border_share error with 3 of 9 vertices outside, got %v", found)
+	}
+}
+
+func TestEmptyReport(t *testing.T) {
+	r := New("test", DefaultOptions()).Report()
+	if len(r.Issues) != 1 || r.Issues[0].Check != CheckEmpty || r.Count(SeverityError) != 1 {
+		t.Errorf("Expected a single empty error, got %+v", r.Issues)
+	}
+}
+
+func TestRawCoordinates(t *testing.T) {
+	layers := []data.EALLayer{{Features: []data.EALFeature{{
+		ID: "MJ:1",
+		Restrictions: []data.EALRestriction{{
+			ID: "TR:1",
+			Lines: data.EALLines{Paths: [][][]float64{{
+				{532186, 6190040},
+				{532189},
+				{6190080, 532218},
+			}}},
+		}},
+	}}}}
+
+	v := New("test", DefaultOptions())
+	for _, err := range converter.EALTracks(v.EALFeatures(data.EALFeaturesOf(layers))) {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	r := v.Report()
+	if len(r.Issues) < 2 {
+		t.Fatalf("Expected short and swapped coordinates, got %+v", r.Issues)
+	}
+	if issue := r.Issues[0]; issue.Check != CheckShortCoordinate || issue.Feature != "TR:1" || issue.Vertex != 1 {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+	if issue := r.Issues[1]; issue.Check != CheckSwappedAxes || issue.Vertex != 2 {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+}
+
+func TestFeatureID(t *testing.T) {
+	tests := []struct {
+		track converter.Track
+		want  string
+	}{
+		{converter.Track{Name: "a", Properties: map[string]any{"id": "TR:1"}}, "TR:1"},
+		{converter.Track{Name: "a", Properties: map[string]any{"OBJECTID": float64(452)}}, "objectid 452"},
+		{converter.Track{Name: "Speed Control Section 1"}, "Speed Control Section 1"},
+	}
+	for _, tt := range tests {
+		if got := FeatureID(tt.track); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package vehicle decides which road restrictions apply to a vehicle, by
+// comparing its dimensions with the limit in the restriction's iconValue.
+// Only the icon catalogue is trusted to tell which dimension a restriction
+// limits: a kind guessed from the feature name may belong to another
+// restriction of the same feature.
+package vehicle
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/icons"
+)
+
+// Vehicle classes
+const (
+	ClassCar   = "car"
+	ClassVan   = "van"
+	ClassTruck = "truck"
+	ClassBus   = "bus"
+)
+
+// Classes lists the valid vehicle classes
+var Classes = []string{ClassCar, ClassVan, ClassTruck, ClassBus}
+
+// Vehicle describes the vehicle restrictions are checked against. Zero
+// fields are unknown, and restrictions on them are always kept.
+type Vehicle struct {
+	Class string
+
+	// Weight is the actual weight in tonnes, AxleLoad the heaviest axle
+	Weight   float64
+	AxleLoad float64
+
+	// Height, Width and Length are in metres, including any load
+	Height float64
+	Width  float64
+	Length float64
+}
+
+// heavyClasses are the classes a heavy vehicle ban applies to
+var heavyClasses = []string{ClassTruck, ClassBus}
+
+// Parse reads a vehicle from a comma-separated list of key=value pairs:
+// class, weight, axle_load, height, width and length, the keys of the
+// configuration file, e.g. "class=truck,weight=40,height=4"
+func Parse(spec string) (Vehicle, error) {
+	var v Vehicle
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok {
+			return Vehicle{}, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		if key == "class" {
+			v.Class = value
+			continue
+		}
+
+		var field *float64
+		switch key {
+		case "weight":
+			field = &v.Weight
+		case "axle_load":
+			field = &v.AxleLoad
+		case "height":
+			field = &v.Height
+		case "width":
+			field = &v.Width
+		case "length":
+			field = &v.Length
+		default:
+			return Vehicle{}, fmt.Errorf("unknown key %q, expected class, weight, axle_load, height, width or length", key)
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return Vehicle{}, fmt.Errorf("%s: invalid number %q", key, value)
+		}
+		*field = n
+	}
+	return v, v.Validate()
+}
+
+// Validate checks the class and that no dimension is negative
+func (v Vehicle) Validate() error {
+	if v.Class != "" && !slices.Contains(Classes, v.Class) {
+		return fmt.Errorf("unknown class %q, expected one of %s", v.Class, strings.Join(Classes, ", "))
+	}
+	for _, f := range []struct {
+		name  string
+		value float64
+	}{{"weight", v.Weight}, {"axle_load", v.AxleLoad}, {"height", v.Height}, {"width", v.Width}, {"length", v.Length}} {
+		if f.value < 0 || math.IsNaN(f.value) || math.IsInf(f.value, 0) {
+			return fmt.Errorf("%s must be a non-negative number, got %v", f.name, f.value)
+		}
+	}
+	return nil
+}
+
+// Applies reports whether a restriction track concerns the vehicle. A
+// restriction is only dropped on the strength of the catalogue entry of its
+// icon code: a weight, axle load, height, width or length limit in t or m
+// that the vehicle does not exceed, or a heavy vehicle ban for a car or van.
+// A limit without a value, a vehicle dimension left unknown, and every
+// restriction whose icon is not in the catalogue are kept to be safe.
+func (v Vehicle) Applies(track converter.Track) bool {
+	return v.applies(track, icons.Lookup)
+}
+
+func (v Vehicle) applies(track converter.Track, lookup func(code string) (icons.Icon, bool)) bool {
+	code, _ := track.Properties["icon"].(string)
+	icon, ok := lookup(code)
+	if !ok {
+		return true
+	}
+	if icon.Kind == icons.KindHeavyVehicles {
+		return v.Class == "" || slices.Contains(heavyClasses, v.Class)
+	}
+	if unit, ok := icons.Units[icon.Kind]; !ok || icon.Unit != unit {
+		return true
+	}
+
+	var dimension float64
+	switch icon.Kind {
+	case icons.KindWeight:
+		dimension = v.Weight
+	case icons.KindAxleLoad:
+		dimension = v.AxleLoad
+	case icons.KindHeight:
+		dimension = v.Height
+	case icons.KindWidth:
+		dimension = v.Width
+	case icons.KindLength:
+		dimension = v.Length
+	}
+	limit, _ := track.Properties["iconValue"].(float64)
+	if limit <= 0 || dimension == 0 {
+		return true
+	}
+	return dimension > limit
+}
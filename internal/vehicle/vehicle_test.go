@@ -0,0 +1,178 @@
+package vehicle
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/icons"
+)
+
+// catalog stands in for icon codes not yet in the real catalogue
+var catalog = map[string]icons.Icon{
+	"901": {Code: "901", Kind: icons.KindWeight, Unit: "t"},
+	"902": {Code: "902", Kind: icons.KindAxleLoad, Unit: "t"},
+	"903": {Code: "903", Kind: icons.KindHeight, Unit: "m"},
+	"904": {Code: "904", Kind: icons.KindWidth, Unit: "m"},
+	"905": {Code: "905", Kind: icons.KindLength, Unit: "m"},
+	"906": {Code: "906", Kind: icons.KindHeavyVehicles},
+	"907": {Code: "907", Kind: icons.KindWeight},
+	"76":  {Code: "76", Kind: icons.KindSpeedLimit, Unit: "km/h"},
+}
+
+func lookup(code string) (icons.Icon, bool) {
+	icon, ok := catalog[code]
+	return icon, ok
+}
+
+func restriction(name, icon string, value float64) converter.Track {
+	props := map[string]any{"type": name, "icon": icon}
+	if value > 0 {
+		props["iconValue"] = value
+	}
+	return converter.Track{Name: name, Properties: props}
+}
+
+func TestParse(t *testing.T) {
+	v, err := Parse("class=truck, weight=40,axle_load=11.5,height=4,width=2.55,length=16.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Vehicle{Class: ClassTruck, Weight: 40, AxleLoad: 11.5, Height: 4, Width: 2.55, Length: 16.5}
+	if v != want {
+		t.Errorf("Parse = %+v, want %+v", v, want)
+	}
+
+	for spec, msg := range map[string]string{
+		"weight":      `"weight" is not a key=value pair`,
+		"mass=3":      `unknown key "mass", expected class, weight, axle_load, height, width or length`,
+		"height=high": `height: invalid number "high"`,
+		"class=tank":  `unknown class "tank", expected one of car, van, truck, bus`,
+		"width=-2":    "width must be a non-negative number, got -2",
+		"length=+Inf": "length must be a non-negative number, got +Inf",
+	} {
+		if _, err := Parse(spec); err == nil || err.Error() != msg {
+			t.Errorf("Parse(%q) error = %v, want %q", spec, err, msg)
+		}
+	}
+}
+
+func TestApplies(t *testing.T) {
+	van := Vehicle{Class: ClassVan, Weight: 3.5, Height: 2.8}
+	truck := Vehicle{Class: ClassTruck, Weight: 40, AxleLoad: 11.5, Height: 4, Width: 2.55, Length: 16.5}
+
+	tests := []struct {
+		track converter.Track
+		van   bool
+		truck bool
+	}{
+		{restriction("Masės ribojimas", "901", 10), false, true},
+		{restriction("Ašies apkrovos ribojimas", "902", 8), true, true},
+		{restriction("Aukščio ribojimas", "903", 3.5), false, true},
+		{restriction("Pločio ribojimas", "904", 2.5), true, true},
+		{restriction("Ilgio ribojimas", "905", 12), true, true},
+		{restriction("Masės ribojimas", "901", 0), true, true},
+		{restriction("Sunkiasvorių transporto priemonių eismas draudžiamas", "906", 0), false, true},
+		{restriction("Kelio remontas", "76", 50), true, true},
+
+		// A limit whose unit is not known cannot be compared
+		{restriction("Masės ribojimas", "907", 10), true, true},
+
+		// Names alone never drop anything
+		{restriction("Masės ribojimas", "999", 10), true, true},
+		{restriction("Sunkiasvorių transporto priemonių eismas draudžiamas", "999", 0), true, true},
+		{restriction("Eismo įvykis", "", 0), true, true},
+	}
+	for _, tt := range tests {
+		code := tt.track.Properties["icon"]
+		if got := van.applies(tt.track, lookup); got != tt.van {
+			t.Errorf("van: Applies(%q, icon %v) = %v, want %v", tt.track.Name, code, got, tt.van)
+		}
+		if got := truck.applies(tt.track, lookup); got != tt.truck {
+			t.Errorf("truck: Applies(%q, icon %v) = %v, want %v", tt.track.Name, code, got, tt.truck)
+		}
+	}
+
+	// Unknown dimensions keep their restrictions
+	if !(Vehicle{Class: ClassCar}).applies(restriction("Aukščio ribojimas", "903", 2), lookup) {
+		t.Error("a height limit was dropped for a vehicle of unknown height")
+	}
+}
+
+// A weight restriction feature may also carry a height limit; only the
+// catalogued weight limit is compared with the vehicle's weight
+func TestAppliesMixedFeature(t *testing.T) {
+	van := Vehicle{Class: ClassVan, Weight: 2.5, Height: 3}
+	weight := restriction("Masės ribojimas", "901", 10)
+	height := restriction("Masės ribojimas", "999", 2.8)
+	for _, track := range []converter.Track{weight, height} {
+		track.Properties["kind"] = icons.KindWeight
+		track.Properties["kindSource"] = icons.SourceFeature
+	}
+
+	if van.applies(weight, lookup) {
+		t.Error("the catalogued 10 t limit was kept for a 2.5 t van")
+	}
+	if !van.applies(height, lookup) {
+		t.Error("the uncatalogued 2.8 limit was dropped for a 2.5 t van as if it were a weight limit")
+	}
+}
+
+// The real catalogue has no limit icons yet, so nothing is dropped
+func TestAppliesCatalog(t *testing.T) {
+	van := Vehicle{Class: ClassVan, Weight: 3.5, Height: 2.8}
+	for _, track := range []converter.Track{restriction("Kelio remontas", "76", 50), restriction("Kelio remontas", "57", 0)} {
+		if !van.Applies(track) {
+			t.Errorf("Applies(icon %v) = false", track.Properties["icon"])
+		}
+	}
+}
+
+// The restrictions of a fixture, classified with a fixture catalogue of
+// limit icons, as a van and a truck see them
+func TestAppliesFixture(t *testing.T) {
+	f, err := os.Open("../../testdata/icon_catalog_limits.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	list, err := icons.ReadCatalog(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture := make(map[string]icons.Icon, len(list))
+	for _, icon := range list {
+		fixture[icon.Code] = icon
+	}
+	lookup := func(code string) (icons.Icon, bool) {
+		icon, ok := fixture[code]
+		return icon, ok
+	}
+
+	van := Vehicle{Class: ClassVan, Weight: 3.5, Height: 2.8}
+	truck := Vehicle{Class: ClassTruck, Weight: 40, Height: 4}
+	var vanKeeps, truckKeeps []string
+	for track, err := range converter.EALTracks(data.EALFeaturesFromFiles([]string{"../../testdata/eal_vehicle_limits.json"})) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := track.Properties["id"].(string)
+		if van.applies(track, lookup) {
+			vanKeeps = append(vanKeeps, id)
+		}
+		if truck.applies(track, lookup) {
+			truckKeeps = append(truckKeeps, id)
+		}
+	}
+
+	// The van is under the 10 t and 3.5 m limits and no heavy vehicle; the
+	// uncatalogued limit of the weight restriction feature is kept
+	if want := []string{"TEST:V001-2", "TEST:V004-1"}; !slices.Equal(vanKeeps, want) {
+		t.Errorf("van keeps %v, want %v", vanKeeps, want)
+	}
+	if want := []string{"TEST:V001-1", "TEST:V001-2", "TEST:V002-1", "TEST:V003-1", "TEST:V004-1"}; !slices.Equal(truckKeeps, want) {
+		t.Errorf("truck keeps %v, want %v", truckKeeps, want)
+	}
+}
@@ -0,0 +1,92 @@
+package watch
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+)
+
+// Item identifies one track of a dataset in a change report
+type Item struct {
+	ID         string         `json:"id"`
+	Name       string         `json:"name"`
+	Properties map[string]any `json:"properties,omitempty"`
+}
+
+// Change lists the tracks that appeared in or disappeared from a source
+// between two runs
+type Change struct {
+	Source  string    `json:"source"`
+	Time    time.Time `json:"time"`
+	Output  string    `json:"output,omitempty"`
+	Added   []Item    `json:"added"`
+	Removed []Item    `json:"removed"`
+}
+
+// Empty reports whether nothing was added or removed
+func (c Change) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// TrackID returns the stable identity of a track: the restriction ID for
+// road restrictions, the object ID for ArcGIS features and the name for
+// anything else
+func TrackID(track converter.Track) string {
+	if id, ok := track.Properties["id"].(string); ok && id != "" {
+		return id
+	}
+	for name, value := range track.Properties {
+		if !strings.EqualFold(name, "objectid") {
+			continue
+		}
+		switch v := value.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case int:
+			return strconv.Itoa(v)
+		case int64:
+			return strconv.FormatInt(v, 10)
+		case string:
+			return v
+		}
+	}
+	return track.Name
+}
+
+// Items returns the items of tracks in ID order. Repeated IDs are made
+// unique with a "#n" suffix so that every track is accounted for.
+func Items(tracks []converter.Track) []Item {
+	result := make([]Item, 0, len(tracks))
+	seen := make(map[string]int, len(tracks))
+	for _, track := range tracks {
+		id := TrackID(track)
+		if seen[id]++; seen[id] > 1 {
+			id += "#" + strconv.Itoa(seen[id])
+		}
+		result = append(result, Item{ID: id, Name: track.Name, Properties: track.Properties})
+	}
+	slices.SortFunc(result, func(a, b Item) int { return strings.Compare(a.ID, b.ID) })
+	return result
+}
+
+// Diff compares two item lists sorted by ID
+func Diff(old, new []Item) (added, removed []Item) {
+	i, j := 0, 0
+	for i < len(old) || j < len(new) {
+		switch {
+		case j == len(new) || (i < len(old) && old[i].ID < new[j].ID):
+			removed = append(removed, old[i])
+			i++
+		case i == len(old) || new[j].ID < old[i].ID:
+			added = append(added, new[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,89 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+
+	"github.com/dimchansky/lt-road-info/internal/webhook"
+)
+
+// Hook is notified about the tracks added to or removed from a source
+type Hook interface {
+	Run(ctx context.Context, change Change) error
+}
+
+// CommandHook runs a shell command with the change as JSON on its standard
+// input. The environment additionally carries LT_ROAD_INFO_SOURCE,
+// LT_ROAD_INFO_OUTPUT, LT_ROAD_INFO_ADDED and LT_ROAD_INFO_REMOVED, the
+// last two being counts.
+type CommandHook struct {
+	Command string
+}
+
+// Run executes the command and waits for it to finish
+func (h CommandHook) Run(ctx context.Context, change Change) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", h.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.Command)
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		"LT_ROAD_INFO_SOURCE="+change.Source,
+		"LT_ROAD_INFO_OUTPUT="+change.Output,
+		"LT_ROAD_INFO_ADDED="+strconv.Itoa(len(change.Added)),
+		"LT_ROAD_INFO_REMOVED="+strconv.Itoa(len(change.Removed)),
+	)
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("hook command failed: %w", err)
+	}
+	return nil
+}
+
+// WebhookHook POSTs the change as JSON to a URL
+type WebhookHook struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewWebhookHook creates a webhook hook. A nil httpClient uses
+// http.DefaultClient.
+func NewWebhookHook(url string, httpClient *http.Client) *WebhookHook {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &WebhookHook{url: url, httpClient: httpClient}
+}
+
+// Run posts the change and expects a 2xx response
+func (h *WebhookHook) Run(ctx context.Context, change Change) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhook.Post(ctx, h.httpClient, h.url, nil, payload)
+	if err != nil {
+		return err
+	}
+	if !resp.OK() {
+		return fmt.Errorf("%s returned unexpected response status: %s", webhook.Redact(h.url), resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Snapshot is what a watcher remembers about the last output written for
+// a source
+type Snapshot struct {
+	Hash string `json:"hash"`
+
+	// UpstreamFeatures is the number of features read from upstream, before
+	// any filter
+	UpstreamFeatures int       `json:"upstream_features"`
+	Time             time.Time `json:"time"`
+	Items            []Item    `json:"items"`
+
+	// Config identifies the settings the outputs were generated with
+	Config string `json:"config,omitempty"`
+}
+
+// State records the last snapshot per source in a JSON file, so that
+// changes are reported correctly across restarts
+type State struct {
+	path string
+
+	mu        sync.Mutex
+	snapshots map[string]Snapshot
+}
+
+// LoadState reads the state file at path. A missing file yields an empty state.
+func LoadState(path string) (*State, error) {
+	s := &State{path: path, snapshots: make(map[string]Snapshot)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read watch state: %w", err)
+	}
+	if err := json.Unmarshal(content, &s.snapshots); err != nil {
+		return nil, fmt.Errorf("failed to parse watch state %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Snapshot returns the last snapshot of source
+func (s *State) Snapshot(source string) (Snapshot, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap, ok := s.snapshots[source]
+	return snap, ok
+}
+
+// Record stores the snapshot of source
+func (s *State) Record(source string, snap Snapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[source] = snap
+}
+
+// Save writes the state file
+func (s *State) Save() error {
+	s.mu.Lock()
+	content, err := json.Marshal(s.snapshots)
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write watch state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package watch periodically regenerates outputs and runs hooks when
+// tracks appear or disappear upstream.
+package watch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"iter"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+	"github.com/dimchansky/lt-road-info/internal/logging"
+	"github.com/dimchansky/lt-road-info/internal/metrics"
+	"github.com/dimchansky/lt-road-info/internal/schedule"
+)
+
+// defaultHookTimeout bounds a single hook run unless Config says otherwise
+const defaultHookTimeout = time.Minute
+
+// Source is a dataset regenerated by the watcher
+type Source struct {
+	Name  string
+	Title string
+
+	// Path is the output reported to hooks. Unless Outputs is set it is
+	// written as GPX.
+	Path string
+
+	// Outputs, if set, are written instead of the GPX at Path; Path should
+	// be one of them
+	Outputs []converter.Output
+
+	// Config identifies the settings the outputs are generated with, e.g.
+	// config.Source.Fingerprint; outputs of other settings are regenerated
+	Config string
+
+	// Fetch returns a fresh stream of tracks from upstream, counting the
+	// upstream features read in stats. If conditional is set, the current
+	// outputs were generated with the same settings and the stream may end
+	// with data.ErrUnchanged to report that upstream has not changed.
+	Fetch func(stats *converter.Stats, conditional bool) iter.Seq2[converter.Track, error]
+
+	// AfterWrite, if set, is called with the tracks of every newly written
+	// output, e.g. to derive further files from them
+	AfterWrite func(tracks []converter.Track, t time.Time) error
+}
+
+// Config holds the settings applied to every source
+type Config struct {
+	Output      converter.OutputOptions
+	Thresholds  guard.Thresholds
+	Hooks       []Hook
+	HookTimeout time.Duration
+
+	// Metrics, if set, records the outcome of every check
+	Metrics *metrics.Metrics
+}
+
+// Result is the outcome of checking one source
+type Result struct {
+	Source    string
+	Unchanged bool
+	Change    Change
+	Err       error
+}
+
+// Watcher regenerates the outputs of its sources when their content changes
+type Watcher struct {
+	sources []Source
+	state   *State
+	cfg     Config
+}
+
+// New creates a watcher. The state provides the previous snapshots that
+// changes are reported against; without one the first check of every
+// source only records a baseline.
+func New(sources []Source, state *State, cfg Config) *Watcher {
+	if cfg.HookTimeout <= 0 {
+		cfg.HookTimeout = defaultHookTimeout
+	}
+	return &Watcher{sources: sources, state: state, cfg: cfg}
+}
+
+// Run checks all sources immediately and then at every activation of
+// sched until ctx is done
+func (w *Watcher) Run(ctx context.Context, sched schedule.Schedule) {
+	for {
+		w.Check(ctx)
+
+		next := sched.Next(time.Now())
+		if next.IsZero() {
+			slog.Info("Schedule has no further activations, stopping")
+			return
+		}
+		slog.Info("Next check scheduled", "next", next.Format(time.RFC3339))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// Check checks all sources concurrently, regenerates the outputs of those
+// that changed and saves the state
+func (w *Watcher) Check(ctx context.Context) []Result {
+	results := make([]Result, len(w.sources))
+
+	var wg sync.WaitGroup
+	for i, src := range w.sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = w.check(ctx, src)
+			w.cfg.Metrics.Refreshed(src.Name, results[i].Unchanged, results[i].Err)
+		}()
+	}
+	wg.Wait()
+
+	if err := w.state.Save(); err != nil {
+		slog.Error("Failed to save watch state", logging.Err(err))
+	}
+	return results
+}
+
+func (w *Watcher) check(ctx context.Context, src Source) Result {
+	result := Result{Source: src.Name}
+
+	previous, hasPrevious := w.state.Snapshot(src.Name)
+	outputs := src.Outputs
+	if len(outputs) == 0 {
+		outputs = []converter.Output{{Path: src.Path, Format: converter.FormatGPX}}
+	}
+	current := hasPrevious && previous.Config == src.Config && allExist(outputs)
+
+	var (
+		tracks []converter.Track
+		stats  converter.Stats
+	)
+	for track, err := range src.Fetch(&stats, current) {
+		if errors.Is(err, data.ErrUnchanged) {
+			slog.Info("No changes", "source", src.Name)
+			result.Unchanged = true
+			return result
+		}
+		if err != nil {
+			slog.Error("Failed to download", "source", src.Name, logging.Err(err))
+			result.Err = err
+			return result
+		}
+		tracks = append(tracks, track)
+	}
+
+	hash, err := hashTracks(tracks)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	if current && previous.Hash == hash {
+		slog.Info("No changes", "source", src.Name)
+		result.Unchanged = true
+		return result
+	}
+
+	var last *guard.Run
+	if hasPrevious {
+		last = &guard.Run{UpstreamFeatures: previous.UpstreamFeatures, Time: previous.Time}
+	}
+	opts := w.cfg.Output
+	opts.Validate = func(int) error {
+		return w.cfg.Thresholds.Check(src.Name, stats.Features, last)
+	}
+	if err := converter.SaveTracks(tracksOf(tracks), src.Title, outputs, opts); err != nil {
+		slog.Warn("Kept previous output", "source", src.Name, logging.Err(err))
+		result.Err = err
+		return result
+	}
+
+	now := time.Now()
+	items := Items(tracks)
+	w.state.Record(src.Name, Snapshot{Hash: hash, UpstreamFeatures: stats.Features, Time: now, Items: items, Config: src.Config})
+
+	result.Change = Change{Source: src.Name, Time: now, Output: src.Path}
+	result.Change.Added, result.Change.Removed = Diff(previous.Items, items)
+	slog.Info("Updated output", "source", src.Name, "path", src.Path, "features", len(tracks),
+		"added", len(result.Change.Added), "removed", len(result.Change.Removed))
+
+	if src.AfterWrite != nil {
+		if err := src.AfterWrite(tracks, now); err != nil {
+			slog.Error("Failed to update derived files", "source", src.Name, "path", src.Path, logging.Err(err))
+		}
+	}
+
+	switch {
+	case !hasPrevious:
+		slog.Info("Recorded baseline, hooks run from the next change on", "source", src.Name)
+	case !result.Change.Empty():
+		w.runHooks(ctx, result.Change)
+	}
+	return result
+}
+
+// runHooks runs every hook in turn. A failing hook is logged and does not
+// prevent the others from running.
+func (w *Watcher) runHooks(ctx context.Context, change Change) {
+	for _, hook := range w.cfg.Hooks {
+		hookCtx, cancel := context.WithTimeout(ctx, w.cfg.HookTimeout)
+		if err := hook.Run(hookCtx, change); err != nil {
+			slog.Error("Hook failed", "source", change.Source, logging.Err(err))
+		}
+		cancel()
+	}
+}
+
+// hashTracks fingerprints the content of a dataset; the JSON encoding is
+// deterministic because map keys are sorted
+func hashTracks(tracks []converter.Track) (string, error) {
+	content, err := json.Marshal(tracks)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func tracksOf(tracks []converter.Track) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		for _, track := range tracks {
+			if !yield(track, nil) {
+				return
+			}
+		}
+	}
+}
+
+func allExist(outputs []converter.Output) bool {
+	for _, out := range outputs {
+		if _, err := os.Stat(out.Path); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,288 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"iter"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dimchansky/lt-road-info/internal/converter"
+	"github.com/dimchansky/lt-road-info/internal/data"
+	"github.com/dimchansky/lt-road-info/internal/guard"
+)
+
+func track(id, name string) converter.Track {
+	return converter.Track{
+		Name:       name,
+		Segments:   [][]converter.Point{{{Lat: 54.68, Lon: 25.27}, {Lat: 54.69, Lon: 25.28}}},
+		Properties: map[string]any{"id": id},
+	}
+}
+
+// fakeSource serves whatever tracks or error it currently holds. With
+// unchanged set it answers conditional fetches like a 304 response.
+type fakeSource struct {
+	tracks    []converter.Track
+	err       error
+	unchanged bool
+}
+
+// fetch yields the tracks, each read from one upstream feature
+func (f *fakeSource) fetch(stats *converter.Stats, conditional bool) iter.Seq2[converter.Track, error] {
+	return func(yield func(converter.Track, error) bool) {
+		if conditional && f.unchanged {
+			yield(converter.Track{}, data.ErrUnchanged)
+			return
+		}
+		for _, t := range f.tracks {
+			stats.Features++
+			if !yield(t, nil) {
+				return
+			}
+		}
+		if f.err != nil {
+			yield(converter.Track{}, f.err)
+		}
+	}
+}
+
+// recordingHook remembers the changes it was given
+type recordingHook struct {
+	mu      sync.Mutex
+	changes []Change
+}
+
+func (h *recordingHook) Run(ctx context.Context, change Change) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changes = append(h.changes, change)
+	return nil
+}
+
+func TestTrackID(t *testing.T) {
+	tests := []struct {
+		track converter.Track
+		want  string
+	}{
+		{converter.Track{Name: "a", Properties: map[string]any{"id": "r-1"}}, "r-1"},
+		{converter.Track{Name: "b", Properties: map[string]any{"objectid": 42.0}}, "42"},
+		{converter.Track{Name: "c", Properties: map[string]any{"OBJECTID": int64(7)}}, "7"},
+		{converter.Track{Name: "d"}, "d"},
+	}
+	for _, tt := range tests {
+		if got := TrackID(tt.track); got != tt.want {
+			t.Errorf("TrackID(%s) = %q, expected %q", tt.track.Name, got, tt.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := Items([]converter.Track{track("1", "one"), track("2", "two"), track("3", "three")})
+	new := Items([]converter.Track{track("4", "four"), track("2", "two"), track("1", "one")})
+
+	added, removed := Diff(old, new)
+	if len(added) != 1 || added[0].ID != "4" {
+		t.Errorf("Expected 4 to be added, got %+v", added)
+	}
+	if len(removed) != 1 || removed[0].ID != "3" {
+		t.Errorf("Expected 3 to be removed, got %+v", removed)
+	}
+
+	// Tracks without IDs that share a name are all accounted for
+	dup := Items([]converter.Track{{Name: "x"}, {Name: "x"}})
+	if dup[0].ID == dup[1].ID {
+		t.Errorf("Expected unique IDs, got %+v", dup)
+	}
+}
+
+func TestWatcherCheck(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "restrictions.gpx")
+	src := &fakeSource{tracks: []converter.Track{track("1", "one"), track("2", "two")}}
+
+	var posted []Change
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change Change
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			t.Errorf("Webhook received invalid JSON: %v", err)
+		}
+		posted = append(posted, change)
+	}))
+	defer webhook.Close()
+
+	hook := &recordingHook{}
+	state, err := LoadState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	var written []int
+	afterWrite := func(tracks []converter.Track, _ time.Time) error {
+		written = append(written, len(tracks))
+		return nil
+	}
+	w := New([]Source{{Name: "restrictions", Title: "Restrictions", Path: output, Fetch: src.fetch, AfterWrite: afterWrite}}, state, Config{
+		Thresholds: guard.Thresholds{MinFeatures: 1},
+		Hooks:      []Hook{hook, NewWebhookHook(webhook.URL, nil)},
+	})
+	ctx := context.Background()
+
+	// The first check writes the output and records a baseline
+	if r := w.Check(ctx)[0]; r.Err != nil || r.Unchanged {
+		t.Fatalf("Unexpected first result: %+v", r)
+	}
+	if _, err := os.Stat(output); err != nil {
+		t.Fatalf("Expected output to be written: %v", err)
+	}
+	if len(hook.changes) != 0 {
+		t.Errorf("Hooks should not run for the baseline, got %+v", hook.changes)
+	}
+
+	// Identical content does not touch the output
+	stat, _ := os.Stat(output)
+	if r := w.Check(ctx)[0]; !r.Unchanged {
+		t.Errorf("Expected unchanged result, got %+v", r)
+	}
+	if again, _ := os.Stat(output); !again.ModTime().Equal(stat.ModTime()) {
+		t.Error("Output was rewritten without a change")
+	}
+
+	// A restriction disappears and another one appears
+	src.tracks = []converter.Track{track("2", "two"), track("3", "three")}
+	r := w.Check(ctx)[0]
+	if r.Err != nil || len(r.Change.Added) != 1 || len(r.Change.Removed) != 1 {
+		t.Fatalf("Unexpected change: %+v", r)
+	}
+	if len(hook.changes) != 1 || hook.changes[0].Added[0].ID != "3" || hook.changes[0].Removed[0].ID != "1" {
+		t.Errorf("Unexpected hook changes: %+v", hook.changes)
+	}
+	if len(posted) != 1 || posted[0].Source != "restrictions" || posted[0].Added[0].Name != "three" {
+		t.Errorf("Unexpected webhook payloads: %+v", posted)
+	}
+
+	// The state survives a restart
+	reloaded, err := LoadState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to reload state: %v", err)
+	}
+	if snap, ok := reloaded.Snapshot("restrictions"); !ok || snap.UpstreamFeatures != 2 || len(snap.Items) != 2 {
+		t.Errorf("Unexpected saved snapshot: %+v", snap)
+	}
+
+	// A collapsed dataset is rejected and fires no hooks
+	src.tracks = nil
+	if r := w.Check(ctx)[0]; !errors.Is(r.Err, guard.ErrRejected) {
+		t.Errorf("Expected the empty dataset to be rejected, got %+v", r)
+	}
+	if len(hook.changes) != 1 {
+		t.Errorf("Hooks should not run for rejected data, got %d runs", len(hook.changes))
+	}
+
+	if len(written) != 2 || written[0] != 2 || written[1] != 2 {
+		t.Errorf("Expected AfterWrite for each written output only, got %v", written)
+	}
+
+	// Upstream reporting no change is passed through
+	src.unchanged = true
+	if r := w.Check(ctx)[0]; !r.Unchanged {
+		t.Errorf("Expected unchanged result, got %+v", r)
+	}
+}
+
+func TestWatcherRegeneratesOnConfigChange(t *testing.T) {
+	dir := t.TempDir()
+	output := filepath.Join(dir, "restrictions.gpx")
+	src := &fakeSource{tracks: []converter.Track{track("1", "one")}, unchanged: true}
+	state, err := LoadState(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+	watcher := func(config string) *Watcher {
+		return New([]Source{{Name: "restrictions", Title: "Restrictions", Path: output, Config: config, Fetch: src.fetch}}, state, Config{})
+	}
+	ctx := context.Background()
+
+	if r := watcher("a").Check(ctx)[0]; r.Err != nil || r.Unchanged {
+		t.Fatalf("Expected the first check to write the output, got %+v", r)
+	}
+	if r := watcher("a").Check(ctx)[0]; !r.Unchanged {
+		t.Fatalf("Expected unchanged upstream to keep the output, got %+v", r)
+	}
+
+	// Other settings regenerate the output although upstream is unchanged
+	// and the tracks are the same
+	if r := watcher("b").Check(ctx)[0]; r.Err != nil || r.Unchanged {
+		t.Fatalf("Expected new settings to rewrite the output, got %+v", r)
+	}
+	if snap, _ := state.Snapshot("restrictions"); snap.Config != "b" {
+		t.Errorf("Expected the new settings to be recorded, got %q", snap.Config)
+	}
+	if r := watcher("b").Check(ctx)[0]; !r.Unchanged {
+		t.Errorf("Expected unchanged result once regenerated, got %+v", r)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "hook.out")
+	hook := CommandHook{Command: `{ echo "$LT_ROAD_INFO_SOURCE $LT_ROAD_INFO_ADDED $LT_ROAD_INFO_REMOVED"; cat; } > ` + out}
+
+	change := Change{Source: "restrictions", Added: []Item{{ID: "3", Name: "three"}}}
+	if err := hook.Run(context.Background(), change); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+
+	content, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("Hook wrote nothing: %v", err)
+	}
+	header, payload, _ := strings.Cut(string(content), "\n")
+	if header != "restrictions 1 0" {
+		t.Errorf("Unexpected environment: %q", header)
+	}
+	if !strings.Contains(payload, `"name":"three"`) {
+		t.Errorf("Expected the change JSON on stdin, got %s", payload)
+	}
+
+	if err := (CommandHook{Command: "exit 3"}).Run(context.Background(), change); err == nil {
+		t.Error("Expected an error for a failing command")
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		if r.URL.Query().Get("fail") != "" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+
+	change := Change{Source: "restrictions", Added: []Item{{ID: "3", Name: "three"}}}
+	if err := NewWebhookHook(srv.URL, nil).Run(context.Background(), change); err != nil {
+		t.Fatalf("Hook failed: %v", err)
+	}
+	if !strings.Contains(body, `"name":"three"`) {
+		t.Errorf("Expected the change JSON, got %s", body)
+	}
+	if err := NewWebhookHook(srv.URL+"/secret-token?fail=1", nil).Run(context.Background(), change); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected a status error without the token, got %v", err)
+	}
+
+	srv.Close()
+	if err := NewWebhookHook(srv.URL+"/secret-token", nil).Run(context.Background(), change); err == nil || strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected a connection error without the token, got %v", err)
+	}
+}
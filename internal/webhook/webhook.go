@@ -0,0 +1,75 @@
+// Package webhook posts JSON payloads to webhook URLs. Chat services embed
+// their tokens in the URL, so errors only ever name its scheme and host.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Response is the status of a posted payload
+type Response struct {
+	StatusCode int
+	Status     string
+	Header     http.Header
+}
+
+// OK reports a 2xx status
+func (r Response) OK() bool {
+	return r.StatusCode >= 200 && r.StatusCode <= 299
+}
+
+// Post sends body as JSON to rawURL with the extra header, and returns the
+// response status once its body has been read. Errors carry the redacted
+// URL only, never the one that failed.
+func Post(ctx context.Context, client *http.Client, rawURL string, header http.Header, body []byte) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, fmt.Errorf("invalid webhook URL %s: %w", Redact(rawURL), unwrap(err))
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "lt-road-info")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to post to %s: %w", Redact(rawURL), unwrap(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return Response{StatusCode: resp.StatusCode, Status: resp.Status, Header: resp.Header}, nil
+}
+
+// unwrap drops the *url.Error around err, whose message holds the URL
+func unwrap(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// Redact hides everything but the scheme and host of a URL, as webhook URLs
+// of chat services embed their tokens in the path, query or user info
+func Redact(url string) string {
+	scheme, rest, ok := strings.Cut(url, "://")
+	if !ok {
+		return "webhook"
+	}
+	host := rest
+	if i := strings.IndexAny(host, "/?#"); i >= 0 {
+		host = host[:i]
+	}
+	if i := strings.LastIndex(host, "@"); i >= 0 {
+		host = host[i+1:]
+	}
+	return scheme + "://" + host + "/..."
+}